@@ -21,7 +21,7 @@ func init() {
 		_ = mdb.Set([]byte("u"), []byte{21})
 		_ = mdb.Set([]byte("z"), []byte{26})
 		return NewPrefixDB(mdb, []byte("test/")), nil
-	}, false)
+	}, Capabilities{Snapshots: true}, false)
 }
 
 func cleanupDBDir(dir, name string) {
@@ -477,3 +477,827 @@ func assertKeyValues(t *testing.T, db DB, expect map[string][]byte) {
 
 	require.Equal(t, expect, actual)
 }
+
+func TestDBSnapshot(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBSnapshot(t, dbType)
+		})
+	}
+}
+
+func testDBSnapshot(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+
+	require.NoError(t, db.Set([]byte("a"), []byte{1}))
+	require.NoError(t, db.Set([]byte("b"), []byte{2}))
+	require.NoError(t, db.Set([]byte("c"), []byte{3}))
+
+	snap, err := db.NewSnapshot()
+	require.NoError(t, err)
+
+	// writes after the snapshot was taken must not be visible through it
+	require.NoError(t, db.Set([]byte("a"), []byte{9}))
+	require.NoError(t, db.Delete([]byte("b")))
+	require.NoError(t, db.Set([]byte("d"), []byte{4}))
+	batch := db.NewBatch()
+	require.NoError(t, batch.Set([]byte("e"), []byte{5}))
+	require.NoError(t, batch.Delete([]byte("c")))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+
+	value, err := snap.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, value)
+	value, err = snap.Get([]byte("d"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	ok, err := snap.Has([]byte("b"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = snap.Has([]byte("e"))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = snap.Get([]byte{})
+	require.Equal(t, errKeyEmpty, err)
+	_, err = snap.Has(nil)
+	require.Equal(t, errKeyEmpty, err)
+	_, err = snap.Iterator([]byte{}, nil)
+	require.Equal(t, errKeyEmpty, err)
+	_, err = snap.ReverseIterator(nil, []byte{})
+	require.Equal(t, errKeyEmpty, err)
+
+	itr, err := snap.Iterator(nil, nil)
+	require.NoError(t, err)
+	var keys []string
+	for ; itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.Key()))
+	}
+	require.NoError(t, itr.Error())
+	require.NoError(t, itr.Close())
+	require.Equal(t, []string{"a", "b", "c"}, keys)
+
+	ritr, err := snap.ReverseIterator([]byte("b"), nil)
+	require.NoError(t, err)
+	keys = nil
+	for ; ritr.Valid(); ritr.Next() {
+		keys = append(keys, string(ritr.Key()))
+	}
+	require.NoError(t, ritr.Error())
+	require.NoError(t, ritr.Close())
+	require.Equal(t, []string{"c", "b"}, keys)
+
+	// the live database is unaffected by the snapshot
+	assertKeyValues(t, db, map[string][]byte{"a": {9}, "d": {4}, "e": {5}})
+
+	// closing is idempotent, and other operations on a closed snapshot should error
+	require.NoError(t, snap.Close())
+	require.NoError(t, snap.Close())
+	_, err = snap.Get([]byte("a"))
+	require.Error(t, err)
+	_, err = snap.Iterator(nil, nil)
+	require.Error(t, err)
+}
+
+func TestDBDeleteRange(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBDeleteRange(t, dbType)
+		})
+	}
+}
+
+func testDBDeleteRange(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+
+	reset := func() {
+		require.NoError(t, db.DeleteRange(nil, nil))
+		for _, k := range []string{"a", "b", "c", "d", "e"} {
+			require.NoError(t, db.Set([]byte(k), []byte(k)))
+		}
+	}
+
+	// empty keys are disallowed, but nil bounds are fine
+	require.Equal(t, errKeyEmpty, db.DeleteRange([]byte{}, nil))
+	require.Equal(t, errKeyEmpty, db.DeleteRange(nil, []byte{}))
+
+	reset()
+	require.NoError(t, db.DeleteRange([]byte("b"), []byte("d")))
+	assertKeyValues(t, db, map[string][]byte{"a": []byte("a"), "d": []byte("d"), "e": []byte("e")})
+
+	reset()
+	require.NoError(t, db.DeleteRange(nil, []byte("c")))
+	assertKeyValues(t, db, map[string][]byte{"c": []byte("c"), "d": []byte("d"), "e": []byte("e")})
+
+	reset()
+	require.NoError(t, db.DeleteRange([]byte("c"), nil))
+	assertKeyValues(t, db, map[string][]byte{"a": []byte("a"), "b": []byte("b")})
+
+	// an inverted or empty range deletes nothing
+	reset()
+	require.NoError(t, db.DeleteRange([]byte("d"), []byte("b")))
+	require.NoError(t, db.DeleteRange([]byte("x"), []byte("z")))
+	assertKeyValues(t, db, map[string][]byte{
+		"a": []byte("a"), "b": []byte("b"), "c": []byte("c"), "d": []byte("d"), "e": []byte("e"),
+	})
+
+	// batched range deletes apply to earlier operations in the batch, but not later ones
+	reset()
+	batch := db.NewBatch()
+	require.Equal(t, errKeyEmpty, batch.DeleteRange([]byte{}, nil))
+	require.NoError(t, batch.Set([]byte("bb"), []byte("bb")))
+	require.NoError(t, batch.DeleteRange([]byte("b"), []byte("d")))
+	require.NoError(t, batch.Set([]byte("c"), []byte("x")))
+	assertKeyValues(t, db, map[string][]byte{
+		"a": []byte("a"), "b": []byte("b"), "c": []byte("c"), "d": []byte("d"), "e": []byte("e"),
+	})
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	assertKeyValues(t, db, map[string][]byte{
+		"a": []byte("a"), "c": []byte("x"), "d": []byte("d"), "e": []byte("e"),
+	})
+
+	// range deletes on a closed batch should error
+	require.Error(t, batch.DeleteRange([]byte("a"), []byte("b")))
+}
+
+func TestPrefixDBDeleteRange(t *testing.T) {
+	db := NewMemDB()
+	require.NoError(t, db.Set([]byte("a"), []byte{1}))
+	require.NoError(t, db.Set([]byte("key"), []byte{2}))
+	require.NoError(t, db.Set([]byte("key1"), []byte{3}))
+	require.NoError(t, db.Set([]byte("key2"), []byte{4}))
+	require.NoError(t, db.Set([]byte("key3"), []byte{5}))
+	require.NoError(t, db.Set([]byte("kez"), []byte{6}))
+
+	pdb := NewPrefixDB(db, []byte("key"))
+	require.NoError(t, pdb.DeleteRange([]byte("2"), nil))
+	assertKeyValues(t, db, map[string][]byte{"a": {1}, "key": {2}, "key1": {3}, "kez": {6}})
+
+	// a nil start must not touch the key equal to the prefix itself
+	batch := pdb.NewBatch()
+	require.NoError(t, batch.DeleteRange(nil, nil))
+	require.NoError(t, batch.Write())
+	assertKeyValues(t, db, map[string][]byte{"a": {1}, "key": {2}, "kez": {6}})
+}
+
+func TestDBIndexedBatch(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBIndexedBatch(t, dbType)
+		})
+	}
+}
+
+func testDBIndexedBatch(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		require.NoError(t, db.Set([]byte(k), []byte(k)))
+	}
+
+	batch := db.NewIndexedBatch()
+	require.NoError(t, batch.Set([]byte("b"), []byte("x")))
+	require.NoError(t, batch.Set([]byte("bb"), []byte("bb")))
+	require.NoError(t, batch.Delete([]byte("c")))
+	require.NoError(t, batch.DeleteRange([]byte("d"), []byte("f")))
+	require.NoError(t, batch.Set([]byte("e"), []byte("y")))
+
+	// reads see pending writes layered over the database
+	value, err := batch.Get([]byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("x"), value)
+	value, err = batch.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), value)
+	value, err = batch.Get([]byte("d"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	ok, err := batch.Has([]byte("bb"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = batch.Has([]byte("c"))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = batch.Get(nil)
+	require.Equal(t, errKeyEmpty, err)
+	_, err = batch.Iterator([]byte{}, nil)
+	require.Equal(t, errKeyEmpty, err)
+
+	collect := func(itr Iterator) []string {
+		var kvs []string
+		for ; itr.Valid(); itr.Next() {
+			kvs = append(kvs, string(itr.Key())+"="+string(itr.Value()))
+		}
+		require.NoError(t, itr.Error())
+		require.NoError(t, itr.Close())
+		return kvs
+	}
+
+	itr, err := batch.Iterator(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a=a", "b=x", "bb=bb", "e=y", "f=f"}, collect(itr))
+
+	itr, err = batch.ReverseIterator(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"f=f", "e=y", "bb=bb", "b=x", "a=a"}, collect(itr))
+
+	itr, err = batch.Iterator([]byte("b"), []byte("e"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"b=x", "bb=bb"}, collect(itr))
+
+	itr, err = batch.ReverseIterator([]byte("bb"), []byte("f"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"e=y", "bb=bb"}, collect(itr))
+
+	// nothing is visible in the database until the batch is written
+	value, err = db.Get([]byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), value)
+
+	require.NoError(t, batch.Write())
+	assertKeyValues(t, db, map[string][]byte{
+		"a": []byte("a"), "b": []byte("x"), "bb": []byte("bb"), "e": []byte("y"), "f": []byte("f"),
+	})
+
+	// reads on a written batch should error, but closing it should work
+	_, err = batch.Get([]byte("a"))
+	require.Error(t, err)
+	_, err = batch.Iterator(nil, nil)
+	require.Error(t, err)
+	require.NoError(t, batch.Close())
+
+	// an unbounded range delete hides the keys of the database after its start
+	batch = db.NewIndexedBatch()
+	require.NoError(t, batch.DeleteRange([]byte("b"), nil))
+	ok, err = batch.Has([]byte("f"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	itr, err = batch.Iterator(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a=a"}, collect(itr))
+	require.NoError(t, batch.Close())
+}
+
+func TestDBIteratorSeek(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBIteratorSeek(t, dbType)
+		})
+	}
+}
+
+func testDBIteratorSeek(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+
+	for i := 0; i < 10; i += 2 {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), []byte{}))
+	}
+
+	// forward iterators seek to the first key >= the seek key, clamped to the domain
+	itr, err := db.Iterator(int642Bytes(2), int642Bytes(8))
+	require.NoError(t, err)
+	itr.Seek(int642Bytes(3))
+	verifyIterator(t, itr, []int64{4, 6}, "forward seek between keys")
+	itr.Seek(int642Bytes(4))
+	verifyIterator(t, itr, []int64{4, 6}, "forward seek to exhausted iterator")
+	itr.Seek(int642Bytes(0))
+	verifyIterator(t, itr, []int64{2, 4, 6}, "forward seek before start")
+	itr.Seek(int642Bytes(8))
+	verifyIterator(t, itr, []int64(nil), "forward seek to end")
+	require.Panics(t, func() { itr.Seek([]byte{}) })
+	require.NoError(t, itr.Close())
+
+	// reverse iterators seek to the last key < the seek key, clamped to the domain
+	itr, err = db.ReverseIterator(int642Bytes(2), int642Bytes(8))
+	require.NoError(t, err)
+	itr.Seek(int642Bytes(5))
+	verifyIterator(t, itr, []int64{4, 2}, "reverse seek between keys")
+	itr.Seek(int642Bytes(4))
+	verifyIterator(t, itr, []int64{2}, "reverse seek to key")
+	itr.Seek(int642Bytes(9))
+	verifyIterator(t, itr, []int64{6, 4, 2}, "reverse seek past end")
+	itr.Seek(int642Bytes(2))
+	verifyIterator(t, itr, []int64(nil), "reverse seek to start")
+	require.NoError(t, itr.Close())
+
+	itr, err = db.ReverseIterator(nil, nil)
+	require.NoError(t, err)
+	itr.Seek(int642Bytes(7))
+	verifyIterator(t, itr, []int64{6, 4, 2, 0}, "unbounded reverse seek")
+	require.NoError(t, itr.Close())
+
+	// prefixed iterators seek within the prefix
+	pdb := NewPrefixDB(db, int642Bytes(4))
+	require.NoError(t, pdb.Set([]byte("b"), []byte{}))
+	require.NoError(t, pdb.Set([]byte("d"), []byte{}))
+	pitr, err := pdb.Iterator(nil, nil)
+	require.NoError(t, err)
+	pitr.Seek([]byte("c"))
+	require.True(t, pitr.Valid())
+	require.Equal(t, []byte("d"), pitr.Key())
+	pitr.Seek([]byte("e"))
+	require.False(t, pitr.Valid())
+	require.NoError(t, pitr.Close())
+
+	pitr, err = pdb.ReverseIterator(nil, nil)
+	require.NoError(t, err)
+	pitr.Seek([]byte("c"))
+	require.True(t, pitr.Valid())
+	require.Equal(t, []byte("b"), pitr.Key())
+	pitr.Seek([]byte("b"))
+	require.False(t, pitr.Valid())
+	require.NoError(t, pitr.Close())
+
+	// indexed batch iterators seek over pending writes too
+	batch := db.NewIndexedBatch()
+	defer batch.Close()
+	require.NoError(t, batch.Set(int642Bytes(5), []byte{}))
+	require.NoError(t, batch.Delete(int642Bytes(6)))
+	itr, err = batch.Iterator(nil, nil)
+	require.NoError(t, err)
+	itr.Seek(int642Bytes(5))
+	var keys []int64
+	for ; itr.Valid(); itr.Next() {
+		if len(itr.Key()) == 8 {
+			keys = append(keys, bytes2Int64(itr.Key()))
+		}
+	}
+	require.Equal(t, []int64{5, 8}, keys)
+	require.NoError(t, itr.Close())
+}
+
+func TestDBTypedStats(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBTypedStats(t, dbType)
+		})
+	}
+}
+
+func testDBTypedStats(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), []byte("value")))
+	}
+	if backend == PebbleDBBackend {
+		// pebble estimates the keys of its sstables only
+		require.NoError(t, db.(Compactor).Compact(nil, nil))
+	}
+
+	stats := db.TypedStats()
+	if backend != "prefixdb" {
+		require.Equal(t, backend, stats.Backend)
+	}
+	require.GreaterOrEqual(t, stats.CacheHitRatio, 0.0)
+	require.LessOrEqual(t, stats.CacheHitRatio, 1.0)
+	switch backend {
+	case MemDBBackend, GoLevelDBBackend, PebbleDBBackend:
+		require.EqualValues(t, 100, stats.KeyCountEstimate)
+	}
+	switch backend {
+	case GoLevelDBBackend:
+		itr, err := db.Iterator(nil, nil)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, db.TypedStats().OpenIterators)
+		require.NoError(t, itr.Close())
+	case PebbleDBBackend:
+		// the keys are compacted into sstables, which the iterator opens
+		itr, err := db.Iterator(nil, nil)
+		require.NoError(t, err)
+		require.NotZero(t, db.TypedStats().OpenIterators)
+		require.NoError(t, itr.Close())
+		require.Zero(t, db.TypedStats().OpenIterators)
+	}
+
+	// Stats includes the typed stats, flattened
+	flat := db.Stats()
+	require.Equal(t, string(stats.Backend), flat["stats.backend"])
+	for _, key := range []string{
+		"stats.key_count_estimate", "stats.disk_size", "stats.memtable_size", "stats.cache_hit_ratio",
+		"stats.compaction_debt", "stats.open_iterators", "stats.write_stalls",
+	} {
+		require.Contains(t, flat, key)
+	}
+}
+
+func TestCachedStat(t *testing.T) {
+	var stat cachedStat
+	measured := 0
+	measure := func() uint64 {
+		measured++
+		return uint64(measured)
+	}
+	require.EqualValues(t, 1, stat.get(measure))
+	require.EqualValues(t, 1, stat.get(measure))
+
+	stat.measured = stat.measured.Add(-cachedStatTTL)
+	require.EqualValues(t, 2, stat.get(measure))
+}
+
+func TestDBBackup(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBBackup(t, dbType)
+		})
+	}
+}
+
+func testDBBackup(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), int642Bytes(int64(i*2))))
+	}
+
+	backupName := fmt.Sprintf("test_%x", randStr(12))
+	backupDir := t.TempDir()
+	destDir := filepath.Join(backupDir, backupName+DBFileSuffix)
+	require.NoError(t, db.Backup(destDir))
+	require.Error(t, db.Backup(destDir), "backing up over an existing directory should fail")
+
+	// Writes after the backup are not in it
+	require.NoError(t, db.Set(int642Bytes(1000), []byte("late")))
+	require.NoError(t, db.Delete(int642Bytes(0)))
+	require.NoError(t, db.Close())
+
+	// The test PrefixDB backs up the underlying MemDB, keys and all.
+	restoreBackend, prefix := backend, []byte{}
+	if backend == "prefixdb" {
+		restoreBackend, prefix = MemDBBackend, []byte("test/")
+	}
+	var restored DB
+	if restoreBackend == MemDBBackend {
+		// A MemDB is only restored explicitly; NewDB opens an empty one.
+		empty, err := NewDB(backupName, MemDBBackend, backupDir)
+		require.NoError(t, err)
+		keys, err := empty.EstimateKeys(nil, nil)
+		require.NoError(t, err)
+		require.Zero(t, keys)
+		restored, err = LoadMemDBBackup(destDir)
+		require.NoError(t, err)
+	} else {
+		restored, err = NewDB(backupName, restoreBackend, backupDir)
+		require.NoError(t, err)
+	}
+	defer restored.Close()
+
+	for i := 0; i < 100; i++ {
+		value, err := restored.Get(append(cp(prefix), int642Bytes(int64(i))...))
+		require.NoError(t, err)
+		require.Equal(t, int642Bytes(int64(i*2)), value)
+	}
+	value, err := restored.Get(append(cp(prefix), int642Bytes(1000)...))
+	require.NoError(t, err)
+	require.Nil(t, value)
+}
+
+func TestDBCompact(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBCompact(t, dbType)
+		})
+	}
+}
+
+func testDBCompact(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	defer db.Close()
+
+	c, ok := db.(Compactor)
+	require.True(t, ok, "%T should implement Compactor", db)
+
+	// Compacting an empty database is fine
+	require.NoError(t, c.Compact(nil, nil))
+
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), []byte(randStr(100))))
+	}
+	require.NoError(t, db.DeleteRange(int642Bytes(100), nil))
+
+	require.Equal(t, errKeyEmpty, c.Compact([]byte{}, nil))
+	require.Equal(t, errKeyEmpty, c.Compact(nil, []byte{}))
+	require.NoError(t, c.Compact(int642Bytes(100), int642Bytes(500)))
+	require.NoError(t, c.Compact(int642Bytes(500), nil))
+	require.NoError(t, c.Compact(nil, int642Bytes(50)))
+	require.NoError(t, c.Compact(nil, nil))
+
+	// Compaction does not change the contents
+	itr, err := db.Iterator(nil, nil)
+	require.NoError(t, err)
+	var keys int64
+	for ; itr.Valid(); itr.Next() {
+		require.Equal(t, int642Bytes(keys), itr.Key())
+		keys++
+	}
+	require.NoError(t, itr.Error())
+	require.NoError(t, itr.Close())
+	require.EqualValues(t, 100, keys)
+}
+
+func TestDBReadOnly(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBReadOnly(t, dbType)
+		})
+	}
+}
+
+func testDBReadOnly(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := t.TempDir()
+	caps, ok := BackendCapabilities(backend)
+	require.True(t, ok)
+
+	// Opening a database which does not exist read-only fails.
+	if caps.Persistent {
+		_, err := NewReadOnlyDB(name, backend, dir)
+		require.Error(t, err)
+	}
+
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	require.NoError(t, db.Set([]byte("a"), []byte{1}))
+	require.NoError(t, db.Set([]byte("b"), []byte{2}))
+	require.NoError(t, db.Close())
+
+	_, err = NewDBwithOptions(name, backend, dir, OptionsMap{OptReadOnly: "maybe"})
+	require.Error(t, err)
+
+	db, err = NewReadOnlyDB(name, backend, dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	if caps.Persistent {
+		assertKeyValues(t, db, map[string][]byte{"a": {1}, "b": {2}})
+	}
+
+	require.ErrorIs(t, db.Set([]byte("c"), []byte{3}), ErrReadOnly)
+	require.ErrorIs(t, db.SetSync([]byte("c"), []byte{3}), ErrReadOnly)
+	require.ErrorIs(t, db.Delete([]byte("a")), ErrReadOnly)
+	require.ErrorIs(t, db.DeleteSync([]byte("a")), ErrReadOnly)
+	require.ErrorIs(t, db.DeleteRange(nil, nil), ErrReadOnly)
+	require.ErrorIs(t, db.(Compactor).Compact(nil, nil), ErrReadOnly)
+
+	batch := db.NewBatch()
+	require.ErrorIs(t, batch.Set([]byte("c"), []byte{3}), ErrReadOnly)
+	require.ErrorIs(t, batch.Delete([]byte("a")), ErrReadOnly)
+	require.ErrorIs(t, batch.Write(), ErrReadOnly)
+	require.NoError(t, batch.Close())
+
+	indexed := db.NewIndexedBatch()
+	require.ErrorIs(t, indexed.Set([]byte("c"), []byte{3}), ErrReadOnly)
+	if caps.Persistent {
+		value, err := indexed.Get([]byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, []byte{1}, value)
+	}
+	require.NoError(t, indexed.Close())
+
+	// Reads still work, including through snapshots.
+	snap, err := db.NewSnapshot()
+	require.NoError(t, err)
+	if caps.Persistent {
+		value, err := snap.Get([]byte("b"))
+		require.NoError(t, err)
+		require.Equal(t, []byte{2}, value)
+	}
+	require.NoError(t, snap.Close())
+}
+
+func TestDBGetMany(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBGetMany(t, dbType)
+		})
+	}
+}
+
+func testDBGetMany(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	defer db.Close()
+
+	// Every third key exists, and the keys are asked for in reverse.
+	var keys, want [][]byte
+	for i := 999; i >= 0; i-- {
+		key := int642Bytes(int64(i))
+		keys = append(keys, key)
+		if i%3 == 0 {
+			value := []byte(randStr(1 + i%50))
+			require.NoError(t, db.Set(key, value))
+			want = append(want, value)
+		} else {
+			want = append(want, nil)
+		}
+	}
+
+	values, err := db.GetMany(keys)
+	require.NoError(t, err)
+	require.Len(t, values, len(keys))
+	for i, key := range keys {
+		value, err := db.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, value, values[i])
+		require.Equal(t, want[i] != nil, values[i] != nil, "key %x", key)
+	}
+
+	_, err = db.GetMany([][]byte{int642Bytes(1), {}})
+	require.Equal(t, errKeyEmpty, err)
+}
+
+func TestDBView(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBView(t, dbType)
+		})
+	}
+}
+
+func testDBView(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	defer db.Close()
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), int642Bytes(int64(i*i))))
+	}
+	for i := 0; i < 110; i++ {
+		key := int642Bytes(int64(i))
+		want, err := db.Get(key)
+		require.NoError(t, err)
+		var got []byte
+		require.NoError(t, db.View(key, func(value []byte) error {
+			if value != nil {
+				got = cp(value)
+			}
+			return nil
+		}))
+		require.Equal(t, want, got, "key %d", i)
+	}
+}
+
+func TestDBEstimate(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDBEstimate(t, dbType)
+		})
+	}
+}
+
+func testDBEstimate(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	defer db.Close()
+
+	keys, err := db.EstimateKeys(nil, nil)
+	require.NoError(t, err)
+	require.Zero(t, keys)
+
+	const n = 10000
+	for i := 0; i < n; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), []byte(randStr(100))))
+	}
+	// Flush the writes, which the backends only estimate once they are on disk.
+	require.NoError(t, db.(Compactor).Compact(nil, nil))
+
+	for _, tc := range []struct {
+		start, end []byte
+		keys       int
+	}{
+		{nil, nil, n},
+		{nil, int642Bytes(n / 2), n / 2},
+		{int642Bytes(n / 4), nil, n - n/4},
+		{int642Bytes(n / 4), int642Bytes(n / 2), n / 4},
+	} {
+		keys, err := db.EstimateKeys(tc.start, tc.end)
+		require.NoError(t, err)
+		require.InDelta(t, tc.keys, keys, float64(tc.keys)/2, "keys in [%x, %x)", tc.start, tc.end)
+
+		// Each key and value take up around 100 bytes, before compression.
+		size, err := db.EstimateSize(tc.start, tc.end)
+		require.NoError(t, err)
+		require.Greater(t, size, uint64(tc.keys*20), "size of [%x, %x)", tc.start, tc.end)
+		require.Less(t, size, uint64(tc.keys*500), "size of [%x, %x)", tc.start, tc.end)
+	}
+
+	keys, err = db.EstimateKeys(int642Bytes(n), nil)
+	require.NoError(t, err)
+	require.Zero(t, keys)
+
+	_, err = db.EstimateSize([]byte{}, nil)
+	require.Equal(t, errKeyEmpty, err)
+	_, err = db.EstimateKeys(nil, []byte{})
+	require.Equal(t, errKeyEmpty, err)
+}
+
+func TestEstimateKeysSample(t *testing.T) {
+	db := NewMemDB()
+	const n = 3 * estimateMaxSampleKeys
+	for i := 0; i < n; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), []byte{1}))
+	}
+
+	// keys are extrapolated from the size of the first ones
+	sizeOf := func(start, end []byte) (uint64, error) {
+		keys, _, err := countRange(db, start, end)
+		return keys * 10, err
+	}
+	keys, err := estimateKeys(db, sizeOf, nil, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, n, keys)
+
+	// keys which take up no size on disk yet are extrapolated from their density over the key
+	// space, not scanned
+	unflushed := func(start, end []byte) (uint64, error) { return 0, nil }
+	keys, err = estimateKeys(db, unflushed, nil, nil)
+	require.NoError(t, err)
+	require.InDelta(t, n, keys, n/100)
+	keys, err = estimateKeys(db, unflushed, int642Bytes(n/3), nil)
+	require.NoError(t, err)
+	require.InDelta(t, n-n/3, keys, n/100)
+}
+
+func TestSampleRange(t *testing.T) {
+	db := NewMemDB()
+	const n = 5 * estimateMaxSampleKeys
+	for i := 0; i < n; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(2*i)), make([]byte, 92)))
+	}
+
+	// small ranges are counted exactly
+	keys, size, err := sampleRange(db, nil, int642Bytes(200))
+	require.NoError(t, err)
+	require.EqualValues(t, 100, keys)
+	require.EqualValues(t, 100*100, size)
+
+	// larger ones are extrapolated from a sample
+	keys, size, err = sampleRange(db, int642Bytes(n), nil)
+	require.NoError(t, err)
+	require.InDelta(t, n/2, keys, n/100)
+	require.InDelta(t, n/2*100, size, n)
+}
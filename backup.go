@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+	"os"
+)
+
+// backupBatchSize is the number of bytes buffered before a batch is written while copying a
+// snapshot into a backup.
+const backupBatchSize = 4 << 20
+
+// checkBackupDir errors if destDir already exists, since a backup never overwrites anything.
+func checkBackupDir(destDir string) error {
+	if destDir == "" {
+		return fmt.Errorf("backup directory cannot be empty")
+	}
+	if _, err := os.Stat(destDir); err == nil {
+		return fmt.Errorf("backup directory %s already exists", destDir)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// copySnapshot writes every key in snap to dst, in batches of about backupBatchSize bytes. The
+// last batch is written with WriteSync.
+func copySnapshot(snap Snapshot, dst DB) error {
+	itr, err := snap.Iterator(nil, nil)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+
+	batch := dst.NewBatch()
+	defer func() { _ = batch.Close() }()
+	for ; itr.Valid(); itr.Next() {
+		if err := batch.Set(itr.Key(), itr.Value()); err != nil {
+			return err
+		}
+		size, err := batch.GetByteSize()
+		if err != nil {
+			return err
+		}
+		if size >= backupBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			_ = batch.Close()
+			batch = dst.NewBatch()
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return err
+	}
+	return batch.WriteSync()
+}
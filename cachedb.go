@@ -0,0 +1,403 @@
+package db
+
+import (
+	"container/list"
+	"hash/maphash"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// cacheShards is the number of shards of a CachedDB, each with its own lock and LRU list.
+	cacheShards = 16
+	// cacheEntryOverhead approximates the bytes a cache entry takes up besides its key and value.
+	cacheEntryOverhead = 64
+)
+
+// CachedDB wraps a DB with a read-through LRU cache of values, keyed by key, so that repeated
+// reads of hot keys such as IAVL nodes do not go to the backend each time. Get, GetMany, View,
+// Has and GetAppend are served from the cache, and cache what they read, including keys which do
+// not exist. Set, Delete, DeleteRange and batch writes invalidate the keys they write, so the
+// cache never serves a value older than the last write made through the CachedDB. Writes made to
+// the wrapped DB directly bypass the invalidation, and must not be mixed with cached reads.
+//
+// Cached values are shared between callers, which must not modify them, as the DB contract
+// requires. Iterators and snapshots are served by the wrapped DB.
+type CachedDB struct {
+	db     DB
+	seed   maphash.Seed
+	shards [cacheShards]cacheShard
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+var (
+	_ DB        = (*CachedDB)(nil)
+	_ Compactor = (*CachedDB)(nil)
+)
+
+// CacheStats are the statistics of the cache of a CachedDB.
+type CacheStats struct {
+	// Hits is the number of lookups served from the cache.
+	Hits uint64
+	// Misses is the number of lookups which went to the wrapped DB.
+	Misses uint64
+	// Entries is the number of keys in the cache.
+	Entries uint64
+	// Size is the approximate number of bytes the cache takes up.
+	Size uint64
+}
+
+// cacheShard is an LRU cache of a subset of the keys, selected by hash.
+type cacheShard struct {
+	mtx      sync.Mutex
+	maxBytes int
+	size     int
+	entries  map[string]*list.Element
+	// lru holds the *cacheEntry of each key, most recently used first.
+	lru list.List
+	// gen is incremented by every invalidation, so that a value read from the wrapped DB before
+	// a write is not cached after the write has invalidated its key.
+	gen uint64
+}
+
+type cacheEntry struct {
+	key string
+	// value is nil if the key does not exist.
+	value []byte
+}
+
+// NewCachedDB wraps db with a cache of up to maxBytes bytes of keys and values. A maxBytes of 0
+// caches nothing.
+func NewCachedDB(db DB, maxBytes int) *CachedDB {
+	cdb := &CachedDB{db: db, seed: maphash.MakeSeed()}
+	for i := range cdb.shards {
+		cdb.shards[i].maxBytes = maxBytes / cacheShards
+		cdb.shards[i].entries = make(map[string]*list.Element)
+	}
+	return cdb
+}
+
+// shard returns the shard caching key.
+func (cdb *CachedDB) shard(key []byte) *cacheShard {
+	return &cdb.shards[maphash.Bytes(cdb.seed, key)%cacheShards]
+}
+
+// lookup returns the cached value of key, and whether it was cached. A value is cached as nil if
+// the key does not exist. On a miss, it returns the generation to cache the value read with.
+func (cdb *CachedDB) lookup(key []byte) (value []byte, ok bool, gen uint64) {
+	s := cdb.shard(key)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	elem, ok := s.entries[string(key)]
+	if !ok {
+		cdb.misses.Add(1)
+		return nil, false, s.gen
+	}
+	cdb.hits.Add(1)
+	s.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).value, true, 0
+}
+
+// add caches the value of key, read at generation gen, evicting the least recently used keys
+// to make room for it. It does nothing if the key was invalidated since gen.
+func (cdb *CachedDB) add(key, value []byte, gen uint64) {
+	s := cdb.shard(key)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	cost := len(key) + len(value) + cacheEntryOverhead
+	if s.gen != gen || cost > s.maxBytes {
+		return
+	}
+	if _, ok := s.entries[string(key)]; ok {
+		return
+	}
+	entry := &cacheEntry{key: string(key), value: value}
+	s.entries[entry.key] = s.lru.PushFront(entry)
+	s.size += cost
+	for s.size > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+}
+
+// remove removes an entry from the shard. The caller must hold the lock.
+func (s *cacheShard) remove(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*cacheEntry)
+	delete(s.entries, entry.key)
+	s.size -= len(entry.key) + len(entry.value) + cacheEntryOverhead
+}
+
+// invalidate removes keys from the cache.
+func (cdb *CachedDB) invalidate(keys ...[]byte) {
+	for _, key := range keys {
+		s := cdb.shard(key)
+		s.mtx.Lock()
+		s.gen++
+		if elem, ok := s.entries[string(key)]; ok {
+			s.remove(elem)
+		}
+		s.mtx.Unlock()
+	}
+}
+
+// invalidateRange removes the keys in [start, end) from the cache.
+func (cdb *CachedDB) invalidateRange(start, end []byte) {
+	for i := range cdb.shards {
+		s := &cdb.shards[i]
+		s.mtx.Lock()
+		s.gen++
+		for key, elem := range s.entries {
+			if IsKeyInDomain([]byte(key), start, end) {
+				s.remove(elem)
+			}
+		}
+		s.mtx.Unlock()
+	}
+}
+
+// CacheStats returns the statistics of the cache.
+func (cdb *CachedDB) CacheStats() CacheStats {
+	stats := CacheStats{Hits: cdb.hits.Load(), Misses: cdb.misses.Load()}
+	for i := range cdb.shards {
+		s := &cdb.shards[i]
+		s.mtx.Lock()
+		stats.Entries += uint64(len(s.entries))
+		stats.Size += uint64(s.size)
+		s.mtx.Unlock()
+	}
+	return stats
+}
+
+// Get implements DB.
+func (cdb *CachedDB) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	value, ok, gen := cdb.lookup(key)
+	if ok {
+		return value, nil
+	}
+	value, err := cdb.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	cdb.add(key, value, gen)
+	return value, nil
+}
+
+// GetMany implements DB.
+// The keys which are not cached are read from the wrapped DB with a single GetMany.
+func (cdb *CachedDB) GetMany(keys [][]byte) ([][]byte, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(keys))
+	var missing [][]byte
+	var missingIdx []int
+	var gens []uint64
+	for i, key := range keys {
+		value, ok, gen := cdb.lookup(key)
+		if ok {
+			values[i] = value
+			continue
+		}
+		missing = append(missing, key)
+		missingIdx = append(missingIdx, i)
+		gens = append(gens, gen)
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	fetched, err := cdb.db.GetMany(missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, value := range fetched {
+		cdb.add(missing[j], value, gens[j])
+		values[missingIdx[j]] = value
+	}
+	return values, nil
+}
+
+// View implements DB.
+// Values not cached are read with Get rather than View, so that they can be cached.
+func (cdb *CachedDB) View(key []byte, fn func([]byte) error) error {
+	value, err := cdb.Get(key)
+	if err != nil {
+		return err
+	}
+	return fn(value)
+}
+
+// GetAppend appends the value of the given key to dst[:0], reading it through the cache. Missing
+// keys return (nil, nil).
+func (cdb *CachedDB) GetAppend(key, dst []byte) ([]byte, error) {
+	value, err := cdb.Get(key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return append(dst[:0], value...), nil
+}
+
+// Checkpoint forwards a visibility/durability barrier when the wrapped DB supports it.
+func (cdb *CachedDB) Checkpoint() error {
+	if cp, ok := cdb.db.(checkpointer); ok {
+		return cp.Checkpoint()
+	}
+	return nil
+}
+
+// Has implements DB.
+// Keys not cached are checked with the wrapped DB, without caching their value.
+func (cdb *CachedDB) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, errKeyEmpty
+	}
+	value, ok, _ := cdb.lookup(key)
+	if ok {
+		return value != nil, nil
+	}
+	return cdb.db.Has(key)
+}
+
+// Set implements DB.
+func (cdb *CachedDB) Set(key, value []byte) error {
+	defer cdb.invalidate(key)
+	return cdb.db.Set(key, value)
+}
+
+// SetSync implements DB.
+func (cdb *CachedDB) SetSync(key, value []byte) error {
+	defer cdb.invalidate(key)
+	return cdb.db.SetSync(key, value)
+}
+
+// Delete implements DB.
+func (cdb *CachedDB) Delete(key []byte) error {
+	defer cdb.invalidate(key)
+	return cdb.db.Delete(key)
+}
+
+// DeleteSync implements DB.
+func (cdb *CachedDB) DeleteSync(key []byte) error {
+	defer cdb.invalidate(key)
+	return cdb.db.DeleteSync(key)
+}
+
+// DeleteRange implements DB.
+func (cdb *CachedDB) DeleteRange(start, end []byte) error {
+	defer cdb.invalidateRange(start, end)
+	return cdb.db.DeleteRange(start, end)
+}
+
+// Iterator implements DB.
+func (cdb *CachedDB) Iterator(start, end []byte) (Iterator, error) {
+	return cdb.db.Iterator(start, end)
+}
+
+// ReverseIterator implements DB.
+func (cdb *CachedDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	return cdb.db.ReverseIterator(start, end)
+}
+
+// Close implements DB.
+func (cdb *CachedDB) Close() error {
+	return cdb.db.Close()
+}
+
+// NewBatch implements DB.
+func (cdb *CachedDB) NewBatch() Batch {
+	return newCachedDBBatch(cdb.db.NewBatch(), cdb)
+}
+
+// NewBatchWithSize implements DB.
+func (cdb *CachedDB) NewBatchWithSize(size int) Batch {
+	return newCachedDBBatch(cdb.db.NewBatchWithSize(size), cdb)
+}
+
+// NewIndexedBatch implements DB.
+// Reads from the batch go to the wrapped DB, and are not cached.
+func (cdb *CachedDB) NewIndexedBatch() IndexedBatch {
+	source := cdb.db.NewIndexedBatch()
+	return &cachedDBIndexedBatch{
+		cachedDBBatch: newCachedDBBatch(source, cdb),
+		source:        source,
+	}
+}
+
+// NewSnapshot implements DB.
+func (cdb *CachedDB) NewSnapshot() (Snapshot, error) {
+	return cdb.db.NewSnapshot()
+}
+
+// Backup implements DB.
+func (cdb *CachedDB) Backup(destDir string) error {
+	return cdb.db.Backup(destDir)
+}
+
+// EstimateSize implements DB.
+func (cdb *CachedDB) EstimateSize(start, end []byte) (uint64, error) {
+	return cdb.db.EstimateSize(start, end)
+}
+
+// EstimateKeys implements DB.
+func (cdb *CachedDB) EstimateKeys(start, end []byte) (uint64, error) {
+	return cdb.db.EstimateKeys(start, end)
+}
+
+// Compact implements Compactor.
+// It is a noop if the wrapped DB is not a Compactor.
+func (cdb *CachedDB) Compact(start, end []byte) error {
+	if c, ok := cdb.db.(Compactor); ok {
+		return c.Compact(start, end)
+	}
+	return nil
+}
+
+// Print implements DB.
+func (cdb *CachedDB) Print() error {
+	return cdb.db.Print()
+}
+
+// Stats implements DB.
+// It adds the CacheStats of the cache to the stats of the wrapped DB, under the "cache." prefix.
+func (cdb *CachedDB) Stats() map[string]string {
+	stats := cdb.db.Stats()
+	if stats == nil {
+		stats = make(map[string]string)
+	}
+	cache := cdb.CacheStats()
+	stats["cache.hits"] = strconv.FormatUint(cache.Hits, 10)
+	stats["cache.misses"] = strconv.FormatUint(cache.Misses, 10)
+	stats["cache.entries"] = strconv.FormatUint(cache.Entries, 10)
+	stats["cache.size"] = strconv.FormatUint(cache.Size, 10)
+	return stats
+}
+
+// TypedStats implements DB.
+func (cdb *CachedDB) TypedStats() DBStats {
+	return cdb.db.TypedStats()
+}
+
+// backendType implements backendTyper.
+func (cdb *CachedDB) backendType() BackendType {
+	return backendOf(cdb.db)
+}
+
+// nativeMetrics implements nativeMetricer.
+func (cdb *CachedDB) nativeMetrics() []nativeMetric {
+	cache := cdb.CacheStats()
+	metrics := []nativeMetric{
+		{name: "cachedb_hits_total", help: "Number of lookups served from the cache.", value: float64(cache.Hits), counter: true},
+		{name: "cachedb_misses_total", help: "Number of lookups which missed the cache.", value: float64(cache.Misses), counter: true},
+		{name: "cachedb_entries", help: "Number of keys in the cache.", value: float64(cache.Entries)},
+		{name: "cachedb_size_bytes", help: "Approximate size of the cache.", value: float64(cache.Size)},
+	}
+	if source, ok := cdb.db.(nativeMetricer); ok {
+		metrics = append(metrics, source.nativeMetrics()...)
+	}
+	return metrics
+}
@@ -0,0 +1,108 @@
+package db
+
+import "bytes"
+
+type cachedDBBatch struct {
+	source Batch
+	cdb    *CachedDB
+	// keys and ranges are the keys and domains written by the batch, invalidated once it is
+	// written.
+	keys   [][]byte
+	ranges [][2][]byte
+}
+
+var _ Batch = (*cachedDBBatch)(nil)
+
+func newCachedDBBatch(source Batch, cdb *CachedDB) *cachedDBBatch {
+	return &cachedDBBatch{
+		source: source,
+		cdb:    cdb,
+	}
+}
+
+// Set implements Batch.
+func (b *cachedDBBatch) Set(key, value []byte) error {
+	if err := b.source.Set(key, value); err != nil {
+		return err
+	}
+	b.keys = append(b.keys, cp(key))
+	return nil
+}
+
+// Delete implements Batch.
+func (b *cachedDBBatch) Delete(key []byte) error {
+	if err := b.source.Delete(key); err != nil {
+		return err
+	}
+	b.keys = append(b.keys, cp(key))
+	return nil
+}
+
+// DeleteRange implements Batch.
+func (b *cachedDBBatch) DeleteRange(start, end []byte) error {
+	if err := b.source.DeleteRange(start, end); err != nil {
+		return err
+	}
+	b.ranges = append(b.ranges, [2][]byte{bytes.Clone(start), bytes.Clone(end)})
+	return nil
+}
+
+// Write implements Batch.
+func (b *cachedDBBatch) Write() error {
+	defer b.invalidate()
+	return b.source.Write()
+}
+
+// WriteSync implements Batch.
+func (b *cachedDBBatch) WriteSync() error {
+	defer b.invalidate()
+	return b.source.WriteSync()
+}
+
+// invalidate removes the keys written by the batch from the cache. It runs whether or not the
+// write succeeded, since a failed write may still have reached the wrapped DB.
+func (b *cachedDBBatch) invalidate() {
+	b.cdb.invalidate(b.keys...)
+	for _, r := range b.ranges {
+		b.cdb.invalidateRange(r[0], r[1])
+	}
+	b.keys, b.ranges = nil, nil
+}
+
+// Close implements Batch.
+func (b *cachedDBBatch) Close() error {
+	b.keys, b.ranges = nil, nil
+	return b.source.Close()
+}
+
+// GetByteSize implements Batch.
+func (b *cachedDBBatch) GetByteSize() (int, error) {
+	return b.source.GetByteSize()
+}
+
+type cachedDBIndexedBatch struct {
+	*cachedDBBatch
+	source IndexedBatch
+}
+
+var _ IndexedBatch = (*cachedDBIndexedBatch)(nil)
+
+// Get implements IndexedBatch.
+func (b *cachedDBIndexedBatch) Get(key []byte) ([]byte, error) {
+	return b.source.Get(key)
+}
+
+// Has implements IndexedBatch.
+func (b *cachedDBIndexedBatch) Has(key []byte) (bool, error) {
+	return b.source.Has(key)
+}
+
+// Iterator implements IndexedBatch.
+func (b *cachedDBIndexedBatch) Iterator(start, end []byte) (Iterator, error) {
+	return b.source.Iterator(start, end)
+}
+
+// ReverseIterator implements IndexedBatch.
+func (b *cachedDBIndexedBatch) ReverseIterator(start, end []byte) (Iterator, error) {
+	return b.source.ReverseIterator(start, end)
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedDB(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testCachedDB(t, dbType)
+		})
+	}
+}
+
+func testCachedDB(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	source, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	db := NewCachedDB(source, 1<<20)
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), int642Bytes(int64(i))))
+	}
+
+	// Reads miss once, then hit, including for keys which do not exist.
+	for round := 0; round < 2; round++ {
+		value, err := db.Get(int642Bytes(1))
+		require.NoError(t, err)
+		require.Equal(t, int642Bytes(1), value)
+		value, err = db.Get(int642Bytes(100))
+		require.NoError(t, err)
+		require.Nil(t, value)
+	}
+	ok, err := db.Has(int642Bytes(100))
+	require.NoError(t, err)
+	require.False(t, ok)
+	values, err := db.GetMany([][]byte{int642Bytes(1), int642Bytes(2), int642Bytes(2)})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{int642Bytes(1), int642Bytes(2), int642Bytes(2)}, values)
+	stats := db.CacheStats()
+	require.EqualValues(t, 4, stats.Hits)
+	require.EqualValues(t, 4, stats.Misses)
+	require.EqualValues(t, 3, stats.Entries)
+	require.Equal(t, "4", db.Stats()["cache.hits"])
+
+	requireCached := func(key, expect []byte) {
+		t.Helper()
+		value, err := db.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, expect, value)
+		require.NoError(t, db.View(key, func(value []byte) error {
+			require.Equal(t, expect, value)
+			return nil
+		}))
+		value, err = source.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, expect, value)
+	}
+
+	// Writes invalidate the keys they write, directly or through batches.
+	require.NoError(t, db.Set(int642Bytes(1), []byte{1}))
+	requireCached(int642Bytes(1), []byte{1})
+	require.NoError(t, db.Delete(int642Bytes(1)))
+	requireCached(int642Bytes(1), nil)
+	require.NoError(t, db.SetSync(int642Bytes(100), []byte{2}))
+	requireCached(int642Bytes(100), []byte{2})
+
+	batch := db.NewBatch()
+	require.NoError(t, batch.Set(int642Bytes(2), []byte{3}))
+	require.NoError(t, batch.Delete(int642Bytes(100)))
+	requireCached(int642Bytes(2), int642Bytes(2))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	requireCached(int642Bytes(2), []byte{3})
+	requireCached(int642Bytes(100), nil)
+
+	for i := 3; i < 10; i++ {
+		requireCached(int642Bytes(int64(i)), int642Bytes(int64(i)))
+	}
+	require.NoError(t, db.DeleteRange(int642Bytes(3), int642Bytes(5)))
+	requireCached(int642Bytes(3), nil)
+	requireCached(int642Bytes(4), nil)
+	requireCached(int642Bytes(5), int642Bytes(5))
+
+	batch = db.NewBatch()
+	require.NoError(t, batch.DeleteRange(int642Bytes(6), nil))
+	require.NoError(t, batch.WriteSync())
+	require.NoError(t, batch.Close())
+	for i := 6; i < 10; i++ {
+		requireCached(int642Bytes(int64(i)), nil)
+	}
+}
+
+func TestCachedDBEviction(t *testing.T) {
+	db := NewCachedDB(NewMemDB(), cacheShards*1024)
+	value := make([]byte, 100)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), value))
+		_, err := db.Get(int642Bytes(int64(i)))
+		require.NoError(t, err)
+	}
+	stats := db.CacheStats()
+	require.LessOrEqual(t, stats.Size, uint64(cacheShards*1024))
+	require.Greater(t, stats.Entries, uint64(0))
+	require.Less(t, stats.Entries, uint64(1000))
+
+	// Recently read keys stay cached.
+	hits := db.CacheStats().Hits
+	_, err := db.Get(int642Bytes(999))
+	require.NoError(t, err)
+	require.Equal(t, hits+1, db.CacheStats().Hits)
+
+	// A cache of 0 bytes caches nothing.
+	db = NewCachedDB(NewMemDB(), 0)
+	require.NoError(t, db.Set([]byte("a"), []byte{1}))
+	for i := 0; i < 2; i++ {
+		_, err := db.Get([]byte("a"))
+		require.NoError(t, err)
+	}
+	require.Zero(t, db.CacheStats().Hits)
+}
+
+func TestCachedDBConcurrent(t *testing.T) {
+	db := NewCachedDB(NewMemDB(), 1<<20)
+	const writers, writes = 4, 500
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				require.NoError(t, db.Set(int642Bytes(int64(i%10)), int642Bytes(int64(w*writes+i))))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				_, err := db.Get(int642Bytes(int64(i % 10)))
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Reads racing writes never leave a stale value cached.
+	for i := 0; i < 10; i++ {
+		cached, err := db.Get(int642Bytes(int64(i)))
+		require.NoError(t, err)
+		stored, err := db.db.Get(int642Bytes(int64(i)))
+		require.NoError(t, err)
+		require.Equal(t, stored, cached)
+	}
+}
+
+// pausingDB is a DB whose Get pauses after reading, until resumed.
+type pausingDB struct {
+	DB
+	read, resume chan struct{}
+}
+
+func (db pausingDB) Get(key []byte) ([]byte, error) {
+	value, err := db.DB.Get(key)
+	db.read <- struct{}{}
+	<-db.resume
+	return value, err
+}
+
+func TestCachedDBStaleRead(t *testing.T) {
+	source := NewMemDB()
+	require.NoError(t, source.Set([]byte("key"), []byte("old")))
+	pausing := pausingDB{DB: source, read: make(chan struct{}), resume: make(chan struct{})}
+	db := NewCachedDB(pausing, 1<<20)
+
+	// A read of the old value, completing after a write of a new one, does not cache it.
+	done := make(chan []byte)
+	go func() {
+		value, err := db.Get([]byte("key"))
+		require.NoError(t, err)
+		done <- value
+	}()
+	<-pausing.read
+	require.NoError(t, db.Set([]byte("key"), []byte("new")))
+	close(pausing.resume)
+	require.Equal(t, []byte("old"), <-done)
+
+	go func() { <-pausing.read }()
+	value, err := db.Get([]byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), value)
+}
@@ -0,0 +1,351 @@
+package db
+
+import (
+	"bytes"
+	"sync"
+)
+
+// defaultChangeFeedBuffer is the number of events buffered for each subscriber by default.
+const defaultChangeFeedBuffer = 256
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	// ChangeSet sets Key to Value.
+	ChangeSet ChangeKind = iota + 1
+	// ChangeDelete deletes Key.
+	ChangeDelete
+	// ChangeDeleteRange deletes the keys in the domain [Key, End). A nil End is unbounded.
+	ChangeDeleteRange
+)
+
+// Change is a single write within a ChangeEvent.
+type Change struct {
+	Kind  ChangeKind
+	Key   []byte
+	Value []byte
+	End   []byte
+}
+
+// ChangeEvent is the set of changes committed by a single write: one change for Set or Delete,
+// and every change of a batch, in the order they were made, for Batch.Write. Only the changes
+// under the prefix of the subscription are included, with range deletes clipped to the prefix.
+// Keys are not stripped of the prefix. Events, shared between subscribers, must not be modified.
+type ChangeEvent struct {
+	Changes []Change
+	// Dropped is the number of events dropped for the subscriber just before this one, under
+	// ChangeFeedDrop.
+	Dropped uint64
+}
+
+// ChangeFeedPolicy decides what a ChangeFeedDB does with an event for a subscriber whose buffer
+// is full.
+type ChangeFeedPolicy int
+
+const (
+	// ChangeFeedBlock blocks the write until the subscriber has room for the event, slowing
+	// writers down to the pace of the slowest subscriber. A subscriber must not write to the
+	// database from the loop receiving its events: once its buffer is full, its own write would
+	// wait for it forever.
+	ChangeFeedBlock ChangeFeedPolicy = iota
+	// ChangeFeedDrop drops the event, and counts it in the Dropped field of the next event
+	// delivered to the subscriber, which then needs to resync from the database.
+	ChangeFeedDrop
+)
+
+// ChangeFeedOptions configures a ChangeFeedDB.
+type ChangeFeedOptions struct {
+	// Buffer is the number of events buffered for each subscriber. Defaults to 256.
+	Buffer int
+	// Policy applies to subscribers whose buffer is full. Defaults to ChangeFeedBlock.
+	Policy ChangeFeedPolicy
+}
+
+// ChangeFeedDB wraps a DB and publishes the writes made through it to subscribers, once they
+// have been committed. Writes are serialized so that events are delivered in commit order. Writes
+// made to the wrapped DB directly are not published.
+type ChangeFeedDB struct {
+	db   DB
+	opts ChangeFeedOptions
+	// mtx serializes writes with their publication, and guards subs.
+	mtx  sync.Mutex
+	subs map[*changeFeedSub]struct{}
+	// closed is closed by Close, releasing the writes blocked on full subscribers.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var (
+	_ DB        = (*ChangeFeedDB)(nil)
+	_ Compactor = (*ChangeFeedDB)(nil)
+)
+
+// changeFeedSub is a subscription to a ChangeFeedDB.
+type changeFeedSub struct {
+	prefix  []byte
+	ch      chan ChangeEvent
+	done    chan struct{}
+	once    sync.Once
+	dropped uint64
+}
+
+// NewChangeFeedDB wraps db, publishing its writes to subscribers.
+func NewChangeFeedDB(db DB, opts ChangeFeedOptions) *ChangeFeedDB {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultChangeFeedBuffer
+	}
+	return &ChangeFeedDB{
+		db:     db,
+		opts:   opts,
+		subs:   make(map[*changeFeedSub]struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+// Subscribe returns a channel of the events committed after it returns, limited to keys starting
+// with prefix, or all keys if it is empty. Calling cancel ends the subscription and closes the
+// channel; closing the database closes it too, and a channel subscribed after Close is closed.
+func (cfdb *ChangeFeedDB) Subscribe(prefix []byte) (events <-chan ChangeEvent, cancel func()) {
+	sub := &changeFeedSub{
+		prefix: cp(prefix),
+		ch:     make(chan ChangeEvent, cfdb.opts.Buffer),
+		done:   make(chan struct{}),
+	}
+	cancel = func() { cfdb.unsubscribe(sub) }
+	cfdb.mtx.Lock()
+	defer cfdb.mtx.Unlock()
+
+	select {
+	case <-cfdb.closed:
+		// Close has already ended the subscriptions.
+		sub.once.Do(func() {
+			close(sub.done)
+			close(sub.ch)
+		})
+	default:
+		cfdb.subs[sub] = struct{}{}
+	}
+	return sub.ch, cancel
+}
+
+// unsubscribe ends a subscription. It first releases any write blocked on the subscriber, which
+// holds the lock.
+func (cfdb *ChangeFeedDB) unsubscribe(sub *changeFeedSub) {
+	sub.once.Do(func() {
+		close(sub.done)
+		cfdb.mtx.Lock()
+		delete(cfdb.subs, sub)
+		cfdb.mtx.Unlock()
+		close(sub.ch)
+	})
+}
+
+// write runs fn, and publishes changes once it succeeds. The caller must not hold the lock.
+func (cfdb *ChangeFeedDB) write(changes []Change, fn func() error) error {
+	cfdb.mtx.Lock()
+	defer cfdb.mtx.Unlock()
+
+	if err := fn(); err != nil {
+		return err
+	}
+	for sub := range cfdb.subs {
+		cfdb.publish(sub, changes)
+	}
+	return nil
+}
+
+// publish delivers the changes under the prefix of sub, if any, following the policy. The caller
+// must hold the lock.
+func (cfdb *ChangeFeedDB) publish(sub *changeFeedSub, changes []Change) {
+	event := ChangeEvent{Changes: filterChanges(changes, sub.prefix), Dropped: sub.dropped}
+	if len(event.Changes) == 0 {
+		return
+	}
+	if cfdb.opts.Policy == ChangeFeedDrop {
+		select {
+		case sub.ch <- event:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+		return
+	}
+	select {
+	case sub.ch <- event:
+	case <-sub.done:
+	case <-cfdb.closed:
+	}
+}
+
+// filterChanges returns the changes under prefix, clipping range deletes to it.
+func filterChanges(changes []Change, prefix []byte) []Change {
+	if len(prefix) == 0 {
+		return changes
+	}
+	var filtered []Change
+	for _, change := range changes {
+		if change.Kind != ChangeDeleteRange {
+			if bytes.HasPrefix(change.Key, prefix) {
+				filtered = append(filtered, change)
+			}
+			continue
+		}
+		start, end := prefixDomain(prefix)
+		if change.Key != nil && bytes.Compare(change.Key, start) > 0 {
+			start = change.Key
+		}
+		if change.End != nil && (end == nil || bytes.Compare(change.End, end) < 0) {
+			end = change.End
+		}
+		if end == nil || bytes.Compare(start, end) < 0 {
+			filtered = append(filtered, Change{Kind: ChangeDeleteRange, Key: start, End: end})
+		}
+	}
+	return filtered
+}
+
+// Get implements DB.
+func (cfdb *ChangeFeedDB) Get(key []byte) ([]byte, error) {
+	return cfdb.db.Get(key)
+}
+
+// GetMany implements DB.
+func (cfdb *ChangeFeedDB) GetMany(keys [][]byte) ([][]byte, error) {
+	return cfdb.db.GetMany(keys)
+}
+
+// View implements DB.
+func (cfdb *ChangeFeedDB) View(key []byte, fn func([]byte) error) error {
+	return cfdb.db.View(key, fn)
+}
+
+// Has implements DB.
+func (cfdb *ChangeFeedDB) Has(key []byte) (bool, error) {
+	return cfdb.db.Has(key)
+}
+
+// Set implements DB.
+func (cfdb *ChangeFeedDB) Set(key, value []byte) error {
+	changes := []Change{{Kind: ChangeSet, Key: cp(key), Value: cp(value)}}
+	return cfdb.write(changes, func() error { return cfdb.db.Set(key, value) })
+}
+
+// SetSync implements DB.
+func (cfdb *ChangeFeedDB) SetSync(key, value []byte) error {
+	changes := []Change{{Kind: ChangeSet, Key: cp(key), Value: cp(value)}}
+	return cfdb.write(changes, func() error { return cfdb.db.SetSync(key, value) })
+}
+
+// Delete implements DB.
+func (cfdb *ChangeFeedDB) Delete(key []byte) error {
+	changes := []Change{{Kind: ChangeDelete, Key: cp(key)}}
+	return cfdb.write(changes, func() error { return cfdb.db.Delete(key) })
+}
+
+// DeleteSync implements DB.
+func (cfdb *ChangeFeedDB) DeleteSync(key []byte) error {
+	changes := []Change{{Kind: ChangeDelete, Key: cp(key)}}
+	return cfdb.write(changes, func() error { return cfdb.db.DeleteSync(key) })
+}
+
+// DeleteRange implements DB.
+// It is published as a single range delete, not as the keys it deleted.
+func (cfdb *ChangeFeedDB) DeleteRange(start, end []byte) error {
+	changes := []Change{{Kind: ChangeDeleteRange, Key: bytes.Clone(start), End: bytes.Clone(end)}}
+	return cfdb.write(changes, func() error { return cfdb.db.DeleteRange(start, end) })
+}
+
+// Iterator implements DB.
+func (cfdb *ChangeFeedDB) Iterator(start, end []byte) (Iterator, error) {
+	return cfdb.db.Iterator(start, end)
+}
+
+// ReverseIterator implements DB.
+func (cfdb *ChangeFeedDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	return cfdb.db.ReverseIterator(start, end)
+}
+
+// Close implements DB.
+// It also ends every subscription, first releasing any write blocked on a full subscriber.
+func (cfdb *ChangeFeedDB) Close() error {
+	cfdb.closeOnce.Do(func() { close(cfdb.closed) })
+	cfdb.mtx.Lock()
+	subs := make([]*changeFeedSub, 0, len(cfdb.subs))
+	for sub := range cfdb.subs {
+		subs = append(subs, sub)
+	}
+	cfdb.mtx.Unlock()
+	for _, sub := range subs {
+		cfdb.unsubscribe(sub)
+	}
+	return cfdb.db.Close()
+}
+
+// NewBatch implements DB.
+func (cfdb *ChangeFeedDB) NewBatch() Batch {
+	return newChangeFeedDBBatch(cfdb.db.NewBatch(), cfdb)
+}
+
+// NewBatchWithSize implements DB.
+func (cfdb *ChangeFeedDB) NewBatchWithSize(size int) Batch {
+	return newChangeFeedDBBatch(cfdb.db.NewBatchWithSize(size), cfdb)
+}
+
+// NewIndexedBatch implements DB.
+func (cfdb *ChangeFeedDB) NewIndexedBatch() IndexedBatch {
+	source := cfdb.db.NewIndexedBatch()
+	return &changeFeedDBIndexedBatch{
+		changeFeedDBBatch: newChangeFeedDBBatch(source, cfdb),
+		source:            source,
+	}
+}
+
+// NewSnapshot implements DB.
+func (cfdb *ChangeFeedDB) NewSnapshot() (Snapshot, error) {
+	return cfdb.db.NewSnapshot()
+}
+
+// Backup implements DB.
+func (cfdb *ChangeFeedDB) Backup(destDir string) error {
+	return cfdb.db.Backup(destDir)
+}
+
+// EstimateSize implements DB.
+func (cfdb *ChangeFeedDB) EstimateSize(start, end []byte) (uint64, error) {
+	return cfdb.db.EstimateSize(start, end)
+}
+
+// EstimateKeys implements DB.
+func (cfdb *ChangeFeedDB) EstimateKeys(start, end []byte) (uint64, error) {
+	return cfdb.db.EstimateKeys(start, end)
+}
+
+// Compact implements Compactor.
+// It is a noop if the wrapped DB is not a Compactor.
+func (cfdb *ChangeFeedDB) Compact(start, end []byte) error {
+	if c, ok := cfdb.db.(Compactor); ok {
+		return c.Compact(start, end)
+	}
+	return nil
+}
+
+// Print implements DB.
+func (cfdb *ChangeFeedDB) Print() error {
+	return cfdb.db.Print()
+}
+
+// Stats implements DB.
+func (cfdb *ChangeFeedDB) Stats() map[string]string {
+	return cfdb.db.Stats()
+}
+
+// TypedStats implements DB.
+func (cfdb *ChangeFeedDB) TypedStats() DBStats {
+	return cfdb.db.TypedStats()
+}
+
+// backendType implements backendTyper.
+func (cfdb *ChangeFeedDB) backendType() BackendType {
+	return backendOf(cfdb.db)
+}
@@ -0,0 +1,103 @@
+package db
+
+import "bytes"
+
+type changeFeedDBBatch struct {
+	source Batch
+	cfdb   *ChangeFeedDB
+	// changes are the changes made by the batch, published once it is written.
+	changes []Change
+}
+
+var _ Batch = (*changeFeedDBBatch)(nil)
+
+func newChangeFeedDBBatch(source Batch, cfdb *ChangeFeedDB) *changeFeedDBBatch {
+	return &changeFeedDBBatch{
+		source: source,
+		cfdb:   cfdb,
+	}
+}
+
+// Set implements Batch.
+func (b *changeFeedDBBatch) Set(key, value []byte) error {
+	if err := b.source.Set(key, value); err != nil {
+		return err
+	}
+	b.changes = append(b.changes, Change{Kind: ChangeSet, Key: cp(key), Value: cp(value)})
+	return nil
+}
+
+// Delete implements Batch.
+func (b *changeFeedDBBatch) Delete(key []byte) error {
+	if err := b.source.Delete(key); err != nil {
+		return err
+	}
+	b.changes = append(b.changes, Change{Kind: ChangeDelete, Key: cp(key)})
+	return nil
+}
+
+// DeleteRange implements Batch.
+func (b *changeFeedDBBatch) DeleteRange(start, end []byte) error {
+	if err := b.source.DeleteRange(start, end); err != nil {
+		return err
+	}
+	b.changes = append(b.changes, Change{Kind: ChangeDeleteRange, Key: bytes.Clone(start), End: bytes.Clone(end)})
+	return nil
+}
+
+// Write implements Batch.
+func (b *changeFeedDBBatch) Write() error {
+	return b.write(b.source.Write)
+}
+
+// WriteSync implements Batch.
+func (b *changeFeedDBBatch) WriteSync() error {
+	return b.write(b.source.WriteSync)
+}
+
+// write writes the batch with fn, publishing its changes as a single event.
+func (b *changeFeedDBBatch) write(fn func() error) error {
+	if err := b.cfdb.write(b.changes, fn); err != nil {
+		return err
+	}
+	b.changes = nil
+	return nil
+}
+
+// Close implements Batch.
+func (b *changeFeedDBBatch) Close() error {
+	b.changes = nil
+	return b.source.Close()
+}
+
+// GetByteSize implements Batch.
+func (b *changeFeedDBBatch) GetByteSize() (int, error) {
+	return b.source.GetByteSize()
+}
+
+type changeFeedDBIndexedBatch struct {
+	*changeFeedDBBatch
+	source IndexedBatch
+}
+
+var _ IndexedBatch = (*changeFeedDBIndexedBatch)(nil)
+
+// Get implements IndexedBatch.
+func (b *changeFeedDBIndexedBatch) Get(key []byte) ([]byte, error) {
+	return b.source.Get(key)
+}
+
+// Has implements IndexedBatch.
+func (b *changeFeedDBIndexedBatch) Has(key []byte) (bool, error) {
+	return b.source.Has(key)
+}
+
+// Iterator implements IndexedBatch.
+func (b *changeFeedDBIndexedBatch) Iterator(start, end []byte) (Iterator, error) {
+	return b.source.Iterator(start, end)
+}
+
+// ReverseIterator implements IndexedBatch.
+func (b *changeFeedDBIndexedBatch) ReverseIterator(start, end []byte) (Iterator, error) {
+	return b.source.ReverseIterator(start, end)
+}
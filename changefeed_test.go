@@ -0,0 +1,171 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeedDB(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testChangeFeedDB(t, dbType)
+		})
+	}
+}
+
+func testChangeFeedDB(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	source, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	db := NewChangeFeedDB(source, ChangeFeedOptions{})
+
+	all, cancelAll := db.Subscribe(nil)
+	defer cancelAll()
+	prefixed, cancelPrefixed := db.Subscribe([]byte("b/"))
+
+	require.NoError(t, db.Set([]byte("a/1"), []byte{1}))
+	require.NoError(t, db.DeleteSync([]byte("b/1")))
+	require.Error(t, db.Set(nil, []byte{1}))
+
+	batch := db.NewBatch()
+	require.NoError(t, batch.Set([]byte("a/2"), []byte{2}))
+	require.NoError(t, batch.Set([]byte("b/2"), []byte{3}))
+	require.NoError(t, batch.DeleteRange([]byte("a/"), []byte("c/")))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+
+	// Failed writes and unwritten batches are not published.
+	batch = db.NewBatch()
+	require.NoError(t, batch.Set([]byte("b/3"), []byte{4}))
+	require.NoError(t, batch.Close())
+
+	require.Equal(t, []ChangeEvent{
+		{Changes: []Change{{Kind: ChangeSet, Key: []byte("a/1"), Value: []byte{1}}}},
+		{Changes: []Change{{Kind: ChangeDelete, Key: []byte("b/1")}}},
+		{Changes: []Change{
+			{Kind: ChangeSet, Key: []byte("a/2"), Value: []byte{2}},
+			{Kind: ChangeSet, Key: []byte("b/2"), Value: []byte{3}},
+			{Kind: ChangeDeleteRange, Key: []byte("a/"), End: []byte("c/")},
+		}},
+	}, receiveEvents(t, all, 3))
+
+	// A subscriber sees its part of a batch as a single event, with range deletes clipped.
+	require.Equal(t, []ChangeEvent{
+		{Changes: []Change{{Kind: ChangeDelete, Key: []byte("b/1")}}},
+		{Changes: []Change{
+			{Kind: ChangeSet, Key: []byte("b/2"), Value: []byte{3}},
+			{Kind: ChangeDeleteRange, Key: []byte("b/"), End: []byte("b0")},
+		}},
+	}, receiveEvents(t, prefixed, 2))
+
+	// Cancelled subscriptions are closed, and closing the database closes the others.
+	cancelPrefixed()
+	cancelPrefixed()
+	require.NoError(t, db.Set([]byte("b/4"), []byte{5}))
+	_, ok := <-prefixed
+	require.False(t, ok)
+	require.Len(t, receiveEvents(t, all, 1), 1)
+
+	require.NoError(t, db.Close())
+	_, ok = <-all
+	require.False(t, ok)
+}
+
+// receiveEvents receives n events from events, and requires no more to be pending.
+func receiveEvents(t *testing.T, events <-chan ChangeEvent, n int) []ChangeEvent {
+	t.Helper()
+
+	received := make([]ChangeEvent, 0, n)
+	for len(received) < n {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for events", "received %d of %d", len(received), n)
+		}
+	}
+	select {
+	case event, ok := <-events:
+		if ok {
+			require.FailNow(t, "unexpected event", "%+v", event)
+		}
+	default:
+	}
+	return received
+}
+
+func TestChangeFeedDBPolicies(t *testing.T) {
+	// Under ChangeFeedDrop, writes go on and the subscriber learns how many events it missed.
+	db := NewChangeFeedDB(NewMemDB(), ChangeFeedOptions{Buffer: 2, Policy: ChangeFeedDrop})
+	events, cancel := db.Subscribe(nil)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), []byte{1}))
+	}
+	received := receiveEvents(t, events, 2)
+	require.Zero(t, received[0].Dropped+received[1].Dropped)
+	require.NoError(t, db.Set([]byte("last"), []byte{1}))
+	last := receiveEvents(t, events, 1)[0]
+	require.EqualValues(t, 3, last.Dropped)
+	require.Equal(t, []byte("last"), last.Changes[0].Key)
+	cancel()
+
+	// Under ChangeFeedBlock, writes wait for the subscriber, and cancelling it releases them.
+	db = NewChangeFeedDB(NewMemDB(), ChangeFeedOptions{Buffer: 1})
+	events, cancel = db.Subscribe(nil)
+	require.NoError(t, db.Set([]byte("a"), []byte{1}))
+	written := make(chan error)
+	go func() { written <- db.Set([]byte("b"), []byte{1}) }()
+	select {
+	case <-written:
+		require.FailNow(t, "write did not block on a full subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Equal(t, []byte("a"), (<-events).Changes[0].Key)
+	require.NoError(t, <-written)
+	require.Equal(t, []byte("b"), (<-events).Changes[0].Key)
+
+	go func() {
+		written <- db.Set([]byte("c"), []byte{1})
+		written <- db.Set([]byte("d"), []byte{1})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-written)
+	require.NoError(t, <-written)
+}
+
+func TestChangeFeedDBCloseBlocked(t *testing.T) {
+	// Close releases a write blocked on a subscriber which never receives its events.
+	db := NewChangeFeedDB(NewMemDB(), ChangeFeedOptions{Buffer: 1})
+	events, _ := db.Subscribe(nil)
+	require.NoError(t, db.Set([]byte("a"), []byte{1}))
+	written := make(chan error)
+	go func() { written <- db.Set([]byte("b"), []byte{1}) }()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() { closed <- db.Close() }()
+	for _, ch := range []chan error{written, closed} {
+		select {
+		case err := <-ch:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Close deadlocked with a blocked write")
+		}
+	}
+	require.Len(t, receiveEvents(t, events, 1), 1)
+	_, ok := <-events
+	require.False(t, ok)
+
+	events, _ = db.Subscribe(nil)
+	_, ok = <-events
+	require.False(t, ok)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// diffValueBytes is the number of bytes of each value printed by diff.
+const diffValueBytes = 32
+
+// diffConfig is the configuration of a diff.
+type diffConfig struct {
+	backendA, backendB dbm.BackendType
+	dirA, dirB         string
+	name               string
+	start, end         []byte
+	limit              int
+}
+
+func runDiff(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(out)
+	var cfg diffConfig
+	var backendA, backendB, prefix, start, end string
+	fs.StringVar(&backendA, "a-backend", "", "backend of the first database")
+	fs.StringVar(&cfg.dirA, "a-dir", "", "directory containing the first database")
+	fs.StringVar(&backendB, "b-backend", "", "backend of the second database, the first one's if empty")
+	fs.StringVar(&cfg.dirB, "b-dir", "", "directory containing the second database")
+	fs.StringVar(&cfg.name, "name", "", "name of the databases, e.g. application")
+	fs.StringVar(&prefix, "prefix", "", "hex-encoded prefix of the keys to compare")
+	fs.StringVar(&start, "start", "", "hex-encoded first key to compare")
+	fs.StringVar(&end, "end", "", "hex-encoded key to stop comparing before")
+	fs.IntVar(&cfg.limit, "limit", 100, "differences to print before stopping, all if 0")
+	fs.Usage = func() {
+		fmt.Fprint(out, "Usage: cosmos-db diff --a-backend <backend> --a-dir <dir> [--b-backend <backend>] --b-dir <dir> --name <name>\n\n"+
+			"Compares two databases key by key and prints the keys added to, removed from or changed in\n"+
+			"the second one, with their values in hex. Keys of the Cosmos SDK multistore and its IAVL\n"+
+			"trees are described. Fails if the databases differ.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if backendB == "" {
+		backendB = backendA
+	}
+	cfg.backendA, cfg.backendB = dbm.BackendType(backendA), dbm.BackendType(backendB)
+
+	var err error
+	switch {
+	case prefix != "" && (start != "" || end != ""):
+		return errors.New("--prefix cannot be combined with --start or --end")
+	case prefix != "":
+		if cfg.start, err = hex.DecodeString(prefix); err != nil {
+			return fmt.Errorf("invalid --prefix: %w", err)
+		}
+		cfg.end = prefixEnd(cfg.start)
+	default:
+		if cfg.start, err = decodeOptionalHex(start); err != nil {
+			return fmt.Errorf("invalid --start: %w", err)
+		}
+		if cfg.end, err = decodeOptionalHex(end); err != nil {
+			return fmt.Errorf("invalid --end: %w", err)
+		}
+	}
+	return diff(cfg, out)
+}
+
+// diff prints the differences between the two databases, and fails if there are any.
+func diff(cfg diffConfig, out io.Writer) error {
+	switch {
+	case cfg.backendA == "":
+		return errors.New("--a-backend is required")
+	case cfg.dirA == "" || cfg.dirB == "":
+		return errors.New("--a-dir and --b-dir are required")
+	case cfg.name == "":
+		return errors.New("--name is required")
+	case cfg.limit < 0:
+		return errors.New("--limit must not be negative")
+	case cfg.backendA == dbm.MemDBBackend || cfg.backendB == dbm.MemDBBackend:
+		return errors.New("memdb is not persistent and cannot be compared")
+	}
+	a, err := openExisting(cfg.name, cfg.backendA, cfg.dirA)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	b, err := openExisting(cfg.name, cfg.backendB, cfg.dirB)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	// The comparison stops at the first difference past the limit, rather than counting them all.
+	// The channel is drained once it stops, so that its snapshots are released before the
+	// databases are closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	diffs := dbm.DiffContext(ctx, a, b, cfg.start, cfg.end)
+	defer func() {
+		cancel()
+		for range diffs {
+		}
+	}()
+	counts := make(map[dbm.DiffKind]int)
+	var printed int
+	var truncated bool
+	for entry := range diffs {
+		if entry.Err != nil {
+			return entry.Err
+		}
+		if cfg.limit > 0 && printed >= cfg.limit {
+			truncated = true
+			break
+		}
+		counts[entry.Kind]++
+		printed++
+		fmt.Fprintf(out, "%-7s %x a=%s b=%s", entry.Kind, entry.Key, formatValue(entry.A), formatValue(entry.B))
+		if desc := describeKey(entry.Key); desc != "" {
+			fmt.Fprintf(out, " # %s", desc)
+		}
+		fmt.Fprintln(out)
+	}
+
+	if truncated {
+		fmt.Fprintln(out, "... more differences not printed")
+		return fmt.Errorf("databases differ: %d added, %d removed, %d changed, and more",
+			counts[dbm.DiffAdded], counts[dbm.DiffRemoved], counts[dbm.DiffChanged])
+	}
+	if printed > 0 {
+		return fmt.Errorf("databases differ: %d added, %d removed, %d changed",
+			counts[dbm.DiffAdded], counts[dbm.DiffRemoved], counts[dbm.DiffChanged])
+	}
+	fmt.Fprintln(out, "databases are identical")
+	return nil
+}
+
+// openExisting opens the database name in dir read-only, failing if it does not exist.
+func openExisting(name string, backend dbm.BackendType, dir string) (dbm.DB, error) {
+	path := filepath.Join(dir, name+dbm.DBFileSuffix)
+	if !dbm.FileExists(path) {
+		return nil, fmt.Errorf("database %s does not exist", path)
+	}
+	return dbm.NewReadOnlyDB(name, backend, dir)
+}
+
+// formatValue formats a value in hex, truncated to diffValueBytes, or "-" if it is absent.
+func formatValue(value []byte) string {
+	switch {
+	case value == nil:
+		return "-"
+	case len(value) == 0:
+		return `""`
+	case len(value) > diffValueBytes:
+		return fmt.Sprintf("%x...(%d bytes)", value[:diffValueBytes], len(value))
+	default:
+		return hex.EncodeToString(value)
+	}
+}
+
+// decodeOptionalHex decodes s, returning nil rather than an empty key if it is empty.
+func decodeOptionalHex(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return hex.DecodeString(s)
+}
+
+// prefixEnd returns the key following every key starting with prefix, or nil if there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xFF {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
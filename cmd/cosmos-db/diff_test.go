@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+func TestDiff(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	newMigrateSource(t, dbm.GoLevelDBBackend, src, 1000)
+	require.NoError(t, migrate(context.Background(), migrateConfig{
+		from: dbm.GoLevelDBBackend, to: dbm.PebbleDBBackend,
+		src: src, dst: dst, name: "application", batchSize: 4096,
+	}, &bytes.Buffer{}))
+	args := []string{"diff", "--a-backend", "goleveldb", "--a-dir", src, "--b-backend", "pebbledb", "--b-dir", dst, "--name", "application"}
+
+	var out bytes.Buffer
+	require.NoError(t, run(args, &out))
+	require.Equal(t, "databases are identical\n", out.String())
+
+	root := append([]byte("s/k:bank/s"), make([]byte, 12)...)
+	binary.BigEndian.PutUint64(root[10:], 42)
+	binary.BigEndian.PutUint32(root[18:], 1)
+	db, err := dbm.NewDB("application", dbm.PebbleDBBackend, dst)
+	require.NoError(t, err)
+	require.NoError(t, db.Delete([]byte("key000001")))
+	require.NoError(t, db.Set([]byte("key000002"), []byte{}))
+	require.NoError(t, db.Set(root, []byte{1}))
+	require.NoError(t, db.Close())
+
+	out.Reset()
+	err = run(args, &out)
+	require.EqualError(t, err, "databases differ: 1 added, 1 removed, 1 changed")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, []string{
+		"removed " + hex.EncodeToString([]byte("key000001")) + " a=" + strings.Repeat("01", 32) + "...(100 bytes) b=-",
+		"changed " + hex.EncodeToString([]byte("key000002")) + " a=" + strings.Repeat("02", 32) + `...(100 bytes) b=""`,
+		"added   " + hex.EncodeToString(root) + " a=- b=01 # store bank: root of version 42",
+	}, lines)
+
+	// A prefix bounds the comparison, and a limit the differences printed.
+	out.Reset()
+	require.NoError(t, run(append(args, "--prefix", hex.EncodeToString([]byte("key0001"))), &out))
+	out.Reset()
+	err = run(append(args, "--limit", "1"), &out)
+	require.EqualError(t, err, "databases differ: 0 added, 1 removed, 0 changed, and more")
+	require.Equal(t, lines[0]+"\n... more differences not printed\n", out.String())
+}
+
+func TestDiffLimit(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	newMigrateSource(t, dbm.GoLevelDBBackend, src, 1000)
+	newMigrateSource(t, dbm.PebbleDBBackend, dst, 1)
+
+	// The comparison stops past the limit, well before the 999 differences are all found, and
+	// releases its iterators before the databases are closed.
+	var out bytes.Buffer
+	err := run([]string{
+		"diff", "--a-backend", "goleveldb", "--a-dir", src, "--b-backend", "pebbledb", "--b-dir", dst,
+		"--name", "application", "--limit", "5",
+	}, &out)
+	require.EqualError(t, err, "databases differ: 0 added, 5 removed, 0 changed, and more")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 6)
+	require.Equal(t, "... more differences not printed", lines[5])
+	requireMigrated(t, dbm.GoLevelDBBackend, src, 1000)
+}
+
+func TestDiffInvalid(t *testing.T) {
+	dir := t.TempDir()
+	newMigrateSource(t, dbm.GoLevelDBBackend, dir, 10)
+
+	for name, args := range map[string][]string{
+		"missing dir":      {"--a-backend", "goleveldb", "--a-dir", dir, "--name", "application"},
+		"missing database": {"--a-backend", "goleveldb", "--a-dir", dir, "--b-dir", t.TempDir(), "--name", "application"},
+		"memdb":            {"--a-backend", "memdb", "--a-dir", dir, "--b-dir", dir, "--name", "application"},
+		"prefix and start": {"--a-backend", "goleveldb", "--a-dir", dir, "--b-dir", dir, "--name", "application", "--prefix", "6b", "--start", "6b"},
+		"invalid end":      {"--a-backend", "goleveldb", "--a-dir", dir, "--b-dir", dir, "--name", "application", "--end", "xyz"},
+		"negative limit":   {"--a-backend", "goleveldb", "--a-dir", dir, "--b-dir", dir, "--name", "application", "--limit", "-1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, run(append([]string{"diff"}, args...), &bytes.Buffer{}))
+		})
+	}
+}
+
+func TestDescribeKey(t *testing.T) {
+	node := append([]byte("s/k:acc/s"), make([]byte, 12)...)
+	binary.BigEndian.PutUint64(node[9:], 7)
+	binary.BigEndian.PutUint32(node[17:], 3)
+
+	for key, desc := range map[string]string{
+		"s/latest":                 "multistore latest version",
+		"s/123":                    "multistore commit info of version 123",
+		string(node):               "store acc: node of version 7, nonce 3",
+		"s/k:acc/fkey":             "store acc: fast node of key 6b6579",
+		"s/k:acc/mstorage_version": `store acc: metadata "storage_version"`,
+		"s/k:acc/x":                "store acc",
+		"s/other":                  "",
+		"key":                      "",
+	} {
+		require.Equal(t, desc, describeKey([]byte(key)), "key %q", key)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// hashCompareConfig is the configuration of a hash comparing two databases.
+type hashCompareConfig struct {
+	backendA, backendB dbm.BackendType
+	dirA, dirB         string
+	name               string
+	prefix             []byte
+}
+
+func runHash(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("hash", flag.ContinueOnError)
+	fs.SetOutput(out)
+	var backend, dir, name, prefix string
+	var backendA, backendB, dirA, dirB string
+	fs.StringVar(&backend, "backend", "", "backend of the database")
+	fs.StringVar(&dir, "dir", "", "directory containing the database")
+	fs.StringVar(&backendA, "a-backend", "", "backend of the first database to compare")
+	fs.StringVar(&dirA, "a-dir", "", "directory containing the first database to compare")
+	fs.StringVar(&backendB, "b-backend", "", "backend of the second database to compare, the first one's if empty")
+	fs.StringVar(&dirB, "b-dir", "", "directory containing the second database to compare")
+	fs.StringVar(&name, "name", "", "name of the database, e.g. application")
+	fs.StringVar(&prefix, "prefix", "", "hex-encoded prefix of the keys to hash, all keys if empty")
+	fs.Usage = func() {
+		fmt.Fprint(out, "Usage: cosmos-db hash --backend <backend> --dir <dir> --name <name> [--prefix <hex>]\n"+
+			"       cosmos-db hash --a-backend <backend> --a-dir <dir> [--b-backend <backend>] --b-dir <dir> --name <name> [--prefix <hex>]\n\n"+
+			"Prints the SHA-256 digest of the keys under a prefix: its root hash, and the hash and key\n"+
+			"count of each bucket of keys extending the prefix by one byte. Comparing the output for\n"+
+			"two databases, and running the command again with the prefix of a differing bucket,\n"+
+			"narrows down where they differ.\n\n"+
+			"Given two databases, bisects their digests down to the first key under the prefix which\n"+
+			"differs between them, and prints it. Fails if there is one.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	prefixBytes, err := hex.DecodeString(prefix)
+	if err != nil {
+		return fmt.Errorf("invalid --prefix: %w", err)
+	}
+
+	if backendA != "" || dirA != "" || backendB != "" || dirB != "" {
+		if backend != "" || dir != "" {
+			return errors.New("--backend and --dir cannot be combined with the options of two databases")
+		}
+		if backendB == "" {
+			backendB = backendA
+		}
+		return hashCompare(hashCompareConfig{
+			backendA: dbm.BackendType(backendA), backendB: dbm.BackendType(backendB),
+			dirA: dirA, dirB: dirB,
+			name:   name,
+			prefix: prefixBytes,
+		}, out)
+	}
+
+	switch {
+	case backend == "" || dir == "" || name == "":
+		return errors.New("--backend, --dir and --name are required")
+	case dbm.BackendType(backend) == dbm.MemDBBackend:
+		return errors.New("memdb is not persistent and cannot be hashed")
+	}
+	db, err := openExisting(name, dbm.BackendType(backend), dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	digest, err := dbm.DigestPrefix(db, prefixBytes, sha256.New)
+	if err != nil {
+		return err
+	}
+	return printDigest(out, digest)
+}
+
+// hashCompare prints the first key under the prefix which differs between the two databases,
+// with its values, and fails if there is one.
+func hashCompare(cfg hashCompareConfig, out io.Writer) error {
+	switch {
+	case cfg.backendA == "":
+		return errors.New("--a-backend is required")
+	case cfg.dirA == "" || cfg.dirB == "":
+		return errors.New("--a-dir and --b-dir are required")
+	case cfg.name == "":
+		return errors.New("--name is required")
+	case cfg.backendA == dbm.MemDBBackend || cfg.backendB == dbm.MemDBBackend:
+		return errors.New("memdb is not persistent and cannot be hashed")
+	}
+	a, err := openExisting(cfg.name, cfg.backendA, cfg.dirA)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	b, err := openExisting(cfg.name, cfg.backendB, cfg.dirB)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	scopedA, scopedB := a, b
+	if len(cfg.prefix) > 0 {
+		scopedA, scopedB = dbm.NewPrefixDB(a, cfg.prefix), dbm.NewPrefixDB(b, cfg.prefix)
+	}
+	key, err := dbm.FirstDifference(scopedA, scopedB, sha256.New)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		fmt.Fprintln(out, "databases are identical")
+		return nil
+	}
+	key = append(append([]byte(nil), cfg.prefix...), key...)
+	valueA, err := a.Get(key)
+	if err != nil {
+		return err
+	}
+	valueB, err := b.Get(key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "first difference %x a=%s b=%s", key, formatValue(valueA), formatValue(valueB))
+	if desc := describeKey(key); desc != "" {
+		fmt.Fprintf(out, " # %s", desc)
+	}
+	fmt.Fprintln(out)
+	return fmt.Errorf("databases differ, first at %x", key)
+}
+
+// printDigest prints the root of digest, then the key itself and each bucket which has keys, one
+// per line, with its key count and hash.
+func printDigest(out io.Writer, digest *dbm.PrefixDigest) error {
+	fmt.Fprintf(out, "prefix %x: %d keys, root %X\n", digest.Prefix, digest.Keys, digest.Root)
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	if digest.Own.Keys > 0 {
+		fmt.Fprintf(tw, "%x\t%d\t%X\n", digest.Prefix, digest.Own.Keys, digest.Own.Sum)
+	}
+	for b, bucket := range digest.Buckets {
+		if bucket.Keys > 0 {
+			fmt.Fprintf(tw, "%x%02x\t%d\t%X\n", digest.Prefix, b, bucket.Keys, bucket.Sum)
+		}
+	}
+	return tw.Flush()
+}
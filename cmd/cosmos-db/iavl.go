@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// Keys of the Cosmos SDK multistore: the latest version, the commit info of each version, and the
+// IAVL trees of the stores, each under its own prefix.
+const (
+	multistoreLatestKey  = "s/latest"
+	multistoreInfoPrefix = "s/"
+	multistoreTreePrefix = "s/k:"
+)
+
+// describeKey describes a key of the Cosmos SDK multistore and its IAVL trees, or returns "" if it
+// is not one. It recognises the node keys of IAVL v1, and the node, orphan and root keys of the
+// legacy format.
+func describeKey(key []byte) string {
+	if string(key) == multistoreLatestKey {
+		return "multistore latest version"
+	}
+	if rest, ok := bytes.CutPrefix(key, []byte(multistoreTreePrefix)); ok {
+		store, node, ok := bytes.Cut(rest, []byte("/"))
+		if !ok {
+			return ""
+		}
+		if desc := describeIAVLKey(node); desc != "" {
+			return fmt.Sprintf("store %s: %s", store, desc)
+		}
+		return fmt.Sprintf("store %s", store)
+	}
+	if rest, ok := bytes.CutPrefix(key, []byte(multistoreInfoPrefix)); ok {
+		if version, err := strconv.ParseUint(string(rest), 10, 64); err == nil {
+			return fmt.Sprintf("multistore commit info of version %d", version)
+		}
+	}
+	return ""
+}
+
+// describeIAVLKey describes a key of an IAVL tree, relative to the prefix of its store.
+func describeIAVLKey(key []byte) string {
+	if len(key) == 0 {
+		return ""
+	}
+	body := key[1:]
+	switch key[0] {
+	case 's':
+		if len(body) == 12 {
+			version, nonce := binary.BigEndian.Uint64(body), binary.BigEndian.Uint32(body[8:])
+			if nonce == 1 {
+				return fmt.Sprintf("root of version %d", version)
+			}
+			return fmt.Sprintf("node of version %d, nonce %d", version, nonce)
+		}
+	case 'f':
+		return fmt.Sprintf("fast node of key %x", body)
+	case 'm':
+		return fmt.Sprintf("metadata %q", body)
+	case 'n':
+		if len(body) == 32 {
+			return fmt.Sprintf("legacy node %x", body)
+		}
+	case 'o':
+		if len(body) == 48 {
+			return fmt.Sprintf("legacy orphan of versions %d to %d",
+				binary.BigEndian.Uint64(body[8:]), binary.BigEndian.Uint64(body))
+		}
+	case 'r':
+		if len(body) == 8 {
+			return fmt.Sprintf("legacy root of version %d", binary.BigEndian.Uint64(body))
+		}
+	}
+	return ""
+}
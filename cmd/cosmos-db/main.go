@@ -0,0 +1,56 @@
+// Command cosmos-db is a set of tools for working with cosmos-db databases.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a cosmos-db subcommand. It is run with the arguments which follow its name.
+type command struct {
+	summary string
+	run     func(args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"diff":    {summary: "compare two databases key by key", run: runDiff},
+	"hash":    {summary: "print the digest of the keys under a prefix", run: runHash},
+	"migrate": {summary: "copy a database to another backend", run: runMigrate},
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(out, usage())
+		return nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage())
+	}
+	return cmd.run(args[1:], out)
+}
+
+func usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Usage: cosmos-db <command> [flags]\n\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-10s %s\n", name, commands[name].summary)
+	}
+	return b.String()
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// defaultMigrateBatchSize is the number of bytes, as reported by Batch.GetByteSize, buffered
+// before a batch is written to the destination.
+const defaultMigrateBatchSize = 16 << 20
+
+// migrateConfig is the configuration of a migration.
+type migrateConfig struct {
+	from, to   dbm.BackendType
+	src, dst   string
+	name       string
+	batchSize  int
+	skipVerify bool
+}
+
+// migrateProgress records how far a migration got, so that it can be resumed. It is saved next to
+// the destination database after every batch, and removed once the migration is verified.
+type migrateProgress struct {
+	From    dbm.BackendType `json:"from"`
+	To      dbm.BackendType `json:"to"`
+	LastKey []byte          `json:"last_key"`
+	Keys    uint64          `json:"keys"`
+}
+
+func runMigrate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	var cfg migrateConfig
+	var from, to string
+	fs.StringVar(&from, "from", "", "backend of the source database")
+	fs.StringVar(&to, "to", "", "backend of the destination database")
+	fs.StringVar(&cfg.src, "src", "", "directory containing the source database")
+	fs.StringVar(&cfg.dst, "dst", "", "directory to create the destination database in")
+	fs.StringVar(&cfg.name, "name", "", "name of the database, e.g. application")
+	fs.IntVar(&cfg.batchSize, "batch-size", defaultMigrateBatchSize, "bytes to buffer per write batch")
+	fs.BoolVar(&cfg.skipVerify, "skip-verify", false, "do not compare key counts and checksums afterwards")
+	fs.Usage = func() {
+		fmt.Fprint(out, "Usage: cosmos-db migrate --from <backend> --to <backend> --src <dir> --dst <dir> --name <name>\n\n"+
+			"Copies every key of a database into a database of another backend. An interrupted\n"+
+			"migration is resumed by running the same command again.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	cfg.from, cfg.to = dbm.BackendType(from), dbm.BackendType(to)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return migrate(ctx, cfg, out)
+}
+
+// migrate copies the source database into the destination database. It stops after the current
+// batch if ctx is cancelled, leaving the progress file behind so that it can be resumed.
+func migrate(ctx context.Context, cfg migrateConfig, out io.Writer) error {
+	switch {
+	case cfg.from == "" || cfg.to == "":
+		return errors.New("--from and --to are required")
+	case cfg.src == "" || cfg.dst == "":
+		return errors.New("--src and --dst are required")
+	case cfg.name == "":
+		return errors.New("--name is required")
+	case cfg.batchSize <= 0:
+		return errors.New("--batch-size must be positive")
+	case cfg.from == dbm.MemDBBackend || cfg.to == dbm.MemDBBackend:
+		return errors.New("memdb is not persistent and cannot be migrated")
+	}
+	srcPath, err := filepath.Abs(filepath.Join(cfg.src, cfg.name+dbm.DBFileSuffix))
+	if err != nil {
+		return err
+	}
+	dstPath, err := filepath.Abs(filepath.Join(cfg.dst, cfg.name+dbm.DBFileSuffix))
+	if err != nil {
+		return err
+	}
+	if srcPath == dstPath {
+		return errors.New("the source and destination databases must be in different directories")
+	}
+	if !dbm.FileExists(srcPath) {
+		return fmt.Errorf("source database %s does not exist", srcPath)
+	}
+
+	progressPath := filepath.Join(cfg.dst, cfg.name+".migrate")
+	progress, resumed, err := loadMigrateProgress(progressPath)
+	if err != nil {
+		return err
+	}
+	if resumed && (progress.From != cfg.from || progress.To != cfg.to) {
+		return fmt.Errorf("%s records a migration from %s to %s, not from %s to %s",
+			progressPath, progress.From, progress.To, cfg.from, cfg.to)
+	}
+	progress.From, progress.To = cfg.from, cfg.to
+
+	src, err := dbm.NewDB(cfg.name, cfg.from, cfg.src)
+	if err != nil {
+		return fmt.Errorf("opening source: %w", err)
+	}
+	defer src.Close()
+	dst, err := dbm.NewDB(cfg.name, cfg.to, cfg.dst)
+	if err != nil {
+		return fmt.Errorf("opening destination: %w", err)
+	}
+	defer dst.Close()
+
+	if resumed {
+		fmt.Fprintf(out, "resuming after %d keys\n", progress.Keys)
+	} else {
+		empty, err := isEmpty(dst)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return fmt.Errorf("destination database %s is not empty", dstPath)
+		}
+	}
+
+	if err := copyKeys(ctx, src, dst, cfg.batchSize, progress, progressPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "copied %d keys from %s to %s\n", progress.Keys, srcPath, dstPath)
+
+	if !cfg.skipVerify {
+		if err := verifyMigration(src, dst, out); err != nil {
+			return err
+		}
+	}
+	return os.Remove(progressPath)
+}
+
+// copyKeys copies the keys of src after progress.LastKey into dst, saving progress before the
+// first batch and after every batch.
+func copyKeys(ctx context.Context, src, dst dbm.DB, batchSize int, progress *migrateProgress, progressPath string) error {
+	// The progress file marks the destination as being migrated into, so that it can be resumed
+	// even if the migration stops between writing its first batch and recording it.
+	if err := saveMigrateProgress(progressPath, progress); err != nil {
+		return err
+	}
+	var start []byte
+	if progress.LastKey != nil {
+		start = append(bytes.Clone(progress.LastKey), 0x00)
+	}
+	itr, err := src.Iterator(start, nil)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+
+	batch := dst.NewBatch()
+	defer func() { _ = batch.Close() }()
+	var pending uint64
+	var lastKey []byte
+
+	// flush durably writes the batch before recording its last key, so that a resumed
+	// migration never skips a key.
+	flush := func() error {
+		if pending == 0 {
+			return saveMigrateProgress(progressPath, progress)
+		}
+		if err := batch.WriteSync(); err != nil {
+			return err
+		}
+		_ = batch.Close()
+		batch = dst.NewBatch()
+		progress.LastKey = bytes.Clone(lastKey)
+		progress.Keys += pending
+		pending = 0
+		return saveMigrateProgress(progressPath, progress)
+	}
+
+	for ; itr.Valid(); itr.Next() {
+		if err := batch.Set(itr.Key(), itr.Value()); err != nil {
+			return err
+		}
+		lastKey = append(lastKey[:0], itr.Key()...)
+		pending++
+
+		size, err := batch.GetByteSize()
+		if err != nil {
+			return err
+		}
+		if size < batchSize {
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("migration interrupted after %d keys, run the command again to resume: %w", progress.Keys, err)
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// verifyMigration checks that src and dst hold the same number of keys, with the same checksum.
+func verifyMigration(src, dst dbm.DB, out io.Writer) error {
+	srcHash, err := dbm.HashRange(src, nil, nil, sha256.New)
+	if err != nil {
+		return fmt.Errorf("checksumming source: %w", err)
+	}
+	dstHash, err := dbm.HashRange(dst, nil, nil, sha256.New)
+	if err != nil {
+		return fmt.Errorf("checksumming destination: %w", err)
+	}
+	if srcHash.Keys != dstHash.Keys {
+		return fmt.Errorf("verification failed: source has %d keys, destination has %d", srcHash.Keys, dstHash.Keys)
+	}
+	if !bytes.Equal(srcHash.Sum, dstHash.Sum) {
+		return fmt.Errorf("verification failed: source checksum %X, destination checksum %X", srcHash.Sum, dstHash.Sum)
+	}
+	fmt.Fprintf(out, "verified %d keys, checksum %X\n", srcHash.Keys, srcHash.Sum)
+	return nil
+}
+
+func isEmpty(db dbm.DB) (bool, error) {
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer itr.Close()
+	return !itr.Valid(), itr.Error()
+}
+
+// loadMigrateProgress reads the progress file at path, reporting whether there was one.
+func loadMigrateProgress(path string) (*migrateProgress, bool, error) {
+	bz, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &migrateProgress{}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var progress migrateProgress
+	if err := json.Unmarshal(bz, &progress); err != nil {
+		return nil, false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &progress, true, nil
+}
+
+// saveMigrateProgress atomically replaces the progress file at path.
+func saveMigrateProgress(path string, progress *migrateProgress) error {
+	bz, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bz, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
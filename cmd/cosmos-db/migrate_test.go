@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+func newMigrateSource(t *testing.T, backend dbm.BackendType, dir string, keys int) {
+	t.Helper()
+
+	db, err := dbm.NewDB("application", backend, dir)
+	require.NoError(t, err)
+	defer db.Close()
+	for i := 0; i < keys; i++ {
+		require.NoError(t, db.Set([]byte(fmt.Sprintf("key%06d", i)), bytes.Repeat([]byte{byte(i)}, 100)))
+	}
+}
+
+func requireMigrated(t *testing.T, backend dbm.BackendType, dir string, keys int) {
+	t.Helper()
+
+	db, err := dbm.NewDB("application", backend, dir)
+	require.NoError(t, err)
+	defer db.Close()
+	for i := 0; i < keys; i++ {
+		value, err := db.Get([]byte(fmt.Sprintf("key%06d", i)))
+		require.NoError(t, err)
+		require.Equal(t, bytes.Repeat([]byte{byte(i)}, 100), value)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	for _, to := range []dbm.BackendType{dbm.PebbleDBBackend, dbm.TreeDBBackend} {
+		t.Run(string(to), func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			newMigrateSource(t, dbm.GoLevelDBBackend, src, 1000)
+
+			var out bytes.Buffer
+			err := run([]string{
+				"migrate", "--from", "goleveldb", "--to", string(to),
+				"--src", src, "--dst", dst, "--name", "application", "--batch-size", "4096",
+			}, &out)
+			require.NoError(t, err)
+			require.Contains(t, out.String(), "copied 1000 keys")
+			require.Contains(t, out.String(), "verified 1000 keys")
+			require.NoFileExists(t, filepath.Join(dst, "application.migrate"))
+
+			requireMigrated(t, to, dst, 1000)
+		})
+	}
+}
+
+func TestMigrateResume(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	newMigrateSource(t, dbm.GoLevelDBBackend, src, 1000)
+	cfg := migrateConfig{
+		from: dbm.GoLevelDBBackend, to: dbm.PebbleDBBackend,
+		src: src, dst: dst, name: "application", batchSize: 4096,
+	}
+
+	// A cancelled migration stops after the first batch, leaving its progress behind.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := migrate(ctx, cfg, &bytes.Buffer{})
+	require.ErrorIs(t, err, context.Canceled)
+	progress, resumed, err := loadMigrateProgress(filepath.Join(dst, "application.migrate"))
+	require.NoError(t, err)
+	require.True(t, resumed)
+	require.Greater(t, progress.Keys, uint64(0))
+	require.Less(t, progress.Keys, uint64(1000))
+
+	// Resuming with other backends is refused.
+	other := cfg
+	other.to = dbm.TreeDBBackend
+	require.Error(t, migrate(context.Background(), other, &bytes.Buffer{}))
+
+	var out bytes.Buffer
+	require.NoError(t, migrate(context.Background(), cfg, &out))
+	require.Contains(t, out.String(), fmt.Sprintf("resuming after %d keys", progress.Keys))
+	require.Contains(t, out.String(), "verified 1000 keys")
+	requireMigrated(t, dbm.PebbleDBBackend, dst, 1000)
+}
+
+// progressHook fails the test if a batch is written before the progress file exists.
+type progressHook struct {
+	dbm.NopWriteHook
+	t    *testing.T
+	path string
+}
+
+func (h progressHook) BeforeBatchWrite([]dbm.Change) error {
+	require.True(h.t, dbm.FileExists(h.path), "batch written before the progress file")
+	return nil
+}
+
+func TestCopyKeysProgress(t *testing.T) {
+	src := dbm.NewMemDB()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, src.Set([]byte(fmt.Sprintf("key%06d", i)), []byte{1}))
+	}
+	path := filepath.Join(t.TempDir(), "application.migrate")
+	dst := dbm.NewHookDB(dbm.NewMemDB(), progressHook{t: t, path: path})
+
+	// The progress is recorded before the first batch is written, so that a crash right after
+	// writing it leaves a migration which can be resumed.
+	progress := &migrateProgress{From: dbm.MemDBBackend, To: dbm.MemDBBackend}
+	require.NoError(t, copyKeys(context.Background(), src, dst, 64, progress, path))
+	require.Equal(t, uint64(100), progress.Keys)
+}
+
+func TestMigrateInvalid(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	newMigrateSource(t, dbm.GoLevelDBBackend, src, 10)
+	valid := migrateConfig{
+		from: dbm.GoLevelDBBackend, to: dbm.PebbleDBBackend,
+		src: src, dst: dst, name: "application", batchSize: 4096,
+	}
+
+	for name, modify := range map[string]func(*migrateConfig){
+		"missing name":    func(c *migrateConfig) { c.name = "" },
+		"missing source":  func(c *migrateConfig) { c.src = t.TempDir() },
+		"same directory":  func(c *migrateConfig) { c.dst = c.src },
+		"memdb":           func(c *migrateConfig) { c.to = dbm.MemDBBackend },
+		"unknown backend": func(c *migrateConfig) { c.to = "nosuchdb" },
+		"zero batch size": func(c *migrateConfig) { c.batchSize = 0 },
+		"nonempty dst":    func(c *migrateConfig) { newMigrateSource(t, dbm.PebbleDBBackend, c.dst, 1) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := valid
+			cfg.dst = t.TempDir()
+			modify(&cfg)
+			require.Error(t, migrate(context.Background(), cfg, &bytes.Buffer{}))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package db_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/cosmos/cosmos-db/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	for _, backend := range []dbm.BackendType{
+		dbm.MemDBBackend, dbm.GoLevelDBBackend, dbm.PebbleDBBackend, dbm.TreeDBBackend,
+	} {
+		t.Run(string(backend), func(t *testing.T) {
+			dbtest.RunConformance(t, func() dbm.DB {
+				db, err := dbm.NewDB("conformance", backend, t.TempDir())
+				require.NoError(t, err)
+				return db
+			})
+		})
+	}
+
+	t.Run("prefixdb", func(t *testing.T) {
+		dbtest.RunConformance(t, func() dbm.DB {
+			source := dbm.NewMemDB()
+			require.NoError(t, source.Set([]byte("prefix"), []byte{0}))
+			require.NoError(t, source.Set([]byte("prefiy"), []byte{1}))
+			return dbm.NewPrefixDB(source, []byte("prefix/"))
+		})
+	})
+
+	t.Run("metricsdb", func(t *testing.T) {
+		var n int
+		dbtest.RunConformance(t, func() dbm.DB {
+			n++
+			db, err := dbm.NewMetricsDB(dbm.NewMemDB(), prometheus.NewRegistry(), map[string]string{"db": fmt.Sprint(n)})
+			require.NoError(t, err)
+			return db
+		})
+	})
+
+	t.Run("faultdb", func(t *testing.T) {
+		dbtest.RunConformance(t, func() dbm.DB {
+			return dbm.NewFaultDB(dbm.NewMemDB(), 1)
+		})
+	})
+
+	t.Run("cacheddb", func(t *testing.T) {
+		dbtest.RunConformance(t, func() dbm.DB {
+			return dbm.NewCachedDB(dbm.NewMemDB(), 1<<20)
+		})
+	})
+
+	t.Run("changefeeddb", func(t *testing.T) {
+		dbtest.RunConformance(t, func() dbm.DB {
+			db := dbm.NewChangeFeedDB(dbm.NewMemDB(), dbm.ChangeFeedOptions{Policy: dbm.ChangeFeedDrop})
+			db.Subscribe(nil)
+			return db
+		})
+	})
+
+	t.Run("hookdb", func(t *testing.T) {
+		dbtest.RunConformance(t, func() dbm.DB {
+			return dbm.NewHookDB(dbm.NewMemDB(), dbm.NopWriteHook{})
+		})
+	})
+}
@@ -0,0 +1,21 @@
+package db_test
+
+import (
+	"testing"
+
+	dbm "github.com/cosmos/cosmos-db"
+	"github.com/cosmos/cosmos-db/dbtest"
+)
+
+func TestCrashRecovery(t *testing.T) {
+	for _, backend := range dbm.Backends() {
+		if !backend.Capabilities.Persistent {
+			continue
+		}
+		t.Run(string(backend.Type), func(t *testing.T) {
+			dbtest.RunCrashRecovery(t, func(dir string) (dbm.DB, error) {
+				return dbm.NewDB("crash", backend.Type, dir)
+			}, dbtest.CrashOptions{})
+		})
+	}
+}
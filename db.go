@@ -1,8 +1,11 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 )
 
 type BackendType string
@@ -30,21 +33,110 @@ const (
 )
 
 type (
-	dbCreator func(name, dir string, opts Options) (DB, error)
+	// Creator opens a database of a backend, with the arguments given to NewDBwithOptions.
+	Creator func(name, dir string, opts Options) (DB, error)
 
 	Options interface {
 		Get(string) interface{}
 	}
+
+	// KeyedOptions are Options which can list their keys, as a viper.Viper, which backs the
+	// AppOptions of the Cosmos SDK, can. Backends only reject the unknown keys of their own
+	// namespace, such as pebble.*, in Options which can list them.
+	KeyedOptions interface {
+		Options
+		AllKeys() []string
+	}
+)
+
+// Capabilities describe what a backend can do, for callers choosing between backends.
+type Capabilities struct {
+	// Persistent backends keep their data on disk, across restarts.
+	Persistent bool
+	// Snapshots is set if NewSnapshot takes a point-in-time view without copying the database.
+	Snapshots bool
+	// DeleteRange is set if DeleteRange is native to the backend, taking about the same time
+	// however many keys are in the range, rather than deleting the keys one by one.
+	DeleteRange bool
+	// RequiresCgo is set if the backend is only available in builds with cgo enabled.
+	RequiresCgo bool
+}
+
+// Backend is a registered backend.
+type Backend struct {
+	Type         BackendType
+	Capabilities Capabilities
+}
+
+type backend struct {
+	creator      Creator
+	capabilities Capabilities
+}
+
+var (
+	backendsMtx sync.RWMutex
+	backends    = map[BackendType]backend{}
 )
 
-var backends = map[BackendType]dbCreator{}
+func registerDBCreator(backendType BackendType, creator Creator, caps Capabilities, force bool) {
+	backendsMtx.Lock()
+	defer backendsMtx.Unlock()
 
-func registerDBCreator(backend BackendType, creator dbCreator, force bool) {
-	_, ok := backends[backend]
+	_, ok := backends[backendType]
 	if !force && ok {
 		return
 	}
-	backends[backend] = creator
+	backends[backendType] = backend{creator: creator, capabilities: caps}
+}
+
+// RegisterBackend registers a backend, so that NewDB and NewDBwithOptions can open databases of
+// it. It errors if a backend of the same type is already registered. The backend is registered
+// without any capabilities; use RegisterBackendWithCapabilities to describe them.
+func RegisterBackend(backendType BackendType, creator Creator) error {
+	return RegisterBackendWithCapabilities(backendType, creator, Capabilities{})
+}
+
+// RegisterBackendWithCapabilities is like RegisterBackend, recording the capabilities of the
+// backend as well.
+func RegisterBackendWithCapabilities(backendType BackendType, creator Creator, caps Capabilities) error {
+	if backendType == "" {
+		return errors.New("backend type cannot be empty")
+	}
+	if creator == nil {
+		return fmt.Errorf("creator for backend %s cannot be nil", backendType)
+	}
+
+	backendsMtx.Lock()
+	defer backendsMtx.Unlock()
+
+	if _, ok := backends[backendType]; ok {
+		return fmt.Errorf("backend %s is already registered", backendType)
+	}
+	backends[backendType] = backend{creator: creator, capabilities: caps}
+	return nil
+}
+
+// Backends returns the registered backends, sorted by type.
+func Backends() []Backend {
+	backendsMtx.RLock()
+	defer backendsMtx.RUnlock()
+
+	list := make([]Backend, 0, len(backends))
+	for backendType, b := range backends {
+		list = append(list, Backend{Type: backendType, Capabilities: b.capabilities})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type < list[j].Type })
+	return list
+}
+
+// BackendCapabilities returns the capabilities of a registered backend. ok is false if no backend
+// of the type is registered.
+func BackendCapabilities(backendType BackendType) (caps Capabilities, ok bool) {
+	backendsMtx.RLock()
+	defer backendsMtx.RUnlock()
+
+	b, ok := backends[backendType]
+	return b.capabilities, ok
 }
 
 // NewDB creates a new database of type backend with the given name.
@@ -53,19 +145,34 @@ func NewDB(name string, backend BackendType, dir string) (DB, error) {
 }
 
 func NewDBwithOptions(name string, backend BackendType, dir string, opts Options) (DB, error) {
-	dbCreator, ok := backends[backend]
+	backendsMtx.RLock()
+	b, ok := backends[backend]
+	backendsMtx.RUnlock()
 	if !ok {
-		keys := make([]string, 0, len(backends))
-		for k := range backends {
-			keys = append(keys, string(k))
+		var keys []string
+		for _, registered := range Backends() {
+			keys = append(keys, string(registered.Type))
 		}
 		return nil, fmt.Errorf("unknown db_backend %s, expected one of %v",
 			backend, strings.Join(keys, ","))
 	}
 
-	db, err := dbCreator(name, dir, opts)
+	readOnly, err := isReadOnly(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := b.creator(name, dir, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
+	if readOnly {
+		return &readOnlyDB{db: db}, nil
+	}
 	return db, nil
 }
+
+// NewReadOnlyDB opens an existing database of type backend read-only. Writes to it fail with
+// ErrReadOnly. See OptReadOnly.
+func NewReadOnlyDB(name string, backend BackendType, dir string) (DB, error) {
+	return NewDBwithOptions(name, backend, dir, OptionsMap{OptReadOnly: true})
+}
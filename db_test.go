@@ -137,3 +137,57 @@ func TestDBIteratorNonemptyBeginAfter(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterBackend(t *testing.T) {
+	custom := BackendType("custom_" + randStr(8))
+	t.Cleanup(func() {
+		backendsMtx.Lock()
+		defer backendsMtx.Unlock()
+		delete(backends, custom)
+	})
+
+	var opened string
+	creator := func(name, dir string, opts Options) (DB, error) {
+		opened = name
+		return NewMemDB(), nil
+	}
+	caps := Capabilities{Snapshots: true}
+	require.NoError(t, RegisterBackendWithCapabilities(custom, creator, caps))
+
+	db, err := NewDB("custom", custom, t.TempDir())
+	require.NoError(t, err)
+	require.IsType(t, &MemDB{}, db)
+	require.Equal(t, "custom", opened)
+
+	got, ok := BackendCapabilities(custom)
+	require.True(t, ok)
+	require.Equal(t, caps, got)
+	require.Contains(t, Backends(), Backend{Type: custom, Capabilities: caps})
+
+	// Duplicates, including of the built-in backends, are rejected.
+	require.Error(t, RegisterBackend(custom, creator))
+	require.Error(t, RegisterBackend(GoLevelDBBackend, creator))
+	require.Error(t, RegisterBackend("", creator))
+	require.Error(t, RegisterBackend(BackendType("other_"+randStr(8)), nil))
+}
+
+func TestBackends(t *testing.T) {
+	list := Backends()
+	for i := 1; i < len(list); i++ {
+		require.Less(t, list[i-1].Type, list[i].Type)
+	}
+
+	for backend, want := range map[BackendType]Capabilities{
+		MemDBBackend:     {Snapshots: true},
+		GoLevelDBBackend: {Persistent: true, Snapshots: true},
+		PebbleDBBackend:  {Persistent: true, Snapshots: true, DeleteRange: true},
+		TreeDBBackend:    {Persistent: true, Snapshots: true, DeleteRange: true},
+	} {
+		require.Contains(t, list, Backend{Type: backend, Capabilities: want})
+	}
+
+	_, ok := BackendCapabilities("nosuchdb")
+	require.False(t, ok)
+	_, err := NewDB("test", "nosuchdb", t.TempDir())
+	require.ErrorContains(t, err, "unknown db_backend nosuchdb")
+}
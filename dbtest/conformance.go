@@ -0,0 +1,465 @@
+// Package dbtest provides a conformance suite for implementations of the cosmos-db DB interface,
+// so that backends and wrappers outside this module can check that they behave like the ones in
+// it, and a crash recovery harness checking the durability of synced writes.
+package dbtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// RunConformance runs the conformance suite against the databases returned by newDB, as subtests
+// of t. newDB is called once per subtest and must return a new, empty database. The suite closes
+// the databases it is given; newDB should register any further cleanup, such as removing a
+// directory, with t.Cleanup.
+func RunConformance(t *testing.T, newDB func() dbm.DB) {
+	t.Helper()
+
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, db dbm.DB)
+	}{
+		{"GetSetDelete", testGetSetDelete},
+		{"GetMany", testGetMany},
+		{"View", testView},
+		{"EmptyKeys", testEmptyKeys},
+		{"Iterator", testIterator},
+		{"ReverseIterator", testReverseIterator},
+		{"IteratorEmptyDB", testIteratorEmptyDB},
+		{"IteratorSeek", testIteratorSeek},
+		{"Batch", testBatch},
+		{"BatchClosed", testBatchClosed},
+		{"DeleteRange", testDeleteRange},
+		{"Snapshot", testSnapshot},
+		{"Estimate", testEstimate},
+		{"Prefix", testPrefix},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newDB()
+			require.NotNil(t, db, "newDB returned nil")
+			t.Cleanup(func() { _ = db.Close() })
+			tc.run(t, db)
+		})
+	}
+}
+
+func testGetSetDelete(t *testing.T, db dbm.DB) {
+	// A nonexistent key should return nil.
+	value, err := db.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+	ok, err := db.Has([]byte("a"))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Set and get a value.
+	require.NoError(t, db.Set([]byte("a"), []byte{0x01}))
+	ok, err = db.Has([]byte("a"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	requireValue(t, db, []byte("a"), []byte{0x01})
+
+	require.NoError(t, db.SetSync([]byte("b"), []byte{0x02}))
+	requireValue(t, db, []byte("b"), []byte{0x02})
+
+	// Setting a key again replaces its value.
+	require.NoError(t, db.Set([]byte("a"), []byte{0x03}))
+	requireValue(t, db, []byte("a"), []byte{0x03})
+
+	// Deleting a nonexistent key is fine.
+	require.NoError(t, db.Delete([]byte("x")))
+	require.NoError(t, db.DeleteSync([]byte("x")))
+
+	// Delete values.
+	require.NoError(t, db.Delete([]byte("a")))
+	requireValue(t, db, []byte("a"), nil)
+	require.NoError(t, db.DeleteSync([]byte("b")))
+	requireValue(t, db, []byte("b"), nil)
+
+	// An empty value is not a nil value, and is stored as such.
+	require.NoError(t, db.Set([]byte("x"), []byte{}))
+	requireValue(t, db, []byte("x"), []byte{})
+	require.NoError(t, db.SetSync([]byte("y"), []byte{}))
+	requireValue(t, db, []byte("y"), []byte{})
+}
+
+func testGetMany(t *testing.T, db dbm.DB) {
+	values, err := db.GetMany(nil)
+	require.NoError(t, err)
+	require.Empty(t, values)
+
+	require.NoError(t, db.Set([]byte("a"), []byte{0x01}))
+	require.NoError(t, db.Set([]byte("c"), []byte{0x03}))
+	require.NoError(t, db.Set([]byte("e"), []byte{}))
+
+	// Values come back in the order of the keys, whatever it is, with nil for missing keys and
+	// empty values kept apart from them.
+	values, err = db.GetMany([][]byte{[]byte("e"), []byte("b"), []byte("c"), []byte("a"), []byte("c"), []byte("f")})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{}, nil, {0x03}, {0x01}, {0x03}, nil}, values)
+	require.NotNil(t, values[0])
+
+	for _, keys := range [][][]byte{{nil}, {[]byte("a"), {}}} {
+		_, err := db.GetMany(keys)
+		require.Error(t, err, "GetMany of %q should fail on the empty key", keys)
+	}
+}
+
+func testView(t *testing.T, db dbm.DB) {
+	require.NoError(t, db.Set([]byte("a"), []byte{0x01}))
+	require.NoError(t, db.Set([]byte("e"), []byte{}))
+
+	// fn is called with the value, an empty value, or nil for a missing key.
+	for key, want := range map[string][]byte{"a": {0x01}, "e": {}, "m": nil} {
+		called := false
+		err := db.View([]byte(key), func(value []byte) error {
+			called = true
+			require.Equal(t, want, value, "View of %q", key)
+			require.Equal(t, want == nil, value == nil, "View of %q", key)
+			return nil
+		})
+		require.NoError(t, err)
+		require.True(t, called, "View of %q should call fn", key)
+	}
+
+	// The error of fn is returned as is.
+	errView := errors.New("view failed")
+	require.ErrorIs(t, db.View([]byte("a"), func([]byte) error { return errView }), errView)
+	require.ErrorIs(t, db.View([]byte("m"), func([]byte) error { return errView }), errView)
+
+	for _, key := range [][]byte{nil, {}} {
+		err := db.View(key, func([]byte) error {
+			t.Fatal("fn should not be called for an empty key")
+			return nil
+		})
+		require.Error(t, err)
+	}
+}
+
+func testEstimate(t *testing.T, db dbm.DB) {
+	// Estimates are free to be approximate, but an empty database has no keys.
+	keys, err := db.EstimateKeys(nil, nil)
+	require.NoError(t, err)
+	require.Zero(t, keys)
+
+	require.NoError(t, db.Set([]byte("a"), []byte{0x01}))
+	_, err = db.EstimateSize(nil, nil)
+	require.NoError(t, err)
+	_, err = db.EstimateKeys([]byte("a"), []byte("b"))
+	require.NoError(t, err)
+
+	for _, domain := range [][2][]byte{{{}, nil}, {nil, {}}} {
+		_, err := db.EstimateSize(domain[0], domain[1])
+		require.Error(t, err, "EstimateSize should fail on an empty key")
+		_, err = db.EstimateKeys(domain[0], domain[1])
+		require.Error(t, err, "EstimateKeys should fail on an empty key")
+	}
+}
+
+func testEmptyKeys(t *testing.T, db dbm.DB) {
+	for _, key := range [][]byte{nil, {}} {
+		_, err := db.Get(key)
+		require.Error(t, err, "Get(%#v)", key)
+		_, err = db.Has(key)
+		require.Error(t, err, "Has(%#v)", key)
+		require.Error(t, db.Set(key, []byte{0x01}), "Set(%#v)", key)
+		require.Error(t, db.SetSync(key, []byte{0x01}), "SetSync(%#v)", key)
+		require.Error(t, db.Delete(key), "Delete(%#v)", key)
+		require.Error(t, db.DeleteSync(key), "DeleteSync(%#v)", key)
+	}
+
+	// Nil values are not allowed either.
+	require.Error(t, db.Set([]byte("x"), nil))
+	require.Error(t, db.SetSync([]byte("x"), nil))
+
+	// Iterator bounds may be nil, but not empty.
+	_, err := db.Iterator([]byte{}, nil)
+	require.Error(t, err)
+	_, err = db.Iterator(nil, []byte{})
+	require.Error(t, err)
+	_, err = db.ReverseIterator([]byte{}, nil)
+	require.Error(t, err)
+	_, err = db.ReverseIterator(nil, []byte{})
+	require.Error(t, err)
+	require.Error(t, db.DeleteRange([]byte{}, nil))
+	require.Error(t, db.DeleteRange(nil, []byte{}))
+}
+
+func testBatch(t *testing.T, db dbm.DB) {
+	// Writes in a batch are not visible until it is written.
+	batch := db.NewBatch()
+	require.NoError(t, batch.Set([]byte("a"), []byte{1}))
+	require.NoError(t, batch.Set([]byte("b"), []byte{2}))
+	require.NoError(t, batch.Set([]byte("c"), []byte{3}))
+	requireKeyValues(t, db, map[string][]byte{})
+	require.NoError(t, batch.Write())
+	requireKeyValues(t, db, map[string][]byte{"a": {1}, "b": {2}, "c": {3}})
+	require.NoError(t, batch.Close())
+
+	// Batches apply their operations in order.
+	batch = db.NewBatch()
+	require.NoError(t, batch.Delete([]byte("a")))
+	require.NoError(t, batch.Set([]byte("a"), []byte{1}))
+	require.NoError(t, batch.Set([]byte("b"), []byte{1}))
+	require.NoError(t, batch.Set([]byte("b"), []byte{2}))
+	require.NoError(t, batch.Set([]byte("c"), []byte{3}))
+	require.NoError(t, batch.Delete([]byte("c")))
+	require.NoError(t, batch.WriteSync())
+	require.NoError(t, batch.Close())
+	requireKeyValues(t, db, map[string][]byte{"a": {1}, "b": {2}})
+
+	// Empty and nil keys, and nil values, are not allowed.
+	batch = db.NewBatchWithSize(16)
+	require.Error(t, batch.Set([]byte{}, []byte{0x01}))
+	require.Error(t, batch.Set(nil, []byte{0x01}))
+	require.Error(t, batch.Set([]byte("a"), nil))
+	require.Error(t, batch.Delete([]byte{}))
+	require.Error(t, batch.Delete(nil))
+	require.NoError(t, batch.Close())
+
+	// An empty batch can be written.
+	batch = db.NewBatch()
+	size, err := batch.GetByteSize()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, size, 0)
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	requireKeyValues(t, db, map[string][]byte{"a": {1}, "b": {2}})
+}
+
+func testBatchClosed(t *testing.T, db dbm.DB) {
+	// Only Close can be called on a written batch.
+	batch := db.NewBatch()
+	require.NoError(t, batch.Set([]byte("a"), []byte{1}))
+	require.NoError(t, batch.Write())
+	requireBatchUnusable(t, batch)
+	require.NoError(t, batch.Close())
+	requireKeyValues(t, db, map[string][]byte{"a": {1}})
+
+	// Closing is idempotent, and nothing else can be called on a closed batch.
+	batch = db.NewBatch()
+	require.NoError(t, batch.Set([]byte("b"), []byte{2}))
+	require.NoError(t, batch.Close())
+	require.NoError(t, batch.Close())
+	requireBatchUnusable(t, batch)
+
+	// A closed batch is discarded.
+	requireKeyValues(t, db, map[string][]byte{"a": {1}})
+}
+
+func requireBatchUnusable(t *testing.T, batch dbm.Batch) {
+	t.Helper()
+
+	require.Error(t, batch.Set([]byte("a"), []byte{9}))
+	require.Error(t, batch.Delete([]byte("a")))
+	require.Error(t, batch.DeleteRange([]byte("a"), []byte("b")))
+	require.Error(t, batch.Write())
+	require.Error(t, batch.WriteSync())
+	_, err := batch.GetByteSize()
+	require.Error(t, err)
+}
+
+func testDeleteRange(t *testing.T, db dbm.DB) {
+	reset := func() {
+		require.NoError(t, db.DeleteRange(nil, nil))
+		for _, k := range []string{"a", "b", "c", "d", "e"} {
+			require.NoError(t, db.Set([]byte(k), []byte(k)))
+		}
+	}
+	all := map[string][]byte{
+		"a": []byte("a"), "b": []byte("b"), "c": []byte("c"), "d": []byte("d"), "e": []byte("e"),
+	}
+
+	reset()
+	require.NoError(t, db.DeleteRange([]byte("b"), []byte("d")))
+	requireKeyValues(t, db, map[string][]byte{"a": []byte("a"), "d": []byte("d"), "e": []byte("e")})
+
+	reset()
+	require.NoError(t, db.DeleteRange(nil, []byte("c")))
+	requireKeyValues(t, db, map[string][]byte{"c": []byte("c"), "d": []byte("d"), "e": []byte("e")})
+
+	reset()
+	require.NoError(t, db.DeleteRange([]byte("c"), nil))
+	requireKeyValues(t, db, map[string][]byte{"a": []byte("a"), "b": []byte("b")})
+
+	// An inverted or empty range deletes nothing.
+	reset()
+	require.NoError(t, db.DeleteRange([]byte("d"), []byte("b")))
+	require.NoError(t, db.DeleteRange([]byte("x"), []byte("z")))
+	requireKeyValues(t, db, all)
+
+	// A range delete in a batch applies to the operations before it, but not after.
+	batch := db.NewBatch()
+	require.NoError(t, batch.Set([]byte("bb"), []byte("bb")))
+	require.NoError(t, batch.DeleteRange([]byte("b"), []byte("d")))
+	require.NoError(t, batch.Set([]byte("c"), []byte("x")))
+	requireKeyValues(t, db, all)
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	requireKeyValues(t, db, map[string][]byte{
+		"a": []byte("a"), "c": []byte("x"), "d": []byte("d"), "e": []byte("e"),
+	})
+
+	// An unbounded range delete in a batch covers keys set earlier in the batch past the last
+	// key in the database.
+	reset()
+	batch = db.NewBatch()
+	require.NoError(t, batch.Set([]byte("z"), []byte("z")))
+	require.NoError(t, batch.DeleteRange([]byte("c"), nil))
+	require.NoError(t, batch.Set([]byte("y"), []byte("y")))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	requireKeyValues(t, db, map[string][]byte{"a": []byte("a"), "b": []byte("b"), "y": []byte("y")})
+
+	// So does one in a batch over an empty domain in the database.
+	require.NoError(t, db.DeleteRange(nil, nil))
+	batch = db.NewBatch()
+	require.NoError(t, batch.Set([]byte("z"), []byte("z")))
+	require.NoError(t, batch.DeleteRange(nil, nil))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	requireKeyValues(t, db, map[string][]byte{})
+}
+
+func testSnapshot(t *testing.T, db dbm.DB) {
+	require.NoError(t, db.Set([]byte("a"), []byte{1}))
+	require.NoError(t, db.Set([]byte("b"), []byte{2}))
+	require.NoError(t, db.Set([]byte("c"), []byte{3}))
+
+	snap, err := db.NewSnapshot()
+	require.NoError(t, err)
+
+	// Writes after the snapshot was taken are not visible through it.
+	require.NoError(t, db.Set([]byte("a"), []byte{9}))
+	require.NoError(t, db.Delete([]byte("b")))
+	require.NoError(t, db.Set([]byte("d"), []byte{4}))
+
+	value, err := snap.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, value)
+	ok, err := snap.Has([]byte("b"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = snap.Has([]byte("d"))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = snap.Get([]byte{})
+	require.Error(t, err)
+	_, err = snap.Iterator([]byte{}, nil)
+	require.Error(t, err)
+
+	itr, err := snap.Iterator(nil, nil)
+	require.NoError(t, err)
+	requireKeys(t, itr, []string{"a", "b", "c"})
+	itr, err = snap.ReverseIterator([]byte("b"), nil)
+	require.NoError(t, err)
+	requireKeys(t, itr, []string{"c", "b"})
+
+	requireKeyValues(t, db, map[string][]byte{"a": {9}, "c": {3}, "d": {4}})
+
+	// Closing is idempotent, and nothing else can be called on a closed snapshot.
+	require.NoError(t, snap.Close())
+	require.NoError(t, snap.Close())
+	_, err = snap.Get([]byte("a"))
+	require.Error(t, err)
+	_, err = snap.Iterator(nil, nil)
+	require.Error(t, err)
+}
+
+func testPrefix(t *testing.T, db dbm.DB) {
+	for _, k := range []string{"a", "key", "key1", "key2", "key3", "kez", "z"} {
+		require.NoError(t, db.Set([]byte(k), []byte(k)))
+	}
+	pdb := dbm.NewPrefixDB(db, []byte("key"))
+
+	// Only keys under the prefix are visible, without the prefix.
+	requireValue(t, pdb, []byte("1"), []byte("key1"))
+	requireValue(t, pdb, []byte("key1"), nil)
+	requireValue(t, pdb, []byte("z"), nil)
+	requireKeyValues(t, pdb, map[string][]byte{
+		"1": []byte("key1"), "2": []byte("key2"), "3": []byte("key3"),
+	})
+
+	itr, err := pdb.ReverseIterator(nil, []byte("3"))
+	require.NoError(t, err)
+	requireKeys(t, itr, []string{"2", "1"})
+
+	// Writes through the prefix land under it.
+	require.NoError(t, pdb.Set([]byte("4"), []byte("key4")))
+	require.NoError(t, pdb.Delete([]byte("1")))
+	batch := pdb.NewBatch()
+	require.NoError(t, batch.Set([]byte("5"), []byte("key5")))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	requireValue(t, db, []byte("key4"), []byte("key4"))
+	requireValue(t, db, []byte("key5"), []byte("key5"))
+	requireValue(t, db, []byte("key1"), nil)
+
+	// Range deletes stay within the prefix, and never reach the key equal to the prefix.
+	require.NoError(t, pdb.DeleteRange(nil, nil))
+	requireKeyValues(t, pdb, map[string][]byte{})
+	requireKeyValues(t, db, map[string][]byte{
+		"a": []byte("a"), "key": []byte("key"), "kez": []byte("kez"), "z": []byte("z"),
+	})
+}
+
+// int642Bytes encodes i as a big-endian key, so that keys sort like the integers.
+func int642Bytes(i int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(i))
+	return buf
+}
+
+func bytes2Int64(buf []byte) int64 {
+	return int64(binary.BigEndian.Uint64(buf))
+}
+
+func requireValue(t *testing.T, db dbm.DB, key, want []byte) {
+	t.Helper()
+
+	value, err := db.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, want, value, "value of %q", key)
+}
+
+// requireKeyValues requires db to hold exactly the given keys and values.
+func requireKeyValues(t *testing.T, db dbm.DB, want map[string][]byte) {
+	t.Helper()
+
+	itr, err := db.Iterator(nil, nil)
+	require.NoError(t, err)
+	defer itr.Close()
+
+	got := make(map[string][]byte)
+	var last []byte
+	for ; itr.Valid(); itr.Next() {
+		key := itr.Key()
+		if last != nil {
+			require.Equal(t, 1, bytes.Compare(key, last), "iterator keys out of order")
+		}
+		last = append(last[:0], key...)
+		got[string(key)] = append([]byte{}, itr.Value()...)
+	}
+	require.NoError(t, itr.Error())
+	require.Equal(t, want, got)
+}
+
+// requireKeys requires itr to yield the given keys in order, and closes it.
+func requireKeys(t *testing.T, itr dbm.Iterator, want []string) {
+	t.Helper()
+
+	var got []string
+	for ; itr.Valid(); itr.Next() {
+		got = append(got, string(itr.Key()))
+	}
+	require.NoError(t, itr.Error())
+	require.NoError(t, itr.Close())
+	require.Equal(t, want, got)
+}
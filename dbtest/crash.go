@@ -0,0 +1,362 @@
+package dbtest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// Environment variables passing the workload of a crash recovery round to the child process.
+const (
+	envCrashTest = "COSMOS_DB_CRASH_TEST"
+	envCrashDir  = "COSMOS_DB_CRASH_DIR"
+	envCrashSeed = "COSMOS_DB_CRASH_SEED"
+)
+
+const (
+	// crashLinePrefix marks the lines the child writes to stdout for the parent.
+	crashLinePrefix = "cosmos-db-crash: "
+	// crashSyncedPrefix and crashUnsyncedPrefix are the key spaces of the synced and unsynced
+	// writes of a workload. Only the synced key space is checked after a crash.
+	crashSyncedPrefix   = "s/"
+	crashUnsyncedPrefix = "u/"
+	crashKeys           = 128
+	crashMaxValueLen    = 256
+	crashMaxBatchLen    = 8
+)
+
+// CrashOptions configures RunCrashRecovery.
+type CrashOptions struct {
+	// Seed seeds the workloads and kill points. 0 picks a seed from the clock; the seed is logged
+	// either way, so that a failure can be reproduced.
+	Seed int64
+	// Rounds is the number of times the workload is run and killed. It defaults to 3.
+	Rounds int
+	// MaxKillDelay bounds how long a workload runs before it is killed. It defaults to 200ms.
+	MaxKillDelay time.Duration
+	// StartTimeout bounds how long the child process may take to open the database. It defaults
+	// to 30s.
+	StartTimeout time.Duration
+}
+
+// RunCrashRecovery checks that writes acknowledged by SetSync, DeleteSync and Batch.WriteSync
+// survive a crash. Each round runs a random workload of synced and unsynced writes in a child
+// process, kills the process at a random point, reopens the database and checks that it holds
+// every synced write acknowledged before the kill. The write in flight at the kill may or may not
+// be there, but a batch must be there whole or not at all.
+//
+// open opens the database in dir, creating it if needed, and must behave the same in the parent
+// and the child. The child process is the test binary re-run for t alone, so the test calling
+// RunCrashRecovery must reach it the same way in every run, e.g. through subtests with fixed
+// names.
+func RunCrashRecovery(t *testing.T, open func(dir string) (dbm.DB, error), opts CrashOptions) {
+	t.Helper()
+
+	if name, ok := os.LookupEnv(envCrashTest); ok {
+		if name != t.Name() {
+			t.Skipf("crash recovery child of %s", name)
+		}
+		runCrashChild(open)
+		return
+	}
+	if testing.Short() {
+		t.Skip("skipping crash recovery in short mode")
+	}
+
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	if opts.Rounds == 0 {
+		opts.Rounds = 3
+	}
+	if opts.MaxKillDelay == 0 {
+		opts.MaxKillDelay = 200 * time.Millisecond
+	}
+	if opts.StartTimeout == 0 {
+		opts.StartTimeout = 30 * time.Second
+	}
+	t.Logf("crash recovery seed %d", opts.Seed)
+
+	dir := t.TempDir()
+	rng := rand.New(rand.NewSource(opts.Seed))
+	state := map[string][]byte{}
+	for round := 0; round < opts.Rounds; round++ {
+		seed := rng.Int63()
+		delay := time.Duration(rng.Int63n(int64(opts.MaxKillDelay)))
+		acked := runCrashRound(t, dir, seed, delay, opts.StartTimeout)
+
+		// Replay the workload up to the last acknowledged write, and the write in flight after it.
+		want := replayCrashWorkload(state, seed, acked)
+		inFlight := replayCrashWorkload(state, seed, acked+1)
+
+		db, err := open(dir)
+		require.NoError(t, err, "round %d (seed %d): reopening after the crash", round, seed)
+		got := readCrashState(t, db)
+		require.NoError(t, db.Close())
+
+		if !crashStatesEqual(got, want) && !crashStatesEqual(got, inFlight) {
+			require.Equal(t, want, got,
+				"round %d (seed %d): state after %d acknowledged synced writes", round, seed, acked)
+		}
+		t.Logf("round %d: %d synced writes acknowledged before the kill after %v", round, acked, delay)
+		state = got
+	}
+}
+
+// runCrashRound runs the workload seeded with seed in a child process, kills it delay after it
+// has opened the database, and returns the number of synced writes it acknowledged.
+func runCrashRound(t *testing.T, dir string, seed int64, delay, startTimeout time.Duration) int {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run="+crashTestPattern(t.Name())) //nolint:gosec // re-runs this test binary
+	cmd.Env = append(os.Environ(),
+		envCrashTest+"="+t.Name(),
+		envCrashDir+"="+dir,
+		envCrashSeed+"="+strconv.FormatInt(seed, 10),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	acked := 0
+	var childErr string
+	var output strings.Builder
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line, ok := strings.CutPrefix(scanner.Text(), crashLinePrefix)
+			if !ok {
+				output.WriteString(scanner.Text() + "\n")
+				continue
+			}
+			switch {
+			case line == "ready":
+				close(ready)
+			case strings.HasPrefix(line, "ack "):
+				acked, _ = strconv.Atoi(strings.TrimPrefix(line, "ack "))
+			default:
+				childErr = line
+			}
+		}
+		_, _ = io.Copy(io.Discard, stdout)
+	}()
+
+	var failure string
+	select {
+	case <-ready:
+		select {
+		case <-time.After(delay):
+		case <-done:
+		}
+	case <-done:
+	case <-time.After(startTimeout):
+		failure = fmt.Sprintf("child did not open the database within %v", startTimeout)
+	}
+	select {
+	case <-done:
+		failure = "child exited before it was killed"
+	default:
+	}
+	_ = cmd.Process.Kill()
+	<-done
+	_ = cmd.Wait()
+
+	if childErr != "" {
+		failure = "child failed: " + childErr
+	}
+	require.Empty(t, failure, "stdout:\n%s\nstderr:\n%s", output.String(), stderr.String())
+	return acked
+}
+
+// crashTestPattern returns the -test.run pattern matching the test name exactly.
+func crashTestPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return strings.Join(parts, "/")
+}
+
+// runCrashChild runs the workload passed in the environment until the process is killed.
+func runCrashChild(open func(dir string) (dbm.DB, error)) {
+	fail := func(err error) {
+		fmt.Printf("%serror %v\n", crashLinePrefix, err)
+		os.Exit(1)
+	}
+	seed, err := strconv.ParseInt(os.Getenv(envCrashSeed), 10, 64)
+	if err != nil {
+		fail(err)
+	}
+	db, err := open(os.Getenv(envCrashDir))
+	if err != nil {
+		fail(err)
+	}
+	fmt.Printf("%sready\n", crashLinePrefix)
+
+	workload := newCrashWorkload(seed)
+	for {
+		op := workload.next()
+		if err := op.apply(db); err != nil {
+			fail(err)
+		}
+		if op.sync {
+			fmt.Printf("%sack %d\n", crashLinePrefix, workload.synced)
+		}
+	}
+}
+
+// crashWrite sets key to value, or deletes it if value is nil.
+type crashWrite struct {
+	key   []byte
+	value []byte
+}
+
+// crashOp is a write of a workload: a single write, or a batch.
+type crashOp struct {
+	writes []crashWrite
+	batch  bool
+	sync   bool
+}
+
+// apply writes op to db.
+func (op crashOp) apply(db dbm.DB) error {
+	if !op.batch {
+		w := op.writes[0]
+		switch {
+		case w.value == nil && op.sync:
+			return db.DeleteSync(w.key)
+		case w.value == nil:
+			return db.Delete(w.key)
+		case op.sync:
+			return db.SetSync(w.key, w.value)
+		default:
+			return db.Set(w.key, w.value)
+		}
+	}
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	for _, w := range op.writes {
+		var err error
+		if w.value == nil {
+			err = batch.Delete(w.key)
+		} else {
+			err = batch.Set(w.key, w.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if op.sync {
+		return batch.WriteSync()
+	}
+	return batch.Write()
+}
+
+// crashWorkload generates the writes of a workload. The same seed generates the same writes, so
+// that the parent can replay what the child wrote.
+type crashWorkload struct {
+	rng *rand.Rand
+	// synced is the number of synced writes generated.
+	synced int
+}
+
+func newCrashWorkload(seed int64) *crashWorkload {
+	return &crashWorkload{rng: rand.New(rand.NewSource(seed))}
+}
+
+// next generates the next write. Synced writes go to the synced key space, and unsynced writes to
+// the unsynced one.
+func (w *crashWorkload) next() crashOp {
+	op := crashOp{
+		sync:  w.rng.Intn(2) == 0,
+		batch: w.rng.Intn(2) == 0,
+	}
+	prefix := crashUnsyncedPrefix
+	if op.sync {
+		prefix = crashSyncedPrefix
+		w.synced++
+	}
+	n := 1
+	if op.batch {
+		n = 1 + w.rng.Intn(crashMaxBatchLen)
+	}
+	for i := 0; i < n; i++ {
+		write := crashWrite{key: fmt.Appendf(nil, "%s%03d", prefix, w.rng.Intn(crashKeys))}
+		if w.rng.Intn(4) > 0 {
+			write.value = fmt.Appendf(nil, "%d:%d:", w.synced, i)
+			write.value = append(write.value, bytes.Repeat([]byte{byte(i)}, w.rng.Intn(crashMaxValueLen))...)
+		}
+		op.writes = append(op.writes, write)
+	}
+	return op
+}
+
+// replayCrashWorkload returns the synced key space of state after the first n synced writes of
+// the workload seeded with seed.
+func replayCrashWorkload(state map[string][]byte, seed int64, n int) map[string][]byte {
+	replayed := make(map[string][]byte, len(state))
+	for k, v := range state {
+		replayed[k] = v
+	}
+	workload := newCrashWorkload(seed)
+	for workload.synced < n {
+		op := workload.next()
+		if !op.sync {
+			continue
+		}
+		for _, w := range op.writes {
+			if w.value == nil {
+				delete(replayed, string(w.key))
+			} else {
+				replayed[string(w.key)] = w.value
+			}
+		}
+	}
+	return replayed
+}
+
+// readCrashState returns the synced key space of db.
+func readCrashState(t *testing.T, db dbm.DB) map[string][]byte {
+	t.Helper()
+
+	state := map[string][]byte{}
+	itr, err := db.Iterator([]byte(crashSyncedPrefix), []byte(crashUnsyncedPrefix))
+	require.NoError(t, err)
+	for ; itr.Valid(); itr.Next() {
+		state[string(itr.Key())] = bytes.Clone(itr.Value())
+	}
+	require.NoError(t, itr.Error())
+	require.NoError(t, itr.Close())
+	return state
+}
+
+func crashStatesEqual(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !bytes.Equal(v, w) {
+			return false
+		}
+	}
+	return true
+}
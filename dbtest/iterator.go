@@ -0,0 +1,157 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbm "github.com/cosmos/cosmos-db"
+)
+
+// iteratorKeys are the keys 0 to 9 without 6, so that the domain cases cover bounds on missing
+// keys as well as existing ones.
+var iteratorKeys = []int64{0, 1, 2, 3, 4, 5, 7, 8, 9}
+
+// setIteratorKeys writes iteratorKeys to db, each with its key as value.
+func setIteratorKeys(t *testing.T, db dbm.DB) {
+	t.Helper()
+
+	for _, i := range iteratorKeys {
+		require.NoError(t, db.Set(int642Bytes(i), int642Bytes(i)))
+	}
+}
+
+// key returns the key for i, or nil for -1, which stands for an unbounded end of a domain.
+func key(i int64) []byte {
+	if i < 0 {
+		return nil
+	}
+	return int642Bytes(i)
+}
+
+// iteratorCase is an iterator domain [start, end), where -1 is unbounded, and the keys it must
+// yield.
+type iteratorCase struct {
+	start, end int64
+	want       []int64
+}
+
+func testIterator(t *testing.T, db dbm.DB) {
+	setIteratorKeys(t, db)
+
+	for _, tc := range []iteratorCase{
+		{-1, -1, []int64{0, 1, 2, 3, 4, 5, 7, 8, 9}},
+		{-1, 0, nil},
+		{0, -1, []int64{0, 1, 2, 3, 4, 5, 7, 8, 9}},
+		{1, -1, []int64{1, 2, 3, 4, 5, 7, 8, 9}},
+		{10, -1, nil},
+		{5, 6, []int64{5}},
+		{5, 7, []int64{5}},
+		{5, 8, []int64{5, 7}},
+		{6, 7, nil},
+		{6, 8, []int64{7}},
+		{7, 8, []int64{7}},
+	} {
+		itr, err := db.Iterator(key(tc.start), key(tc.end))
+		require.NoError(t, err)
+		requireIterator(t, itr, tc, false)
+	}
+}
+
+func testReverseIterator(t *testing.T, db dbm.DB) {
+	setIteratorKeys(t, db)
+
+	for _, tc := range []iteratorCase{
+		{-1, -1, []int64{9, 8, 7, 5, 4, 3, 2, 1, 0}},
+		{-1, 10, []int64{9, 8, 7, 5, 4, 3, 2, 1, 0}},
+		{-1, 9, []int64{8, 7, 5, 4, 3, 2, 1, 0}},
+		{-1, 8, []int64{7, 5, 4, 3, 2, 1, 0}},
+		{10, -1, nil},
+		{6, -1, []int64{9, 8, 7}},
+		{5, -1, []int64{9, 8, 7, 5}},
+		{4, 5, []int64{4}},
+		{4, 6, []int64{5, 4}},
+		{4, 7, []int64{5, 4}},
+		{5, 6, []int64{5}},
+		{5, 7, []int64{5}},
+		{6, 7, nil},
+		{8, 9, []int64{8}},
+		{2, 4, []int64{3, 2}},
+		{4, 2, nil},
+	} {
+		itr, err := db.ReverseIterator(key(tc.start), key(tc.end))
+		require.NoError(t, err)
+		requireIterator(t, itr, tc, true)
+	}
+}
+
+// requireIterator checks the domain of itr and the keys and values it yields, and that it is
+// unusable once exhausted, before closing it.
+func requireIterator(t *testing.T, itr dbm.Iterator, tc iteratorCase, isReverse bool) {
+	t.Helper()
+
+	msg := "iterator over [%d, %d)"
+	if isReverse {
+		msg = "reverse " + msg
+	}
+	start, end := itr.Domain()
+	require.Equal(t, key(tc.start), start, msg, tc.start, tc.end)
+	require.Equal(t, key(tc.end), end, msg, tc.start, tc.end)
+
+	var got []int64
+	for ; itr.Valid(); itr.Next() {
+		require.Equal(t, itr.Key(), itr.Value(), msg, tc.start, tc.end)
+		got = append(got, bytes2Int64(itr.Key()))
+	}
+	require.Equal(t, tc.want, got, msg, tc.start, tc.end)
+	require.NoError(t, itr.Error())
+
+	require.Panics(t, func() { itr.Key() }, "Key on an invalid iterator should panic")
+	require.Panics(t, func() { itr.Value() }, "Value on an invalid iterator should panic")
+	require.Panics(t, func() { itr.Next() }, "Next on an invalid iterator should panic")
+	require.NoError(t, itr.Close())
+}
+
+func testIteratorEmptyDB(t *testing.T, db dbm.DB) {
+	itr, err := db.Iterator(nil, nil)
+	require.NoError(t, err)
+	requireIterator(t, itr, iteratorCase{-1, -1, nil}, false)
+
+	itr, err = db.ReverseIterator(nil, nil)
+	require.NoError(t, err)
+	requireIterator(t, itr, iteratorCase{-1, -1, nil}, true)
+}
+
+func testIteratorSeek(t *testing.T, db dbm.DB) {
+	for i := int64(0); i < 10; i += 2 {
+		require.NoError(t, db.Set(int642Bytes(i), []byte{}))
+	}
+	seek := func(itr dbm.Iterator, target int64) []int64 {
+		itr.Seek(int642Bytes(target))
+		var got []int64
+		for ; itr.Valid(); itr.Next() {
+			got = append(got, bytes2Int64(itr.Key()))
+		}
+		require.NoError(t, itr.Error())
+		return got
+	}
+
+	// Forward iterators seek to the first key >= the seek key, clamped to the domain.
+	itr, err := db.Iterator(int642Bytes(2), int642Bytes(8))
+	require.NoError(t, err)
+	require.Equal(t, []int64{4, 6}, seek(itr, 3), "forward seek between keys")
+	require.Equal(t, []int64{4, 6}, seek(itr, 4), "forward seek of an exhausted iterator")
+	require.Equal(t, []int64{2, 4, 6}, seek(itr, 0), "forward seek before the domain")
+	require.Equal(t, []int64(nil), seek(itr, 8), "forward seek to the end of the domain")
+	require.Panics(t, func() { itr.Seek([]byte{}) }, "seeking to an empty key should panic")
+	require.NoError(t, itr.Close())
+
+	// Reverse iterators seek to the last key < the seek key, clamped to the domain.
+	itr, err = db.ReverseIterator(int642Bytes(2), int642Bytes(8))
+	require.NoError(t, err)
+	require.Equal(t, []int64{4, 2}, seek(itr, 5), "reverse seek between keys")
+	require.Equal(t, []int64{2}, seek(itr, 4), "reverse seek to a key")
+	require.Equal(t, []int64{6, 4, 2}, seek(itr, 9), "reverse seek past the domain")
+	require.Equal(t, []int64(nil), seek(itr, 2), "reverse seek to the start of the domain")
+	require.NoError(t, itr.Close())
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// diffBufferSize is the number of entries Diff buffers ahead of its reader.
+const diffBufferSize = 64
+
+// DiffKind is the kind of a difference between two databases.
+type DiffKind int
+
+const (
+	// DiffAdded is a key which only the second database holds.
+	DiffAdded DiffKind = iota + 1
+	// DiffRemoved is a key which only the first database holds.
+	DiffRemoved
+	// DiffChanged is a key which both databases hold, with different values.
+	DiffChanged
+)
+
+// String implements fmt.Stringer.
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	default:
+		return fmt.Sprintf("DiffKind(%d)", int(k))
+	}
+}
+
+// DiffEntry is a key which differs between two databases, as reported by Diff.
+type DiffEntry struct {
+	Kind DiffKind
+	Key  []byte
+	// A and B are the values of the key in the first and second database, nil where it is absent.
+	A, B []byte
+	// Err is set on the last entry if the comparison failed, in which case the entry holds no key.
+	Err error
+}
+
+// Diff compares the keys and values of a and b in the domain [start, end), and sends each key
+// which differs on the returned channel in key order, closing it when done. A nil start or end
+// leaves the domain unbounded at that end. Both databases are read from snapshots, so that the
+// differences are those between two single points in time. If the comparison fails, the last
+// entry sent carries the error. The channel must be read until it is closed, which releases the
+// snapshots; use DiffContext to stop early.
+func Diff(a, b DB, start, end []byte) <-chan DiffEntry {
+	return DiffContext(context.Background(), a, b, start, end)
+}
+
+// DiffContext is like Diff, but stops comparing once ctx is done: the snapshots are released and
+// the channel closed, without an error entry. A reader which cancels ctx must still read the
+// channel until it is closed before closing a or b, but only receives the few entries already
+// buffered; ctx.Err tells whether the comparison finished.
+func DiffContext(ctx context.Context, a, b DB, start, end []byte) <-chan DiffEntry {
+	ch := make(chan DiffEntry, diffBufferSize)
+	go func() {
+		defer close(ch)
+		send := func(entry DiffEntry) bool {
+			// select picks a ready case at random, so an entry could still be sent after ctx is
+			// done while the channel has room.
+			if ctx.Err() != nil {
+				return false
+			}
+			select {
+			case ch <- entry:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if err := diffSnapshots(a, b, start, end, send); err != nil {
+			send(DiffEntry{Err: err})
+		}
+	}()
+	return ch
+}
+
+// diffSnapshots compares snapshots of a and b in [start, end) with diffIterators.
+func diffSnapshots(a, b DB, start, end []byte, fn func(DiffEntry) bool) error {
+	snapA, err := a.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapA.Close()
+	snapB, err := b.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapB.Close()
+
+	itrA, err := snapA.Iterator(start, end)
+	if err != nil {
+		return err
+	}
+	defer itrA.Close()
+	itrB, err := snapB.Iterator(start, end)
+	if err != nil {
+		return err
+	}
+	defer itrB.Close()
+	return diffIterators(itrA, itrB, fn)
+}
+
+// diffIterators walks itrA and itrB in lockstep, and calls fn with each key which differs between
+// them, in key order, until fn returns false.
+func diffIterators(itrA, itrB Iterator, fn func(DiffEntry) bool) error {
+	for itrA.Valid() || itrB.Valid() {
+		var entry DiffEntry
+		var c int
+		switch {
+		case !itrA.Valid():
+			c = 1
+		case !itrB.Valid():
+			c = -1
+		default:
+			c = bytes.Compare(itrA.Key(), itrB.Key())
+		}
+		switch {
+		case c < 0:
+			entry = DiffEntry{Kind: DiffRemoved, Key: cp(itrA.Key()), A: cp(itrA.Value())}
+			itrA.Next()
+		case c > 0:
+			entry = DiffEntry{Kind: DiffAdded, Key: cp(itrB.Key()), B: cp(itrB.Value())}
+			itrB.Next()
+		case !bytes.Equal(itrA.Value(), itrB.Value()):
+			entry = DiffEntry{Kind: DiffChanged, Key: cp(itrA.Key()), A: cp(itrA.Value()), B: cp(itrB.Value())}
+			itrA.Next()
+			itrB.Next()
+		default:
+			itrA.Next()
+			itrB.Next()
+			continue
+		}
+		if !fn(entry) {
+			break
+		}
+	}
+	if err := itrA.Error(); err != nil {
+		return err
+	}
+	return itrB.Error()
+}
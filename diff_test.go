@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testDiff(t, dbType)
+		})
+	}
+}
+
+func testDiff(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	defer db.Close()
+
+	mem := NewMemDB()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), int642Bytes(int64(i))))
+		require.NoError(t, mem.Set(int642Bytes(int64(i)), int642Bytes(int64(i))))
+	}
+	require.Empty(t, collectDiff(t, db, mem, nil, nil))
+
+	require.NoError(t, mem.Delete(int642Bytes(0)))
+	require.NoError(t, mem.Set(int642Bytes(10), []byte{}))
+	require.NoError(t, mem.Set(append(int642Bytes(10), 0x00), []byte{1}))
+	require.NoError(t, mem.Set(int642Bytes(99), []byte{2}))
+	require.NoError(t, mem.Set(int642Bytes(100), []byte{3}))
+
+	require.Equal(t, []DiffEntry{
+		{Kind: DiffRemoved, Key: int642Bytes(0), A: int642Bytes(0)},
+		{Kind: DiffChanged, Key: int642Bytes(10), A: int642Bytes(10), B: []byte{}},
+		{Kind: DiffAdded, Key: append(int642Bytes(10), 0x00), B: []byte{1}},
+		{Kind: DiffChanged, Key: int642Bytes(99), A: int642Bytes(99), B: []byte{2}},
+		{Kind: DiffAdded, Key: int642Bytes(100), B: []byte{3}},
+	}, collectDiff(t, db, mem, nil, nil))
+
+	// Swapping the databases swaps the kinds, and a domain bounds the keys compared.
+	require.Equal(t, []DiffEntry{
+		{Kind: DiffChanged, Key: int642Bytes(10), A: []byte{}, B: int642Bytes(10)},
+		{Kind: DiffRemoved, Key: append(int642Bytes(10), 0x00), A: []byte{1}},
+	}, collectDiff(t, mem, db, int642Bytes(1), int642Bytes(11)))
+}
+
+// collectDiff returns the entries of Diff(a, b, start, end), requiring none to carry an error.
+func collectDiff(t *testing.T, a, b DB, start, end []byte) []DiffEntry {
+	t.Helper()
+
+	var entries []DiffEntry
+	for entry := range Diff(a, b, start, end) {
+		require.NoError(t, entry.Err)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// snapshotErrDB is a DB whose snapshots cannot be taken.
+type snapshotErrDB struct {
+	DB
+}
+
+var errSnapshot = errors.New("snapshot failed")
+
+func (snapshotErrDB) NewSnapshot() (Snapshot, error) {
+	return nil, errSnapshot
+}
+
+func TestDiffError(t *testing.T) {
+	var entries []DiffEntry
+	for entry := range Diff(NewMemDB(), snapshotErrDB{NewMemDB()}, nil, nil) {
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 1)
+	require.ErrorIs(t, entries[0].Err, errSnapshot)
+	require.Equal(t, "removed", DiffRemoved.String())
+}
+
+// releaseDB is a DB which signals on released when a snapshot of it is closed.
+type releaseDB struct {
+	DB
+	released chan struct{}
+}
+
+type releaseSnapshot struct {
+	Snapshot
+	released chan struct{}
+}
+
+func (db releaseDB) NewSnapshot() (Snapshot, error) {
+	snapshot, err := db.DB.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return releaseSnapshot{Snapshot: snapshot, released: db.released}, nil
+}
+
+func (s releaseSnapshot) Close() error {
+	defer close(s.released)
+	return s.Snapshot.Close()
+}
+
+func TestDiffContext(t *testing.T) {
+	a := releaseDB{DB: NewMemDB(), released: make(chan struct{})}
+	for i := 0; i < 10*diffBufferSize; i++ {
+		require.NoError(t, a.Set(int642Bytes(int64(i)), []byte{1}))
+	}
+
+	// A reader which stops early cancels the comparison, which releases the snapshots without
+	// the channel being drained.
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := DiffContext(ctx, a, NewMemDB(), nil, nil)
+	entry := <-ch
+	require.NoError(t, entry.Err)
+	require.Equal(t, int642Bytes(0), entry.Key)
+	cancel()
+	select {
+	case <-a.released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("snapshot not released after cancellation")
+	}
+}
+
+func TestDiffContextClose(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			name := fmt.Sprintf("test_%x", randStr(12))
+			dir := os.TempDir()
+			db, err := NewDB(name, dbType, dir)
+			require.NoError(t, err)
+			defer cleanupDBDir(dir, name)
+			for i := 0; i < 10*diffBufferSize; i++ {
+				require.NoError(t, db.Set(int642Bytes(int64(i)), []byte{1}))
+			}
+
+			// Once cancelled, the comparison sends at most the entries already buffered, and the
+			// database can be closed when the channel is, with no iterator left open.
+			ctx, cancel := context.WithCancel(context.Background())
+			ch := DiffContext(ctx, db, NewMemDB(), nil, nil)
+			<-ch
+			cancel()
+			var received int
+			for range ch {
+				received++
+			}
+			require.LessOrEqual(t, received, diffBufferSize)
+			require.NoError(t, db.Close())
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package db
+
+const (
+	// estimateSampleKeys is the number of keys estimateKeys counts between checks of the size of
+	// what it has counted.
+	estimateSampleKeys = 1000
+	// estimateMaxSampleKeys is the number of keys after which estimateKeys stops counting.
+	estimateMaxSampleKeys = 10 * estimateSampleKeys
+)
+
+// estimateKeys estimates the number of keys in [start, end) for backends which can estimate the
+// size of a range on disk, but not the number of keys in it. It counts keys from start until the
+// keys counted take up some, but not all, of the size of the range, and extrapolates from their
+// density. Small ranges end up counted exactly. Keys not yet flushed take up no size on disk, so
+// once estimateMaxSampleKeys keys have been counted without taking up any, the count is
+// extrapolated from their density over the key space of the range instead, with extrapolateKeys.
+func estimateKeys(db DB, sizeOf func(start, end []byte) (uint64, error), start, end []byte) (uint64, error) {
+	total, err := sizeOf(start, end)
+	if err != nil {
+		return 0, err
+	}
+	itr, err := db.Iterator(start, end)
+	if err != nil {
+		return 0, err
+	}
+	defer itr.Close()
+
+	var keys uint64
+	var first []byte
+	for ; itr.Valid(); itr.Next() {
+		if first == nil {
+			first = cp(itr.Key())
+		}
+		keys++
+		if keys == estimateMaxSampleKeys {
+			return extrapolateKeys(db, start, end, first, itr.Key(), keys)
+		}
+		if keys%estimateSampleKeys != 0 {
+			continue
+		}
+		counted, err := sizeOf(start, append(cp(itr.Key()), 0x00))
+		if err != nil {
+			return 0, err
+		}
+		if counted > 0 && counted < total {
+			return uint64(float64(keys) * float64(total) / float64(counted)), nil
+		}
+	}
+	return keys, itr.Error()
+}
+
+// sampleRange estimates the number of keys in [start, end) of db, and the number of bytes of their
+// keys and values, for backends without statistics of key ranges. Ranges of up to
+// estimateMaxSampleKeys keys are counted exactly; larger ones are extrapolated from their first
+// estimateMaxSampleKeys keys, with extrapolateKeys.
+func sampleRange(db DB, start, end []byte) (keys, size uint64, err error) {
+	itr, err := db.Iterator(start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer itr.Close()
+
+	var first []byte
+	for ; itr.Valid(); itr.Next() {
+		if first == nil {
+			first = cp(itr.Key())
+		}
+		keys++
+		size += uint64(len(itr.Key()) + len(itr.Value()))
+		if keys < estimateMaxSampleKeys {
+			continue
+		}
+		total, err := extrapolateKeys(db, start, end, first, itr.Key(), keys)
+		if err != nil {
+			return 0, 0, err
+		}
+		return total, uint64(float64(size) * float64(total) / float64(keys)), nil
+	}
+	return keys, size, itr.Error()
+}
+
+// extrapolateKeys extrapolates the keys counted in [first, key] to all of [start, end), whose
+// first key is first, assuming that the keys of the range are spread evenly over the key space
+// between its first and last keys. It returns at least counted.
+func extrapolateKeys(db DB, start, end, first, key []byte, counted uint64) (uint64, error) {
+	itr, err := db.ReverseIterator(start, end)
+	if err != nil {
+		return 0, err
+	}
+	defer itr.Close()
+	if !itr.Valid() {
+		return counted, itr.Error()
+	}
+
+	covered := keySpaceFraction(first, key, itr.Key())
+	if covered <= 0 || covered >= 1 {
+		return counted, nil
+	}
+	return uint64(float64(counted) / covered), nil
+}
+
+// keySpaceFraction returns the fraction of the key space between first and last which lies
+// between first and key, reading the keys after their common prefix as base-256 fractions.
+func keySpaceFraction(first, key, last []byte) float64 {
+	n := 0
+	for n < len(first) && n < len(last) && first[n] == last[n] {
+		n++
+	}
+	lo, hi := keyFraction(first[n:]), keyFraction(last[n:])
+	if hi <= lo || len(key) < n {
+		return 0
+	}
+	return (keyFraction(key[n:]) - lo) / (hi - lo)
+}
+
+// keyFraction reads the first 8 bytes of key as a base-256 fraction in [0, 1).
+func keyFraction(key []byte) float64 {
+	var f float64
+	scale := 1.0
+	for _, b := range key[:min(len(key), 8)] {
+		scale /= 256
+		f += float64(b) * scale
+	}
+	return f
+}
+
+// countRange returns the exact number of keys in [start, end) of db, and the number of bytes of
+// their keys and values, for backends without statistics to estimate them from.
+func countRange(db DB, start, end []byte) (keys, size uint64, err error) {
+	itr, err := db.Iterator(start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer itr.Close()
+
+	for ; itr.Valid(); itr.Next() {
+		keys++
+		size += uint64(len(itr.Key()) + len(itr.Value()))
+	}
+	return keys, size, itr.Error()
+}
+
+// lastKey returns the largest key in db, or nil if it is empty.
+func lastKey(db DB) ([]byte, error) {
+	itr, err := db.ReverseIterator(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+
+	if !itr.Valid() {
+		return nil, itr.Error()
+	}
+	return cp(itr.Key()), nil
+}
@@ -0,0 +1,327 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// An export stream starts with exportMagic, the format version and a flags byte. The rest of the
+// stream, compressed with zstd if exportFlagZstd is set, is a sequence of chunks, each made of
+// its length as a uvarint, its payload, and a CRC-32C, big-endian, of its payload chained to the
+// CRC of the chunk before it. The first chunk holds the ExportHeader as JSON, the next ones the
+// key and value of pairs, each prefixed with its length as a uvarint, and an empty chunk marks
+// the end of the pairs. A last chunk holds the number of pairs as a uvarint, so that Export
+// counts them as it writes them rather than in a pass of its own.
+const (
+	exportMagic    = "CDBX"
+	exportVersion  = 1
+	exportFlagZstd = 1 << 0
+	// exportMaxHeaderLen bounds the header read by Import, so that a corrupt length is not
+	// mistaken for a huge header.
+	exportMaxHeaderLen = 1 << 20
+	// exportChunkSize is the payload size at which Export starts a new chunk. Import checks each
+	// chunk before writing its pairs as a batch.
+	exportChunkSize = 4 << 20
+)
+
+var exportCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// backendTyper is implemented by the backends, and by the wrappers of this module, which forward
+// it to the DB they wrap, so that Export can name the backend without computing its stats.
+type backendTyper interface {
+	backendType() BackendType
+}
+
+// backendOf returns the backend of db, or "" for a DB from outside this module.
+func backendOf(db DB) BackendType {
+	if typer, ok := db.(backendTyper); ok {
+		return typer.backendType()
+	}
+	return ""
+}
+
+// ErrCorruptExport is returned by Import for a stream which is truncated, fails its checksum, or
+// is not an export stream at all.
+var ErrCorruptExport = errors.New("corrupt export stream")
+
+// ExportHeader describes the contents of an export stream.
+type ExportHeader struct {
+	// Backend is the backend of the exported database.
+	Backend BackendType `json:"backend"`
+	// Keys is the number of key/value pairs in the stream. It is recorded at the end of the
+	// stream, not in the header chunk.
+	Keys uint64 `json:"-"`
+	// Start and End are the domain [start, end) which was exported, nil if unbounded.
+	Start []byte `json:"start,omitempty"`
+	End   []byte `json:"end,omitempty"`
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// Compress compresses the stream with zstd.
+	Compress bool
+}
+
+// Export writes the key/value pairs of db in the domain [start, end) to w, as a stream which
+// Import can read into a database of any backend. A nil start or end leaves the domain unbounded
+// at that end. The pairs are read from a snapshot, so that the stream is consistent with a single
+// point in time.
+func Export(db DB, w io.Writer, start, end []byte, opts ExportOptions) (ExportHeader, error) {
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		return ExportHeader{}, err
+	}
+	defer snap.Close()
+
+	header := ExportHeader{Backend: backendOf(db), Start: start, End: end}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return ExportHeader{}, err
+	}
+
+	var flags byte
+	if opts.Compress {
+		flags |= exportFlagZstd
+	}
+	if _, err := w.Write(append([]byte(exportMagic), exportVersion, flags)); err != nil {
+		return ExportHeader{}, err
+	}
+	body := w
+	var zw *zstd.Encoder
+	if opts.Compress {
+		if zw, err = zstd.NewWriter(w); err != nil {
+			return ExportHeader{}, err
+		}
+		defer zw.Close()
+		body = zw
+	}
+	ew := &exportWriter{w: bufio.NewWriter(body)}
+
+	ew.writeBytes(headerJSON)
+	ew.flushChunk()
+	itr, err := snap.Iterator(start, end)
+	if err != nil {
+		return ExportHeader{}, err
+	}
+	defer itr.Close()
+	for ; itr.Valid() && ew.err == nil; itr.Next() {
+		ew.writeBytes(itr.Key())
+		ew.writeBytes(itr.Value())
+		header.Keys++
+		if ew.chunk.Len() >= exportChunkSize {
+			ew.flushChunk()
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return ExportHeader{}, err
+	}
+	if err := ew.finish(header.Keys); err != nil {
+		return ExportHeader{}, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return ExportHeader{}, err
+		}
+	}
+	return header, nil
+}
+
+// Import reads a stream written by Export into db, and returns its header. Pairs overwrite any
+// existing values of their keys. They are written in batches, one per chunk of the stream, each
+// written only once its chunk and the chunk after it have passed their checksums, so an import
+// which fails may have written part of the stream, but never pairs which failed a checksum. The
+// last batch is written with WriteSync, once the whole stream has been checked.
+func Import(db DB, r io.Reader) (ExportHeader, error) {
+	prefix := make([]byte, len(exportMagic)+2)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return ExportHeader{}, fmt.Errorf("%w: %w", ErrCorruptExport, err)
+	}
+	if string(prefix[:len(exportMagic)]) != exportMagic {
+		return ExportHeader{}, fmt.Errorf("%w: not an export stream", ErrCorruptExport)
+	}
+	if version := prefix[len(exportMagic)]; version != exportVersion {
+		return ExportHeader{}, fmt.Errorf("unsupported export version %d", version)
+	}
+	flags := prefix[len(exportMagic)+1]
+	if flags&^exportFlagZstd != 0 {
+		return ExportHeader{}, fmt.Errorf("unsupported export flags %#x", flags)
+	}
+	if flags&exportFlagZstd != 0 {
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return ExportHeader{}, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	er := &exportReader{r: bufio.NewReader(r)}
+
+	var header ExportHeader
+	chunk, err := er.readChunk(exportMaxHeaderLen + binary.MaxVarintLen64)
+	if err != nil {
+		return ExportHeader{}, err
+	}
+	headerJSON, rest, err := chunkField(chunk)
+	if err != nil {
+		return ExportHeader{}, err
+	}
+	if len(rest) > 0 {
+		return ExportHeader{}, fmt.Errorf("%w: invalid header chunk", ErrCorruptExport)
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return ExportHeader{}, fmt.Errorf("%w: invalid header: %w", ErrCorruptExport, err)
+	}
+
+	// batch holds the pairs of the last chunk read, written once the next one has been checked.
+	var batch Batch
+	defer func() {
+		if batch != nil {
+			_ = batch.Close()
+		}
+	}()
+	var keys uint64
+	for {
+		chunk, err := er.readChunk(0)
+		if err != nil {
+			return header, err
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		if batch != nil {
+			if err := batch.Write(); err != nil {
+				return header, err
+			}
+			_ = batch.Close()
+		}
+		batch = db.NewBatch()
+		for len(chunk) > 0 {
+			var key, value []byte
+			if key, chunk, err = chunkField(chunk); err != nil {
+				return header, err
+			}
+			if value, chunk, err = chunkField(chunk); err != nil {
+				return header, err
+			}
+			if err := batch.Set(key, value); err != nil {
+				return header, err
+			}
+			keys++
+		}
+	}
+
+	chunk, err = er.readChunk(binary.MaxVarintLen64)
+	if err != nil {
+		return header, err
+	}
+	n, k := binary.Uvarint(chunk)
+	if k <= 0 || k != len(chunk) {
+		return header, fmt.Errorf("%w: invalid key count", ErrCorruptExport)
+	}
+	if header.Keys = n; keys != header.Keys {
+		return header, fmt.Errorf("%w: read %d keys, stream says %d", ErrCorruptExport, keys, header.Keys)
+	}
+	if batch == nil {
+		return header, nil
+	}
+	return header, batch.WriteSync()
+}
+
+// exportWriter writes the chunks of an export stream, chaining their checksums. The first error is
+// kept, and stops all further writes.
+type exportWriter struct {
+	w *bufio.Writer
+	// chunk is the payload of the chunk being written.
+	chunk bytes.Buffer
+	crc   uint32
+	buf   [binary.MaxVarintLen64]byte
+	err   error
+}
+
+// writeBytes adds p, prefixed with its length, to the current chunk.
+func (ew *exportWriter) writeBytes(p []byte) {
+	ew.chunk.Write(ew.buf[:binary.PutUvarint(ew.buf[:], uint64(len(p)))])
+	ew.chunk.Write(p)
+}
+
+func (ew *exportWriter) write(p []byte) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = ew.w.Write(p)
+}
+
+// flushChunk writes the current chunk, and starts a new one.
+func (ew *exportWriter) flushChunk() {
+	payload := ew.chunk.Bytes()
+	ew.crc = crc32.Update(ew.crc, exportCRCTable, payload)
+	ew.write(ew.buf[:binary.PutUvarint(ew.buf[:], uint64(len(payload)))])
+	ew.write(payload)
+	ew.write(binary.BigEndian.AppendUint32(nil, ew.crc))
+	ew.chunk.Reset()
+}
+
+// finish writes the current chunk, if it holds anything, the empty chunk ending the pairs and the
+// chunk holding their number, and flushes the stream.
+func (ew *exportWriter) finish(keys uint64) error {
+	if ew.chunk.Len() > 0 {
+		ew.flushChunk()
+	}
+	ew.flushChunk()
+	ew.chunk.Write(ew.buf[:binary.PutUvarint(ew.buf[:], keys)])
+	ew.flushChunk()
+	if ew.err != nil {
+		return ew.err
+	}
+	return ew.w.Flush()
+}
+
+// exportReader reads the chunks of an export stream, checking their chained checksums.
+type exportReader struct {
+	r   *bufio.Reader
+	crc uint32
+}
+
+// readChunk reads the payload of the next chunk, no longer than maxLen if it is not 0, and checks
+// its checksum. The payload is read as it arrives rather than allocated up front, so that a
+// corrupt length cannot exhaust memory.
+func (er *exportReader) readChunk(maxLen uint64) ([]byte, error) {
+	n, err := binary.ReadUvarint(er.r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruptExport, err)
+	}
+	if (maxLen > 0 && n > maxLen) || n > math.MaxInt64 {
+		return nil, fmt.Errorf("%w: chunk of %d bytes is too long", ErrCorruptExport, n)
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, er.r, int64(n)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruptExport, err)
+	}
+	var trailer [4]byte
+	if _, err := io.ReadFull(er.r, trailer[:]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruptExport, err)
+	}
+	er.crc = crc32.Update(er.crc, exportCRCTable, buf.Bytes())
+	if binary.BigEndian.Uint32(trailer[:]) != er.crc {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptExport)
+	}
+	return buf.Bytes(), nil
+}
+
+// chunkField returns the length-prefixed field at the start of chunk, and the rest of the chunk.
+func chunkField(chunk []byte) (field, rest []byte, err error) {
+	n, k := binary.Uvarint(chunk)
+	if k <= 0 || n > uint64(len(chunk)-k) {
+		return nil, nil, fmt.Errorf("%w: invalid field", ErrCorruptExport)
+	}
+	return chunk[k : k+int(n)], chunk[k+int(n):], nil
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testExportImport(t, dbType)
+		})
+	}
+}
+
+func testExportImport(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	defer db.Close()
+
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), []byte(randStr(1+i%100))))
+	}
+	require.NoError(t, db.Set(int642Bytes(1000), []byte{}))
+
+	for _, opts := range []ExportOptions{{}, {Compress: true}} {
+		var buf bytes.Buffer
+		header, err := Export(db, &buf, nil, nil, opts)
+		require.NoError(t, err)
+		require.EqualValues(t, 1001, header.Keys)
+		if backend == "prefixdb" {
+			// the test PrefixDB wraps a MemDB
+			require.Equal(t, MemDBBackend, header.Backend)
+		} else {
+			require.Equal(t, backend, header.Backend)
+		}
+
+		dst := NewMemDB()
+		imported, err := Import(dst, &buf)
+		require.NoError(t, err)
+		require.Equal(t, header, imported)
+		requireSameContents(t, db, dst, nil, nil)
+	}
+
+	// A domain exports only its keys, into a database which already has others.
+	var buf bytes.Buffer
+	header, err := Export(db, &buf, int642Bytes(100), int642Bytes(200), ExportOptions{Compress: true})
+	require.NoError(t, err)
+	require.EqualValues(t, 100, header.Keys)
+	require.Equal(t, int642Bytes(100), header.Start)
+
+	dst := NewMemDB()
+	require.NoError(t, dst.Set([]byte("other"), []byte{1}))
+	_, err = Import(dst, &buf)
+	require.NoError(t, err)
+	requireSameContents(t, db, dst, int642Bytes(100), int642Bytes(200))
+	value, err := dst.Get([]byte("other"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, value)
+}
+
+// requireSameContents requires a and b to hold the same keys and values in [start, end).
+func requireSameContents(t *testing.T, a, b DB, start, end []byte) {
+	t.Helper()
+
+	itrA, err := a.Iterator(start, end)
+	require.NoError(t, err)
+	defer itrA.Close()
+	itrB, err := b.Iterator(start, end)
+	require.NoError(t, err)
+	defer itrB.Close()
+
+	for ; itrA.Valid(); itrA.Next() {
+		require.True(t, itrB.Valid(), "missing key %x", itrA.Key())
+		require.Equal(t, itrA.Key(), itrB.Key())
+		require.Equal(t, itrA.Value(), itrB.Value())
+		itrB.Next()
+	}
+	require.False(t, itrB.Valid())
+	require.NoError(t, itrA.Error())
+	require.NoError(t, itrB.Error())
+}
+
+func TestImportCorrupt(t *testing.T) {
+	db := NewMemDB()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), []byte(randStr(10))))
+	}
+
+	for _, opts := range []ExportOptions{{}, {Compress: true}} {
+		var buf bytes.Buffer
+		_, err := Export(db, &buf, nil, nil, opts)
+		require.NoError(t, err)
+		stream := buf.Bytes()
+
+		// Truncated streams, at any point, are caught.
+		for _, n := range []int{0, 3, len(exportMagic) + 2, len(stream) / 2, len(stream) - 1} {
+			_, err := Import(NewMemDB(), bytes.NewReader(stream[:n]))
+			require.ErrorIs(t, err, ErrCorruptExport, "stream truncated to %d bytes", n)
+		}
+
+		if !opts.Compress {
+			// A flipped bit in a value is caught by the checksum.
+			corrupt := bytes.Clone(stream)
+			corrupt[len(corrupt)-10] ^= 0x01
+			_, err := Import(NewMemDB(), bytes.NewReader(corrupt))
+			require.ErrorIs(t, err, ErrCorruptExport)
+		}
+	}
+
+	_, err := Import(NewMemDB(), bytes.NewReader([]byte("not an export stream")))
+	require.ErrorIs(t, err, ErrCorruptExport)
+
+	// A stream whose key count, at its end, disagrees with its pairs is caught.
+	var buf bytes.Buffer
+	buf.Write(append([]byte(exportMagic), exportVersion, 0))
+	ew := &exportWriter{w: bufio.NewWriter(&buf)}
+	ew.writeBytes([]byte("{}"))
+	ew.flushChunk()
+	ew.writeBytes([]byte("key"))
+	ew.writeBytes([]byte("value"))
+	require.NoError(t, ew.finish(2))
+	_, err = Import(NewMemDB(), &buf)
+	require.ErrorIs(t, err, ErrCorruptExport)
+	require.ErrorContains(t, err, "read 1 keys, stream says 2")
+}
+
+func TestImportCorruptChunk(t *testing.T) {
+	// A stream of several chunks, with a flipped bit in one of them.
+	db := NewMemDB()
+	value := bytes.Repeat([]byte{0xab}, 1024)
+	for i := 0; i < 3*exportChunkSize/len(value); i++ {
+		require.NoError(t, db.Set(int642Bytes(int64(i)), value))
+	}
+	var buf bytes.Buffer
+	_, err := Export(db, &buf, nil, nil, ExportOptions{})
+	require.NoError(t, err)
+	stream := buf.Bytes()
+	require.Greater(t, len(stream), 2*exportChunkSize)
+
+	for _, offset := range []int{exportChunkSize / 2, 2 * exportChunkSize, len(stream) - 10} {
+		corrupt := bytes.Clone(stream)
+		corrupt[offset] ^= 0x01
+		dst := NewMemDB()
+		_, err := Import(dst, bytes.NewReader(corrupt))
+		require.ErrorIs(t, err, ErrCorruptExport, "bit flipped at %d", offset)
+
+		// Only pairs which passed their checksum were written.
+		itr, err := dst.Iterator(nil, nil)
+		require.NoError(t, err)
+		for ; itr.Valid(); itr.Next() {
+			require.Equal(t, value, itr.Value())
+		}
+		require.NoError(t, itr.Close())
+	}
+
+	dst := NewMemDB()
+	_, err = Import(dst, bytes.NewReader(stream))
+	require.NoError(t, err)
+	requireSameContents(t, db, dst, nil, nil)
+}
@@ -0,0 +1,474 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+)
+
+// ErrInjectedFault is the error returned by a FaultDB for a fault without an error of its own.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultOp is an operation a FaultDB can inject faults into.
+type FaultOp string
+
+// Operations of a FaultDB.
+const (
+	FaultOpGet            FaultOp = "get"
+	FaultOpHas            FaultOp = "has"
+	FaultOpSet            FaultOp = "set"
+	FaultOpSetSync        FaultOp = "set_sync"
+	FaultOpDelete         FaultOp = "delete"
+	FaultOpDeleteSync     FaultOp = "delete_sync"
+	FaultOpDeleteRange    FaultOp = "delete_range"
+	FaultOpBatchWrite     FaultOp = "batch_write"
+	FaultOpBatchWriteSync FaultOp = "batch_write_sync"
+	FaultOpIterator       FaultOp = "iterator"
+	FaultOpIteratorNext   FaultOp = "iterator_next"
+)
+
+// Fault describes calls of an operation which fail, or return corrupted values.
+type Fault struct {
+	// Op is the operation the fault applies to.
+	Op FaultOp
+	// Err is the error returned by the failing calls. It defaults to ErrInjectedFault.
+	Err error
+	// Prefix restricts the fault to calls on keys with the prefix. Calls without a key, such as
+	// batch writes, always match.
+	Prefix []byte
+	// After is the number of matching calls which succeed before the fault starts.
+	After int
+	// Times is the number of calls which fail once the fault has started, or 0 for all of them.
+	Times int
+	// Probability is the chance that a matching call fails, drawn from the seeded source of the
+	// FaultDB. 0 fails every matching call.
+	Probability float64
+	// Corrupt makes the matching calls succeed, but with a bit of the value flipped, instead of
+	// failing. It applies to FaultOpGet, and to FaultOpIteratorNext for the value moved to.
+	Corrupt bool
+}
+
+// fault is an injected Fault, with its state.
+type fault struct {
+	Fault
+	matched int
+	fired   int
+}
+
+// FaultDB wraps a DB and injects scripted faults into its operations, for testing how callers
+// handle them: calls can fail, values can be corrupted, and iterators can fail mid-scan. Faults
+// with a Probability draw from a source seeded at construction, so a run is reproducible as long
+// as the calls are made in the same order.
+//
+// FaultDB also simulates crashes. Writes made with Set, Delete, DeleteRange and Batch.Write are
+// unsynced until the next SetSync, DeleteSync or Batch.WriteSync, and Crash rolls them back, as a
+// crash would lose them. Snapshots are passed through without faults.
+type FaultDB struct {
+	mtx    sync.Mutex
+	db     DB
+	rng    *rand.Rand
+	faults []*fault
+	// undo holds the previous state of the keys written since the last sync, in write order.
+	undo []undoEntry
+}
+
+// undoEntry is the state of a key before an unsynced write. A nil value means the key did not
+// exist.
+type undoEntry struct {
+	key   []byte
+	value []byte
+}
+
+var (
+	_ DB        = (*FaultDB)(nil)
+	_ Compactor = (*FaultDB)(nil)
+)
+
+// NewFaultDB wraps db, seeding the source of faults with seed.
+func NewFaultDB(db DB, seed int64) *FaultDB {
+	return &FaultDB{
+		db:  db,
+		rng: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Inject adds a fault. Faults are checked in the order they were added, and the first one which
+// fires applies.
+func (fdb *FaultDB) Inject(f Fault) {
+	fdb.mtx.Lock()
+	defer fdb.mtx.Unlock()
+
+	if f.Err == nil {
+		f.Err = ErrInjectedFault
+	}
+	f.Prefix = cp(f.Prefix)
+	fdb.faults = append(fdb.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes all faults.
+func (fdb *FaultDB) ClearFaults() {
+	fdb.mtx.Lock()
+	defer fdb.mtx.Unlock()
+
+	fdb.faults = nil
+}
+
+// Crash simulates a crash and restart, rolling back the writes made since the last sync. Open
+// iterators and batches must not be used afterwards.
+func (fdb *FaultDB) Crash() error {
+	fdb.mtx.Lock()
+	defer fdb.mtx.Unlock()
+
+	for i := len(fdb.undo) - 1; i >= 0; i-- {
+		entry := fdb.undo[i]
+		var err error
+		if entry.value == nil {
+			err = fdb.db.Delete(entry.key)
+		} else {
+			err = fdb.db.Set(entry.key, entry.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fdb.undo = nil
+	// Leave the rolled back state as durable as what it replaced.
+	batch := fdb.db.NewBatch()
+	defer batch.Close()
+	return batch.WriteSync()
+}
+
+// Unsynced returns the number of unsynced writes which Crash would roll back, counting a key once
+// per write.
+func (fdb *FaultDB) Unsynced() int {
+	fdb.mtx.Lock()
+	defer fdb.mtx.Unlock()
+
+	return len(fdb.undo)
+}
+
+// check returns the fault which fires for a call of op on key, if any. Calls without a key pass
+// a nil key. The caller must hold mtx.
+func (fdb *FaultDB) check(op FaultOp, key []byte) *fault {
+	for _, f := range fdb.faults {
+		if f.Op != op || (key != nil && !bytes.HasPrefix(key, f.Prefix)) {
+			continue
+		}
+		f.matched++
+		if f.matched <= f.After || (f.Times > 0 && f.fired >= f.Times) {
+			continue
+		}
+		if f.Probability > 0 && fdb.rng.Float64() >= f.Probability {
+			continue
+		}
+		f.fired++
+		return f
+	}
+	return nil
+}
+
+// fail returns the error of the fault which fires for a call of op on key, if any.
+func (fdb *FaultDB) fail(op FaultOp, key []byte) error {
+	fdb.mtx.Lock()
+	defer fdb.mtx.Unlock()
+
+	if f := fdb.check(op, key); f != nil && !f.Corrupt {
+		return f.Err
+	}
+	return nil
+}
+
+// corrupt returns a copy of value with a bit flipped, chosen by the seeded source. The caller
+// must hold mtx.
+func (fdb *FaultDB) corrupt(value []byte) []byte {
+	if len(value) == 0 {
+		return []byte{0x01}
+	}
+	corrupted := cp(value)
+	bit := fdb.rng.Intn(len(corrupted) * 8)
+	corrupted[bit/8] ^= 1 << (bit % 8)
+	return corrupted
+}
+
+// read applies the faults of a read of key, returning value, a corrupted copy of it, or an error.
+func (fdb *FaultDB) read(op FaultOp, key, value []byte) ([]byte, error) {
+	fdb.mtx.Lock()
+	defer fdb.mtx.Unlock()
+
+	f := fdb.check(op, key)
+	switch {
+	case f == nil:
+		return value, nil
+	case f.Corrupt:
+		return fdb.corrupt(value), nil
+	default:
+		return nil, f.Err
+	}
+}
+
+// recordUndo adds the current state of keys to the undo log. The caller must hold mtx.
+func (fdb *FaultDB) recordUndo(keys ...[]byte) error {
+	for _, key := range keys {
+		value, err := fdb.db.Get(key)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			value = cp(value)
+		}
+		fdb.undo = append(fdb.undo, undoEntry{key: cp(key), value: value})
+	}
+	return nil
+}
+
+// fixedKeys returns a function returning keys, for write.
+func fixedKeys(keys ...[]byte) func() ([][]byte, error) {
+	return func() ([][]byte, error) { return keys, nil }
+}
+
+// rangeKeys returns the keys currently in [start, end).
+func (fdb *FaultDB) rangeKeys(start, end []byte) ([][]byte, error) {
+	itr, err := fdb.db.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+
+	var keys [][]byte
+	for ; itr.Valid(); itr.Next() {
+		keys = append(keys, cp(itr.Key()))
+	}
+	return keys, itr.Error()
+}
+
+// write runs an unsynced write of the keys returned by keys, recording their state so that Crash
+// can roll it back.
+func (fdb *FaultDB) write(op FaultOp, key []byte, keys func() ([][]byte, error), fn func() error) error {
+	fdb.mtx.Lock()
+	defer fdb.mtx.Unlock()
+
+	if f := fdb.check(op, key); f != nil {
+		return f.Err
+	}
+	written, err := keys()
+	if err != nil {
+		return err
+	}
+	undoLen := len(fdb.undo)
+	if err := fdb.recordUndo(written...); err != nil {
+		fdb.undo = fdb.undo[:undoLen]
+		return err
+	}
+	if err := fn(); err != nil {
+		fdb.undo = fdb.undo[:undoLen]
+		return err
+	}
+	return nil
+}
+
+// writeSync runs a synced write, which makes all earlier writes durable.
+func (fdb *FaultDB) writeSync(op FaultOp, key []byte, fn func() error) error {
+	fdb.mtx.Lock()
+	defer fdb.mtx.Unlock()
+
+	if f := fdb.check(op, key); f != nil {
+		return f.Err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	fdb.undo = nil
+	return nil
+}
+
+// Get implements DB.
+func (fdb *FaultDB) Get(key []byte) ([]byte, error) {
+	value, err := fdb.db.Get(key)
+	if err != nil || value == nil {
+		if err == nil {
+			err = fdb.fail(FaultOpGet, key)
+		}
+		return nil, err
+	}
+	return fdb.read(FaultOpGet, key, value)
+}
+
+// GetMany implements DB.
+// The keys are read with Get, so FaultOpGet faults apply to each of them.
+func (fdb *FaultDB) GetMany(keys [][]byte) ([][]byte, error) {
+	return getMany(fdb.Get, keys)
+}
+
+// View implements DB.
+// The value is read with Get, so FaultOpGet faults apply to it.
+func (fdb *FaultDB) View(key []byte, fn func([]byte) error) error {
+	value, err := fdb.Get(key)
+	if err != nil {
+		return err
+	}
+	return fn(value)
+}
+
+// Has implements DB.
+func (fdb *FaultDB) Has(key []byte) (bool, error) {
+	if err := fdb.fail(FaultOpHas, key); err != nil {
+		return false, err
+	}
+	return fdb.db.Has(key)
+}
+
+// Set implements DB.
+func (fdb *FaultDB) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	if value == nil {
+		return errValueNil
+	}
+	return fdb.write(FaultOpSet, key, fixedKeys(key), func() error {
+		return fdb.db.Set(key, value)
+	})
+}
+
+// SetSync implements DB.
+func (fdb *FaultDB) SetSync(key, value []byte) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	if value == nil {
+		return errValueNil
+	}
+	return fdb.writeSync(FaultOpSetSync, key, func() error {
+		return fdb.db.SetSync(key, value)
+	})
+}
+
+// Delete implements DB.
+func (fdb *FaultDB) Delete(key []byte) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	return fdb.write(FaultOpDelete, key, fixedKeys(key), func() error {
+		return fdb.db.Delete(key)
+	})
+}
+
+// DeleteSync implements DB.
+func (fdb *FaultDB) DeleteSync(key []byte) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	return fdb.writeSync(FaultOpDeleteSync, key, func() error {
+		return fdb.db.DeleteSync(key)
+	})
+}
+
+// DeleteRange implements DB.
+// Faults with a Prefix match if start has the prefix.
+func (fdb *FaultDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	rangeKeys := func() ([][]byte, error) { return fdb.rangeKeys(start, end) }
+	return fdb.write(FaultOpDeleteRange, start, rangeKeys, func() error {
+		return fdb.db.DeleteRange(start, end)
+	})
+}
+
+// Iterator implements DB.
+// Faults with a Prefix match if start has the prefix.
+func (fdb *FaultDB) Iterator(start, end []byte) (Iterator, error) {
+	if err := fdb.fail(FaultOpIterator, start); err != nil {
+		return nil, err
+	}
+	itr, err := fdb.db.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newFaultDBIterator(fdb, itr), nil
+}
+
+// ReverseIterator implements DB.
+// Faults with a Prefix match if start has the prefix.
+func (fdb *FaultDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	if err := fdb.fail(FaultOpIterator, start); err != nil {
+		return nil, err
+	}
+	itr, err := fdb.db.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newFaultDBIterator(fdb, itr), nil
+}
+
+// EstimateSize implements DB.
+func (fdb *FaultDB) EstimateSize(start, end []byte) (uint64, error) {
+	return fdb.db.EstimateSize(start, end)
+}
+
+// EstimateKeys implements DB.
+func (fdb *FaultDB) EstimateKeys(start, end []byte) (uint64, error) {
+	return fdb.db.EstimateKeys(start, end)
+}
+
+// Compact implements Compactor.
+// It is a noop if the wrapped DB is not a Compactor.
+func (fdb *FaultDB) Compact(start, end []byte) error {
+	if c, ok := fdb.db.(Compactor); ok {
+		return c.Compact(start, end)
+	}
+	return nil
+}
+
+// Close implements DB.
+func (fdb *FaultDB) Close() error {
+	return fdb.db.Close()
+}
+
+// NewBatch implements DB.
+func (fdb *FaultDB) NewBatch() Batch {
+	return newFaultDBBatch(fdb, fdb.db.NewBatch())
+}
+
+// NewBatchWithSize implements DB.
+func (fdb *FaultDB) NewBatchWithSize(size int) Batch {
+	return newFaultDBBatch(fdb, fdb.db.NewBatchWithSize(size))
+}
+
+// NewIndexedBatch implements DB.
+// Reads go through the FaultDB, so they are subject to its faults.
+func (fdb *FaultDB) NewIndexedBatch() IndexedBatch {
+	return newIndexedBatch(fdb, fdb.NewBatch())
+}
+
+// NewSnapshot implements DB.
+func (fdb *FaultDB) NewSnapshot() (Snapshot, error) {
+	return fdb.db.NewSnapshot()
+}
+
+// Backup implements DB.
+// The backup includes unsynced writes.
+func (fdb *FaultDB) Backup(destDir string) error {
+	return fdb.db.Backup(destDir)
+}
+
+// Print implements DB.
+func (fdb *FaultDB) Print() error {
+	return fdb.db.Print()
+}
+
+// Stats implements DB.
+func (fdb *FaultDB) Stats() map[string]string {
+	return fdb.db.Stats()
+}
+
+// TypedStats implements DB.
+func (fdb *FaultDB) TypedStats() DBStats {
+	return fdb.db.TypedStats()
+}
+
+// backendType implements backendTyper.
+func (fdb *FaultDB) backendType() BackendType {
+	return backendOf(fdb.db)
+}
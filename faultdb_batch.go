@@ -0,0 +1,84 @@
+package db
+
+import "bytes"
+
+// faultDBBatch is a batch of a FaultDB. It tracks the keys it writes, so that an unsynced Write
+// can be rolled back by FaultDB.Crash.
+type faultDBBatch struct {
+	fdb    *FaultDB
+	source Batch
+	keys   [][]byte
+	// ranges holds the start and end of each range deleted by the batch.
+	ranges [][2][]byte
+}
+
+var _ Batch = (*faultDBBatch)(nil)
+
+func newFaultDBBatch(fdb *FaultDB, source Batch) *faultDBBatch {
+	return &faultDBBatch{
+		fdb:    fdb,
+		source: source,
+	}
+}
+
+// Set implements Batch.
+func (b *faultDBBatch) Set(key, value []byte) error {
+	if err := b.source.Set(key, value); err != nil {
+		return err
+	}
+	b.keys = append(b.keys, cp(key))
+	return nil
+}
+
+// Delete implements Batch.
+func (b *faultDBBatch) Delete(key []byte) error {
+	if err := b.source.Delete(key); err != nil {
+		return err
+	}
+	b.keys = append(b.keys, cp(key))
+	return nil
+}
+
+// DeleteRange implements Batch.
+func (b *faultDBBatch) DeleteRange(start, end []byte) error {
+	if err := b.source.DeleteRange(start, end); err != nil {
+		return err
+	}
+	b.ranges = append(b.ranges, [2][]byte{bytes.Clone(start), bytes.Clone(end)})
+	return nil
+}
+
+// writtenKeys returns the keys the batch writes, including the keys currently in its deleted
+// ranges.
+func (b *faultDBBatch) writtenKeys() ([][]byte, error) {
+	keys := b.keys
+	for _, r := range b.ranges {
+		rangeKeys, err := b.fdb.rangeKeys(r[0], r[1])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, rangeKeys...)
+	}
+	return keys, nil
+}
+
+// Write implements Batch.
+func (b *faultDBBatch) Write() error {
+	return b.fdb.write(FaultOpBatchWrite, nil, b.writtenKeys, b.source.Write)
+}
+
+// WriteSync implements Batch.
+func (b *faultDBBatch) WriteSync() error {
+	return b.fdb.writeSync(FaultOpBatchWriteSync, nil, b.source.WriteSync)
+}
+
+// Close implements Batch.
+func (b *faultDBBatch) Close() error {
+	b.keys, b.ranges = nil, nil
+	return b.source.Close()
+}
+
+// GetByteSize implements Batch.
+func (b *faultDBBatch) GetByteSize() (int, error) {
+	return b.source.GetByteSize()
+}
@@ -0,0 +1,81 @@
+package db
+
+// faultDBIterator is an iterator of a FaultDB. FaultOpIteratorNext faults either corrupt the
+// value moved to, or fail the iterator, which then stays invalid and reports the fault from Error.
+type faultDBIterator struct {
+	Iterator
+	fdb   *FaultDB
+	err   error
+	value []byte // corrupted value at the current position, if any
+}
+
+var _ Iterator = (*faultDBIterator)(nil)
+
+func newFaultDBIterator(fdb *FaultDB, source Iterator) *faultDBIterator {
+	return &faultDBIterator{
+		Iterator: source,
+		fdb:      fdb,
+	}
+}
+
+// Valid implements Iterator.
+func (itr *faultDBIterator) Valid() bool {
+	return itr.err == nil && itr.Iterator.Valid()
+}
+
+// Next implements Iterator.
+func (itr *faultDBIterator) Next() {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+	itr.Iterator.Next()
+	itr.value = nil
+	if !itr.Iterator.Valid() {
+		return
+	}
+
+	itr.fdb.mtx.Lock()
+	defer itr.fdb.mtx.Unlock()
+	f := itr.fdb.check(FaultOpIteratorNext, itr.Iterator.Key())
+	switch {
+	case f == nil:
+	case f.Corrupt:
+		itr.value = itr.fdb.corrupt(itr.Iterator.Value())
+	default:
+		itr.err = f.Err
+	}
+}
+
+// Seek implements Iterator.
+// Seeking a failed iterator leaves it failed.
+func (itr *faultDBIterator) Seek(key []byte) {
+	itr.Iterator.Seek(key)
+	itr.value = nil
+}
+
+// Key implements Iterator.
+func (itr *faultDBIterator) Key() []byte {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+	return itr.Iterator.Key()
+}
+
+// Value implements Iterator.
+func (itr *faultDBIterator) Value() []byte {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+	if itr.value != nil {
+		return itr.value
+	}
+	return itr.Iterator.Value()
+}
+
+// Error implements Iterator.
+func (itr *faultDBIterator) Error() error {
+	if itr.err != nil {
+		return itr.err
+	}
+	return itr.Iterator.Error()
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultDB(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testFaultDB(t, dbType)
+		})
+	}
+}
+
+func testFaultDB(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	fdb := NewFaultDB(db, 1)
+	defer fdb.Close()
+
+	// Faults start after After calls, fire Times times, and only match keys with Prefix.
+	errDisk := errors.New("disk on fire")
+	fdb.Inject(Fault{Op: FaultOpSet, Err: errDisk, Prefix: []byte("a"), After: 1, Times: 2})
+	require.NoError(t, fdb.Set([]byte("a1"), []byte("1")))
+	require.ErrorIs(t, fdb.Set([]byte("a2"), []byte("2")), errDisk)
+	require.NoError(t, fdb.Set([]byte("b1"), []byte("1")))
+	require.ErrorIs(t, fdb.Set([]byte("a3"), []byte("3")), errDisk)
+	require.NoError(t, fdb.Set([]byte("a4"), []byte("4")))
+	value, err := fdb.Get([]byte("a2"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	fdb.Inject(Fault{Op: FaultOpGet})
+	_, err = fdb.Get([]byte("a1"))
+	require.ErrorIs(t, err, ErrInjectedFault)
+	fdb.ClearFaults()
+
+	// Corrupted values differ from the stored one, but are not written back.
+	fdb.Inject(Fault{Op: FaultOpGet, Corrupt: true, Times: 1})
+	value, err = fdb.Get([]byte("a1"))
+	require.NoError(t, err)
+	require.NotEqual(t, []byte("1"), value)
+	value, err = fdb.Get([]byte("a1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), value)
+
+	// Iterators fail mid-scan, and stay failed.
+	fdb.Inject(Fault{Op: FaultOpIteratorNext, After: 1, Times: 1})
+	itr, err := fdb.Iterator(nil, nil)
+	require.NoError(t, err)
+	var keys []string
+	for ; itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.Key()))
+	}
+	require.Equal(t, []string{"a1", "a4"}, keys)
+	require.ErrorIs(t, itr.Error(), ErrInjectedFault)
+	require.Panics(t, func() { itr.Value() })
+	require.NoError(t, itr.Close())
+	fdb.ClearFaults()
+
+	fdb.Inject(Fault{Op: FaultOpIterator})
+	_, err = fdb.ReverseIterator(nil, nil)
+	require.ErrorIs(t, err, ErrInjectedFault)
+	fdb.ClearFaults()
+
+	// A crash rolls back the writes since the last sync, whichever way they were made.
+	require.NoError(t, fdb.SetSync([]byte("s"), []byte("synced")))
+	require.Zero(t, fdb.Unsynced())
+	require.NoError(t, fdb.Set([]byte("a1"), []byte("changed")))
+	require.NoError(t, fdb.Delete([]byte("b1")))
+	require.NoError(t, fdb.DeleteRange([]byte("a4"), []byte("a5")))
+	batch := fdb.NewBatch()
+	require.NoError(t, batch.Set([]byte("c"), []byte("batched")))
+	require.NoError(t, batch.DeleteRange([]byte("s"), []byte("t")))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	require.NotZero(t, fdb.Unsynced())
+
+	require.NoError(t, fdb.Crash())
+	requireFaultDBContents(t, fdb, map[string]string{"a1": "1", "a4": "4", "b1": "1", "s": "synced"})
+
+	// Synced writes make the writes before them durable.
+	require.NoError(t, fdb.Set([]byte("x"), []byte("1")))
+	batch = fdb.NewBatch()
+	require.NoError(t, batch.Delete([]byte("a1")))
+	require.NoError(t, batch.WriteSync())
+	require.NoError(t, batch.Close())
+	require.NoError(t, fdb.Set([]byte("y"), []byte("1")))
+	require.NoError(t, fdb.DeleteSync([]byte("b1")))
+	require.NoError(t, fdb.Set([]byte("z"), []byte("lost")))
+
+	require.NoError(t, fdb.Crash())
+	requireFaultDBContents(t, fdb, map[string]string{"a4": "4", "s": "synced", "x": "1", "y": "1"})
+
+	// Failed writes are not recorded, as they changed nothing.
+	fdb.Inject(Fault{Op: FaultOpBatchWriteSync})
+	batch = fdb.NewBatch()
+	require.NoError(t, batch.Set([]byte("z"), []byte("1")))
+	require.ErrorIs(t, batch.WriteSync(), ErrInjectedFault)
+	require.NoError(t, batch.Close())
+	require.Zero(t, fdb.Unsynced())
+}
+
+func requireFaultDBContents(t *testing.T, db DB, want map[string]string) {
+	t.Helper()
+
+	got := map[string]string{}
+	itr, err := db.Iterator(nil, nil)
+	require.NoError(t, err)
+	for ; itr.Valid(); itr.Next() {
+		got[string(itr.Key())] = string(itr.Value())
+	}
+	require.NoError(t, itr.Error())
+	require.NoError(t, itr.Close())
+	require.Equal(t, want, got)
+}
+
+func TestFaultDBSeed(t *testing.T) {
+	faults := func(seed int64) []bool {
+		fdb := NewFaultDB(NewMemDB(), seed)
+		fdb.Inject(Fault{Op: FaultOpSet, Probability: 0.5})
+		var failed []bool
+		for i := 0; i < 64; i++ {
+			failed = append(failed, fdb.Set([]byte{byte(i)}, []byte{}) != nil)
+		}
+		return failed
+	}
+
+	require.Equal(t, faults(42), faults(42))
+	require.NotEqual(t, faults(42), faults(43))
+	require.Contains(t, faults(42), true)
+	require.Contains(t, faults(42), false)
+}
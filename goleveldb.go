@@ -17,15 +17,22 @@ func init() {
 	dbCreator := func(name, dir string, opts Options) (DB, error) {
 		return NewGoLevelDB(name, dir, opts)
 	}
-	registerDBCreator(GoLevelDBBackend, dbCreator, false)
+	registerDBCreator(GoLevelDBBackend, dbCreator, Capabilities{Persistent: true, Snapshots: true}, false)
 }
 
 type GoLevelDB struct {
 	db *leveldb.DB
+	// keyCount is the key count estimated by EstimateKeys.
+	keyCount cachedStat
 }
 
-var _ DB = (*GoLevelDB)(nil)
+var (
+	_ DB        = (*GoLevelDB)(nil)
+	_ Compactor = (*GoLevelDB)(nil)
+)
 
+// NewGoLevelDB opens the GoLevelDB name in dir. With OptReadOnly set, writes fail with the errors
+// of goleveldb rather than ErrReadOnly; open it through NewReadOnlyDB for those.
 func NewGoLevelDB(name, dir string, opts Options) (*GoLevelDB, error) {
 	defaultOpts := &opt.Options{
 		Filter: filter.NewBloomFilter(10), // by default, goleveldb doesn't use a bloom filter.
@@ -35,6 +42,14 @@ func NewGoLevelDB(name, dir string, opts Options) (*GoLevelDB, error) {
 		if files > 0 {
 			defaultOpts.OpenFilesCacheCapacity = files
 		}
+		readOnly, err := isReadOnly(opts)
+		if err != nil {
+			return nil, err
+		}
+		if readOnly {
+			defaultOpts.ReadOnly = true
+			defaultOpts.ErrorIfMissing = true
+		}
 	}
 
 	return NewGoLevelDBWithOpts(name, dir, defaultOpts)
@@ -67,6 +82,39 @@ func (db *GoLevelDB) Get(key []byte) ([]byte, error) {
 	return res, nil
 }
 
+// GetMany implements DB.
+// The keys are read from a snapshot, so that the values are from the same point in time.
+func (db *GoLevelDB) GetMany(keys [][]byte) ([][]byte, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	snap, err := db.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Release()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		value, err := snap.Get(key, nil)
+		if err != nil && !errors.Is(err, leveldberrors.ErrNotFound) {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// View implements DB.
+// goleveldb has no way to lend its buffers, so fn is given a copy of the value.
+func (db *GoLevelDB) View(key []byte, fn func([]byte) error) error {
+	value, err := db.Get(key)
+	if err != nil {
+		return err
+	}
+	return fn(value)
+}
+
 // Has implements DB.
 func (db *GoLevelDB) Has(key []byte) (bool, error) {
 	if len(key) == 0 {
@@ -126,6 +174,33 @@ func (db *GoLevelDB) DeleteSync(key []byte) error {
 	return nil
 }
 
+// DeleteRange implements DB.
+// goleveldb has no range tombstones, so the keys in the range are deleted one by one in a single
+// atomic batch.
+func (db *GoLevelDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	batch := new(leveldb.Batch)
+	if err := db.appendRangeDeletes(batch, start, end); err != nil {
+		return err
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return db.db.Write(batch, nil)
+}
+
+// appendRangeDeletes appends a delete to batch for every key currently in [start, end).
+func (db *GoLevelDB) appendRangeDeletes(batch *leveldb.Batch, start, end []byte) error {
+	itr := db.db.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	defer itr.Release()
+	for itr.Next() {
+		batch.Delete(itr.Key())
+	}
+	return itr.Error()
+}
+
 func (db *GoLevelDB) DB() *leveldb.DB {
 	return db.db
 }
@@ -175,9 +250,145 @@ func (db *GoLevelDB) Stats() map[string]string {
 			stats[key] = str
 		}
 	}
-	return stats
+	return db.TypedStats().flatten(stats)
+}
+
+// TypedStats implements DB.
+func (db *GoLevelDB) TypedStats() DBStats {
+	var stats leveldb.DBStats
+	if err := db.db.Stats(&stats); err != nil {
+		return DBStats{Backend: GoLevelDBBackend}
+	}
+	return DBStats{
+		Backend:          GoLevelDBBackend,
+		KeyCountEstimate: db.keyCount.get(db.keyCountEstimate),
+		DiskSize:         uint64(stats.LevelSizes.Sum()),
+		OpenIterators:    uint64(stats.AliveIterators),
+		WriteStalls:      uint64(stats.WriteDelayCount),
+	}
+}
+
+// backendType implements backendTyper.
+func (db *GoLevelDB) backendType() BackendType {
+	return GoLevelDBBackend
+}
+
+// keyCountEstimate estimates the number of keys with EstimateKeys, as goleveldb does not count
+// them.
+func (db *GoLevelDB) keyCountEstimate() uint64 {
+	keys, err := db.EstimateKeys(nil, nil)
+	if err != nil {
+		return 0
+	}
+	return keys
 }
 
+// nativeMetrics implements nativeMetricer.
+func (db *GoLevelDB) nativeMetrics() []nativeMetric {
+	var stats leveldb.DBStats
+	if err := db.db.Stats(&stats); err != nil {
+		return nil
+	}
+	metrics := []nativeMetric{
+		{name: "leveldb_write_delays_total", help: "Number of writes delayed by compaction.", value: float64(stats.WriteDelayCount), counter: true},
+		{name: "leveldb_write_delay_seconds_total", help: "Time writes were delayed by compaction.", value: stats.WriteDelayDuration.Seconds(), counter: true},
+		{name: "leveldb_alive_snapshots", help: "Number of unreleased snapshots.", value: float64(stats.AliveSnapshots)},
+		{name: "leveldb_alive_iterators", help: "Number of unreleased iterators.", value: float64(stats.AliveIterators)},
+		{name: "leveldb_io_read_bytes_total", help: "Bytes read from storage.", value: float64(stats.IORead), counter: true},
+		{name: "leveldb_io_write_bytes_total", help: "Bytes written to storage.", value: float64(stats.IOWrite), counter: true},
+		{name: "leveldb_block_cache_size_bytes", help: "Size of the block cache.", value: float64(stats.BlockCacheSize)},
+		{name: "leveldb_opened_tables", help: "Number of opened tables.", value: float64(stats.OpenedTablesCount)},
+	}
+	for typ, count := range map[string]uint32{
+		"memtable": stats.MemComp,
+		"level0":   stats.Level0Comp,
+		"level":    stats.NonLevel0Comp,
+		"seek":     stats.SeekComp,
+	} {
+		metrics = append(metrics, nativeMetric{
+			name: "leveldb_compactions_total", help: "Number of compactions, by type.",
+			labels: map[string]string{"type": typ}, value: float64(count), counter: true,
+		})
+	}
+	for level, size := range stats.LevelSizes {
+		labels := map[string]string{"level": fmt.Sprint(level)}
+		metrics = append(metrics,
+			nativeMetric{name: "leveldb_level_size_bytes", help: "Size of the tables in a level.", labels: labels, value: float64(size)},
+			nativeMetric{name: "leveldb_level_tables", help: "Number of tables in a level.", labels: labels, value: float64(stats.LevelTablesCounts[level])},
+		)
+	}
+	return metrics
+}
+
+// Backup implements DB.
+// goleveldb has no checkpoints, so a snapshot is copied into a new database at destDir.
+func (db *GoLevelDB) Backup(destDir string) error {
+	if err := checkBackupDir(destDir); err != nil {
+		return err
+	}
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	dst, err := leveldb.OpenFile(destDir, &opt.Options{ErrorIfExist: true})
+	if err != nil {
+		return err
+	}
+	if err := copySnapshot(snap, &GoLevelDB{db: dst}); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// EstimateSize implements DB.
+// It uses the SizeOf of goleveldb, which only covers keys written to disk.
+func (db *GoLevelDB) EstimateSize(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	return db.sizeOf(start, end)
+}
+
+// EstimateKeys implements DB.
+// goleveldb does not count keys by range, so the count is extrapolated from EstimateSize.
+func (db *GoLevelDB) EstimateKeys(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	return estimateKeys(db, db.sizeOf, start, end)
+}
+
+// sizeOf returns the size of [start, end) on disk. SizeOf needs a bounded domain, so a nil end
+// is replaced by one past the last key.
+func (db *GoLevelDB) sizeOf(start, end []byte) (uint64, error) {
+	if end == nil {
+		last, err := lastKey(db)
+		if err != nil || last == nil {
+			return 0, err
+		}
+		end = append(last, 0x00)
+	}
+	sizes, err := db.db.SizeOf([]util.Range{{Start: start, Limit: end}})
+	if err != nil {
+		return 0, err
+	}
+	return uint64(sizes.Sum()), nil
+}
+
+// Compact implements Compactor.
+func (db *GoLevelDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	return db.db.CompactRange(util.Range{Start: start, Limit: end})
+}
+
+// ForceCompact compacts the keys in [start, limit).
+//
+// Deprecated: use Compact, which every backend implements.
 func (db *GoLevelDB) ForceCompact(start, limit []byte) error {
 	return db.db.CompactRange(util.Range{Start: start, Limit: limit})
 }
@@ -192,6 +403,11 @@ func (db *GoLevelDB) NewBatchWithSize(size int) Batch {
 	return newGoLevelDBBatchWithSize(db, size)
 }
 
+// NewIndexedBatch implements DB.
+func (db *GoLevelDB) NewIndexedBatch() IndexedBatch {
+	return newIndexedBatch(db, newGoLevelDBBatch(db))
+}
+
 // Iterator implements DB.
 func (db *GoLevelDB) Iterator(start, end []byte) (Iterator, error) {
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
@@ -53,6 +53,27 @@ func (b *goLevelDBBatch) Delete(key []byte) error {
 	return nil
 }
 
+// DeleteRange implements Batch.
+// The range is expanded into point deletes when DeleteRange is called, covering the keys in the
+// database and the keys set earlier in this batch. Keys written to the database after this call
+// are not deleted.
+func (b *goLevelDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	if b.batch == nil {
+		return errBatchClosed
+	}
+	pending := &batchRangeKeys{start: start, end: end}
+	if err := b.batch.Replay(pending); err != nil {
+		return err
+	}
+	for _, key := range pending.keys {
+		b.batch.Delete(key)
+	}
+	return b.db.appendRangeDeletes(b.batch, start, end)
+}
+
 // Write implements Batch.
 func (b *goLevelDBBatch) Write() error {
 	return b.write(false)
@@ -91,3 +112,22 @@ func (b *goLevelDBBatch) GetByteSize() (int, error) {
 	}
 	return len(b.batch.Dump()), nil
 }
+
+// batchRangeKeys collects copies of the keys set in a leveldb.Batch that fall within [start, end).
+type batchRangeKeys struct {
+	start []byte
+	end   []byte
+	keys  [][]byte
+}
+
+var _ leveldb.BatchReplay = (*batchRangeKeys)(nil)
+
+// Put implements leveldb.BatchReplay.
+func (r *batchRangeKeys) Put(key, _ []byte) {
+	if IsKeyInDomain(key, r.start, r.end) {
+		r.keys = append(r.keys, cp(key))
+	}
+}
+
+// Delete implements leveldb.BatchReplay.
+func (r *batchRangeKeys) Delete(_ []byte) {}
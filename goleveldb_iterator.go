@@ -118,6 +118,25 @@ func (itr *goLevelDBIterator) Next() {
 	}
 }
 
+// Seek implements Iterator.
+func (itr *goLevelDBIterator) Seek(key []byte) {
+	target, ok := seekTarget(key, itr.start, itr.end, itr.isReverse)
+	if !ok {
+		itr.isInvalid = true
+		return
+	}
+	itr.isInvalid = false
+	if itr.isReverse {
+		if itr.source.Seek(target) {
+			itr.source.Prev()
+		} else {
+			itr.source.Last()
+		}
+	} else {
+		itr.source.Seek(target)
+	}
+}
+
 // Error implements Iterator.
 func (itr *goLevelDBIterator) Error() error {
 	return itr.source.Error()
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldberrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+type goLevelDBSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+var _ Snapshot = (*goLevelDBSnapshot)(nil)
+
+// NewSnapshot implements DB.
+func (db *GoLevelDB) NewSnapshot() (Snapshot, error) {
+	snap, err := db.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &goLevelDBSnapshot{snap: snap}, nil
+}
+
+// Get implements Snapshot.
+func (s *goLevelDBSnapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	res, err := s.snap.Get(key, nil)
+	if err != nil {
+		if errors.Is(err, leveldberrors.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// Has implements Snapshot.
+func (s *goLevelDBSnapshot) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, errKeyEmpty
+	}
+	if s.snap == nil {
+		return false, errSnapshotClosed
+	}
+	return s.snap.Has(key, nil)
+}
+
+// Iterator implements Snapshot.
+func (s *goLevelDBSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	itr := s.snap.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	return newGoLevelDBIterator(itr, start, end, false), nil
+}
+
+// ReverseIterator implements Snapshot.
+func (s *goLevelDBSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	itr := s.snap.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	return newGoLevelDBIterator(itr, start, end, true), nil
+}
+
+// Close implements Snapshot.
+func (s *goLevelDBSnapshot) Close() error {
+	if s.snap != nil {
+		s.snap.Release()
+		s.snap = nil
+	}
+	return nil
+}
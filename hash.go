@@ -0,0 +1,216 @@
+package db
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// firstDifferenceScanKeys is the number of keys under a prefix below which FirstDifference
+// compares the keys one by one, rather than digesting the prefix further.
+const firstDifferenceScanKeys = 256
+
+// RangeHash is the hash of a range of keys, computed by HashRange.
+type RangeHash struct {
+	// Sum is the hash of the length-prefixed keys and values, in key order.
+	Sum []byte
+	// Keys is the number of keys in the range.
+	Keys uint64
+}
+
+// Equal reports whether h and other hash the same keys and values.
+func (h RangeHash) Equal(other RangeHash) bool {
+	return h.Keys == other.Keys && bytes.Equal(h.Sum, other.Sum)
+}
+
+// HashRange hashes the keys and values in the domain [start, end) of db with the hash returned
+// by newHash, or SHA-256 if it is nil. Each key and value is prefixed with its length as a
+// uvarint, so two databases hash the same if and only if they hold the same pairs, whatever
+// their backends. A nil start or end leaves the domain unbounded at that end.
+func HashRange(db DB, start, end []byte, newHash func() hash.Hash) (RangeHash, error) {
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	itr, err := db.Iterator(start, end)
+	if err != nil {
+		return RangeHash{}, err
+	}
+	defer itr.Close()
+
+	h := newHash()
+	var keys uint64
+	var buf []byte
+	for ; itr.Valid(); itr.Next() {
+		buf = hashPair(h, buf, itr.Key(), itr.Value())
+		keys++
+	}
+	if err := itr.Error(); err != nil {
+		return RangeHash{}, err
+	}
+	return RangeHash{Sum: h.Sum(nil), Keys: keys}, nil
+}
+
+// hashPair writes the length-prefixed key and value to h, using buf as scratch space, and
+// returns buf for reuse.
+func hashPair(h hash.Hash, buf, key, value []byte) []byte {
+	buf = binary.AppendUvarint(buf[:0], uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	buf = append(buf, value...)
+	_, _ = h.Write(buf)
+	return buf
+}
+
+// PrefixDigest is a one-level Merkle digest of the keys under a prefix: the hash of the key equal
+// to the prefix, if any, and of each of the 256 buckets of keys extending the prefix by one more
+// byte. Comparing the digests of two databases tells which buckets differ, and digesting the
+// prefix of a differing bucket narrows the difference down further.
+type PrefixDigest struct {
+	// Prefix is the digested prefix. The nil prefix digests the whole database.
+	Prefix []byte
+	// Root hashes Own and the Buckets which have keys, so it differs if any of them does.
+	Root []byte
+	// Keys is the number of keys under the prefix, including the prefix itself.
+	Keys uint64
+	// Own is the hash of the key equal to the prefix, with no keys if there is none.
+	Own RangeHash
+	// Buckets holds, at index b, the hash of the keys starting with the prefix followed by b.
+	Buckets [256]RangeHash
+}
+
+// DigestPrefix computes the PrefixDigest of prefix in db in a single scan, hashing the keys with
+// the hash returned by newHash, or SHA-256 if it is nil. A bucket hash is the HashRange of the
+// keys of the bucket.
+func DigestPrefix(db DB, prefix []byte, newHash func() hash.Hash) (*PrefixDigest, error) {
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	start, end := prefixDomain(prefix)
+	itr, err := db.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+
+	digest := &PrefixDigest{Prefix: cp(prefix)}
+	// Keys come in order, so the keys of each bucket, and the key equal to the prefix before them,
+	// are contiguous and hashed one bucket at a time.
+	var (
+		current *RangeHash
+		h       hash.Hash
+		buf     []byte
+	)
+	finish := func() {
+		if current != nil {
+			current.Sum = h.Sum(nil)
+		}
+	}
+	for ; itr.Valid(); itr.Next() {
+		key := itr.Key()
+		next := &digest.Own
+		if len(key) > len(prefix) {
+			next = &digest.Buckets[key[len(prefix)]]
+		}
+		if next != current {
+			finish()
+			current, h = next, newHash()
+		}
+		buf = hashPair(h, buf, key, itr.Value())
+		current.Keys++
+		digest.Keys++
+	}
+	if err := itr.Error(); err != nil {
+		return nil, err
+	}
+	finish()
+
+	root := newHash()
+	if digest.Own.Keys > 0 {
+		_, _ = root.Write([]byte{0})
+		_, _ = root.Write(digest.Own.Sum)
+	}
+	for b, bucket := range digest.Buckets {
+		if bucket.Keys > 0 {
+			_, _ = root.Write([]byte{1, byte(b)})
+			_, _ = root.Write(bucket.Sum)
+		}
+	}
+	digest.Root = root.Sum(nil)
+	return digest, nil
+}
+
+// FirstDifference returns the first key, in key order, whose value differs between a and b or
+// which only one of them holds, or nil if they hold the same keys and values. It bisects with
+// DigestPrefix, hashing with the hash returned by newHash, or SHA-256 if it is nil: it descends
+// into the first differing bucket until few enough keys are left to compare one by one.
+func FirstDifference(a, b DB, newHash func() hash.Hash) ([]byte, error) {
+	var prefix []byte
+	for {
+		digestA, err := DigestPrefix(a, prefix, newHash)
+		if err != nil {
+			return nil, err
+		}
+		digestB, err := DigestPrefix(b, prefix, newHash)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(digestA.Root, digestB.Root) {
+			return nil, nil
+		}
+		if digestA.Keys <= firstDifferenceScanKeys || digestB.Keys <= firstDifferenceScanKeys {
+			start, end := prefixDomain(prefix)
+			return scanFirstDifference(a, b, start, end)
+		}
+		if !digestA.Own.Equal(digestB.Own) {
+			return cp(prefix), nil
+		}
+		bucket := firstDifferentBucket(digestA, digestB)
+		if bucket < 0 {
+			return nil, nil
+		}
+		prefix = append(prefix, byte(bucket))
+	}
+}
+
+// firstDifferentBucket returns the index of the first bucket which differs between a and b, or -1
+// if none does.
+func firstDifferentBucket(a, b *PrefixDigest) int {
+	for bucket := range a.Buckets {
+		if !a.Buckets[bucket].Equal(b.Buckets[bucket]) {
+			return bucket
+		}
+	}
+	return -1
+}
+
+// scanFirstDifference compares the keys and values of a and b in [start, end) one by one, and
+// returns the first key which differs, or nil if none does.
+func scanFirstDifference(a, b DB, start, end []byte) ([]byte, error) {
+	itrA, err := a.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer itrA.Close()
+	itrB, err := b.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer itrB.Close()
+
+	var diff []byte
+	err = diffIterators(itrA, itrB, func(entry DiffEntry) bool {
+		diff = entry.Key
+		return false
+	})
+	return diff, err
+}
+
+// prefixDomain returns the domain of the keys starting with prefix. The nil prefix covers the
+// whole database.
+func prefixDomain(prefix []byte) (start, end []byte) {
+	if len(prefix) == 0 {
+		return nil, nil
+	}
+	return prefix, cpIncr(prefix)
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashRange(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testHashRange(t, dbType)
+		})
+	}
+}
+
+func testHashRange(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	defer db.Close()
+
+	mem := NewMemDB()
+	for i := 0; i < 2000; i++ {
+		key, value := []byte(fmt.Sprintf("key%05d", i)), []byte(randStr(1+i%20))
+		require.NoError(t, db.Set(key, value))
+		require.NoError(t, mem.Set(key, value))
+	}
+	require.NoError(t, db.Set([]byte("key"), []byte{}))
+	require.NoError(t, mem.Set([]byte("key"), []byte{}))
+
+	// The same keys hash the same, whatever the backend.
+	for _, newHash := range []func() hash.Hash{nil, sha512.New} {
+		h, err := HashRange(db, []byte("key00100"), []byte("key00200"), newHash)
+		require.NoError(t, err)
+		require.EqualValues(t, 100, h.Keys)
+		m, err := HashRange(mem, []byte("key00100"), []byte("key00200"), newHash)
+		require.NoError(t, err)
+		require.True(t, h.Equal(m))
+	}
+
+	digest, err := DigestPrefix(db, []byte("key01"), nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1000, digest.Keys)
+	require.Zero(t, digest.Own.Keys)
+	for b, bucket := range digest.Buckets {
+		if b < '0' || b > '9' {
+			require.Zero(t, bucket.Keys)
+			continue
+		}
+		require.EqualValues(t, 100, bucket.Keys)
+		start := append([]byte("key01"), byte(b))
+		expect, err := HashRange(db, start, cpIncr(start), nil)
+		require.NoError(t, err)
+		require.True(t, bucket.Equal(expect))
+	}
+
+	// The whole database digests the same as its copy, until they differ.
+	digest, err = DigestPrefix(db, nil, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 2001, digest.Keys)
+	memDigest, err := DigestPrefix(mem, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, memDigest, digest)
+
+	diff, err := FirstDifference(db, mem, nil)
+	require.NoError(t, err)
+	require.Nil(t, diff)
+
+	for _, tc := range []struct {
+		name   string
+		modify func(db DB) error
+		diff   string
+	}{
+		{"changed value", func(db DB) error { return db.Set([]byte("key01234"), []byte("changed")) }, "key01234"},
+		{"missing key", func(db DB) error { return db.Delete([]byte("key00777")) }, "key00777"},
+		{"extra key", func(db DB) error { return db.Set([]byte("key00777a"), []byte{1}) }, "key00777a"},
+		{"changed prefix key", func(db DB) error { return db.Set([]byte("key"), []byte{1}) }, "key"},
+		{"first key", func(db DB) error { return db.Set([]byte{0x00}, []byte{1}) }, "\x00"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			copied := NewMemDB()
+			itr, err := mem.Iterator(nil, nil)
+			require.NoError(t, err)
+			for ; itr.Valid(); itr.Next() {
+				require.NoError(t, copied.Set(itr.Key(), itr.Value()))
+			}
+			require.NoError(t, itr.Close())
+			require.NoError(t, tc.modify(copied))
+
+			diff, err := FirstDifference(db, copied, nil)
+			require.NoError(t, err)
+			require.Equal(t, []byte(tc.diff), diff)
+			diff, err = FirstDifference(copied, db, nil)
+			require.NoError(t, err)
+			require.Equal(t, []byte(tc.diff), diff)
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package db
+
+import (
+	"bytes"
+	"sync"
+)
+
+// WriteHook observes, and can veto, the writes made through a HookDB. An error returned by a
+// Before hook vetoes the write, and is returned to the writer. Hooks are called concurrently by
+// concurrent writers. Keys and values passed to hooks must not be modified or retained.
+type WriteHook interface {
+	// BeforeSet is called before key is set to value, directly or in a batch.
+	BeforeSet(key, value []byte) error
+	// BeforeDelete is called before key is deleted, directly or in a batch.
+	BeforeDelete(key []byte) error
+	// BeforeDeleteRange is called before the keys in [start, end) are deleted, directly or in a
+	// batch.
+	BeforeDeleteRange(start, end []byte) error
+	// BeforeBatchWrite is called with every write of a batch, in order, before it is written.
+	BeforeBatchWrite(ops []Change) error
+	// AfterBatchWrite is called with the writes of every committed batch, and of every committed
+	// Set, Delete or DeleteRange, as a batch of one.
+	AfterBatchWrite(ops []Change)
+}
+
+// NopWriteHook is a WriteHook which allows every write. Embed it to implement only some hooks.
+type NopWriteHook struct{}
+
+var _ WriteHook = NopWriteHook{}
+
+// BeforeSet implements WriteHook.
+func (NopWriteHook) BeforeSet([]byte, []byte) error { return nil }
+
+// BeforeDelete implements WriteHook.
+func (NopWriteHook) BeforeDelete([]byte) error { return nil }
+
+// BeforeDeleteRange implements WriteHook.
+func (NopWriteHook) BeforeDeleteRange([]byte, []byte) error { return nil }
+
+// BeforeBatchWrite implements WriteHook.
+func (NopWriteHook) BeforeBatchWrite([]Change) error { return nil }
+
+// AfterBatchWrite implements WriteHook.
+func (NopWriteHook) AfterBatchWrite([]Change) {}
+
+// HookDB wraps a DB and calls the WriteHooks added to it around every write made through it, in
+// the order they were added. Writes made to the wrapped DB directly bypass the hooks.
+//
+// Hooks see keys as they are written to the HookDB: under a PrefixDB, they see keys without the
+// prefix. A hook written for the keys of a PrefixDB can be added to a HookDB below it instead,
+// scoped with PrefixWriteHook.
+type HookDB struct {
+	db    DB
+	mtx   sync.RWMutex
+	hooks []WriteHook
+}
+
+var (
+	_ DB        = (*HookDB)(nil)
+	_ Compactor = (*HookDB)(nil)
+)
+
+// NewHookDB wraps db, calling hooks around its writes.
+func NewHookDB(db DB, hooks ...WriteHook) *HookDB {
+	return &HookDB{db: db, hooks: hooks}
+}
+
+// AddHook adds a hook, called after those already added. It applies to batches created before
+// it was added too, once they are written.
+func (hdb *HookDB) AddHook(hook WriteHook) {
+	hdb.mtx.Lock()
+	defer hdb.mtx.Unlock()
+
+	// Hooks are copied on write, so that callers can run them without holding the lock.
+	hdb.hooks = append(hdb.hooks[:len(hdb.hooks):len(hdb.hooks)], hook)
+}
+
+// currentHooks returns the hooks to call for a write.
+func (hdb *HookDB) currentHooks() []WriteHook {
+	hdb.mtx.RLock()
+	defer hdb.mtx.RUnlock()
+
+	return hdb.hooks
+}
+
+// before calls fn with each hook, stopping at the first error.
+func (hdb *HookDB) before(fn func(WriteHook) error) error {
+	for _, hook := range hdb.currentHooks() {
+		if err := fn(hook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// after calls the AfterBatchWrite hooks with ops.
+func (hdb *HookDB) after(ops []Change) {
+	for _, hook := range hdb.currentHooks() {
+		hook.AfterBatchWrite(ops)
+	}
+}
+
+// write calls the before hooks of op, then fn, then the after hooks if fn succeeded.
+func (hdb *HookDB) write(op Change, fn func() error) error {
+	if err := hdb.before(func(hook WriteHook) error { return beforeOp(hook, op) }); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	hdb.after([]Change{op})
+	return nil
+}
+
+// beforeOp calls the Before hook of hook for the kind of op.
+func beforeOp(hook WriteHook, op Change) error {
+	switch op.Kind {
+	case ChangeSet:
+		return hook.BeforeSet(op.Key, op.Value)
+	case ChangeDelete:
+		return hook.BeforeDelete(op.Key)
+	default:
+		return hook.BeforeDeleteRange(op.Key, op.End)
+	}
+}
+
+// Get implements DB.
+func (hdb *HookDB) Get(key []byte) ([]byte, error) {
+	return hdb.db.Get(key)
+}
+
+// GetMany implements DB.
+func (hdb *HookDB) GetMany(keys [][]byte) ([][]byte, error) {
+	return hdb.db.GetMany(keys)
+}
+
+// View implements DB.
+func (hdb *HookDB) View(key []byte, fn func([]byte) error) error {
+	return hdb.db.View(key, fn)
+}
+
+// Has implements DB.
+func (hdb *HookDB) Has(key []byte) (bool, error) {
+	return hdb.db.Has(key)
+}
+
+// Set implements DB.
+func (hdb *HookDB) Set(key, value []byte) error {
+	op := Change{Kind: ChangeSet, Key: key, Value: value}
+	return hdb.write(op, func() error { return hdb.db.Set(key, value) })
+}
+
+// SetSync implements DB.
+func (hdb *HookDB) SetSync(key, value []byte) error {
+	op := Change{Kind: ChangeSet, Key: key, Value: value}
+	return hdb.write(op, func() error { return hdb.db.SetSync(key, value) })
+}
+
+// Delete implements DB.
+func (hdb *HookDB) Delete(key []byte) error {
+	op := Change{Kind: ChangeDelete, Key: key}
+	return hdb.write(op, func() error { return hdb.db.Delete(key) })
+}
+
+// DeleteSync implements DB.
+func (hdb *HookDB) DeleteSync(key []byte) error {
+	op := Change{Kind: ChangeDelete, Key: key}
+	return hdb.write(op, func() error { return hdb.db.DeleteSync(key) })
+}
+
+// DeleteRange implements DB.
+func (hdb *HookDB) DeleteRange(start, end []byte) error {
+	op := Change{Kind: ChangeDeleteRange, Key: start, End: end}
+	return hdb.write(op, func() error { return hdb.db.DeleteRange(start, end) })
+}
+
+// Iterator implements DB.
+func (hdb *HookDB) Iterator(start, end []byte) (Iterator, error) {
+	return hdb.db.Iterator(start, end)
+}
+
+// ReverseIterator implements DB.
+func (hdb *HookDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	return hdb.db.ReverseIterator(start, end)
+}
+
+// Close implements DB.
+func (hdb *HookDB) Close() error {
+	return hdb.db.Close()
+}
+
+// NewBatch implements DB.
+func (hdb *HookDB) NewBatch() Batch {
+	return newHookDBBatch(hdb.db.NewBatch(), hdb)
+}
+
+// NewBatchWithSize implements DB.
+func (hdb *HookDB) NewBatchWithSize(size int) Batch {
+	return newHookDBBatch(hdb.db.NewBatchWithSize(size), hdb)
+}
+
+// NewIndexedBatch implements DB.
+func (hdb *HookDB) NewIndexedBatch() IndexedBatch {
+	source := hdb.db.NewIndexedBatch()
+	return &hookDBIndexedBatch{
+		hookDBBatch: newHookDBBatch(source, hdb),
+		source:      source,
+	}
+}
+
+// NewSnapshot implements DB.
+func (hdb *HookDB) NewSnapshot() (Snapshot, error) {
+	return hdb.db.NewSnapshot()
+}
+
+// Backup implements DB.
+func (hdb *HookDB) Backup(destDir string) error {
+	return hdb.db.Backup(destDir)
+}
+
+// EstimateSize implements DB.
+func (hdb *HookDB) EstimateSize(start, end []byte) (uint64, error) {
+	return hdb.db.EstimateSize(start, end)
+}
+
+// EstimateKeys implements DB.
+func (hdb *HookDB) EstimateKeys(start, end []byte) (uint64, error) {
+	return hdb.db.EstimateKeys(start, end)
+}
+
+// Compact implements Compactor.
+// It is a noop if the wrapped DB is not a Compactor.
+func (hdb *HookDB) Compact(start, end []byte) error {
+	if c, ok := hdb.db.(Compactor); ok {
+		return c.Compact(start, end)
+	}
+	return nil
+}
+
+// Print implements DB.
+func (hdb *HookDB) Print() error {
+	return hdb.db.Print()
+}
+
+// Stats implements DB.
+func (hdb *HookDB) Stats() map[string]string {
+	return hdb.db.Stats()
+}
+
+// TypedStats implements DB.
+func (hdb *HookDB) TypedStats() DBStats {
+	return hdb.db.TypedStats()
+}
+
+// backendType implements backendTyper.
+func (hdb *HookDB) backendType() BackendType {
+	return backendOf(hdb.db)
+}
+
+// prefixWriteHook scopes a WriteHook to the keys under a prefix.
+type prefixWriteHook struct {
+	prefix []byte
+	hook   WriteHook
+}
+
+// PrefixWriteHook returns a WriteHook which calls hook for the writes of keys starting with
+// prefix only, with the prefix stripped from their keys, as a hook added to a HookDB wrapped by
+// NewPrefixDB(db, prefix) would see them. Range deletes are clipped to the prefix, and batch hooks
+// are called with the writes under the prefix, if there are any.
+func PrefixWriteHook(prefix []byte, hook WriteHook) WriteHook {
+	return &prefixWriteHook{prefix: cp(prefix), hook: hook}
+}
+
+// BeforeSet implements WriteHook.
+func (h *prefixWriteHook) BeforeSet(key, value []byte) error {
+	if !bytes.HasPrefix(key, h.prefix) {
+		return nil
+	}
+	return h.hook.BeforeSet(key[len(h.prefix):], value)
+}
+
+// BeforeDelete implements WriteHook.
+func (h *prefixWriteHook) BeforeDelete(key []byte) error {
+	if !bytes.HasPrefix(key, h.prefix) {
+		return nil
+	}
+	return h.hook.BeforeDelete(key[len(h.prefix):])
+}
+
+// BeforeDeleteRange implements WriteHook.
+func (h *prefixWriteHook) BeforeDeleteRange(start, end []byte) error {
+	ops := h.strip([]Change{{Kind: ChangeDeleteRange, Key: start, End: end}})
+	if len(ops) == 0 {
+		return nil
+	}
+	return h.hook.BeforeDeleteRange(ops[0].Key, ops[0].End)
+}
+
+// BeforeBatchWrite implements WriteHook.
+func (h *prefixWriteHook) BeforeBatchWrite(ops []Change) error {
+	if ops = h.strip(ops); len(ops) == 0 {
+		return nil
+	}
+	return h.hook.BeforeBatchWrite(ops)
+}
+
+// AfterBatchWrite implements WriteHook.
+func (h *prefixWriteHook) AfterBatchWrite(ops []Change) {
+	if ops = h.strip(ops); len(ops) > 0 {
+		h.hook.AfterBatchWrite(ops)
+	}
+}
+
+// strip returns the ops under the prefix, clipped to it, with the prefix stripped from their
+// keys. A range bound at the edge of the prefix becomes nil, unbounded. So does a range start of
+// 0x00, which PrefixDB.DeleteRange uses for a nil start, and which covers the same keys.
+func (h *prefixWriteHook) strip(ops []Change) []Change {
+	ops = filterChanges(ops, h.prefix)
+	if len(h.prefix) == 0 {
+		return ops
+	}
+	stripped := make([]Change, len(ops))
+	for i, op := range ops {
+		stripped[i] = Change{Kind: op.Kind, Key: op.Key[len(h.prefix):], Value: op.Value}
+		if len(stripped[i].Key) == 0 || (op.Kind == ChangeDeleteRange && bytes.Equal(stripped[i].Key, []byte{0x00})) {
+			stripped[i].Key = nil
+		}
+		if op.Kind == ChangeDeleteRange && bytes.HasPrefix(op.End, h.prefix) {
+			stripped[i].End = op.End[len(h.prefix):]
+		}
+	}
+	return stripped
+}
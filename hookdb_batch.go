@@ -0,0 +1,112 @@
+package db
+
+import "bytes"
+
+type hookDBBatch struct {
+	source Batch
+	hdb    *HookDB
+	// ops are the writes of the batch, passed to the batch hooks once it is written.
+	ops []Change
+}
+
+var _ Batch = (*hookDBBatch)(nil)
+
+func newHookDBBatch(source Batch, hdb *HookDB) *hookDBBatch {
+	return &hookDBBatch{
+		source: source,
+		hdb:    hdb,
+	}
+}
+
+// add calls the before hooks of op, and adds it to the batch with fn unless they veto it.
+func (b *hookDBBatch) add(op Change, fn func() error) error {
+	if err := b.hdb.before(func(hook WriteHook) error { return beforeOp(hook, op) }); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	b.ops = append(b.ops, op)
+	return nil
+}
+
+// Set implements Batch.
+func (b *hookDBBatch) Set(key, value []byte) error {
+	op := Change{Kind: ChangeSet, Key: cp(key), Value: cp(value)}
+	return b.add(op, func() error { return b.source.Set(key, value) })
+}
+
+// Delete implements Batch.
+func (b *hookDBBatch) Delete(key []byte) error {
+	op := Change{Kind: ChangeDelete, Key: cp(key)}
+	return b.add(op, func() error { return b.source.Delete(key) })
+}
+
+// DeleteRange implements Batch.
+func (b *hookDBBatch) DeleteRange(start, end []byte) error {
+	op := Change{Kind: ChangeDeleteRange, Key: bytes.Clone(start), End: bytes.Clone(end)}
+	return b.add(op, func() error { return b.source.DeleteRange(start, end) })
+}
+
+// Write implements Batch.
+func (b *hookDBBatch) Write() error {
+	return b.write(b.source.Write)
+}
+
+// WriteSync implements Batch.
+func (b *hookDBBatch) WriteSync() error {
+	return b.write(b.source.WriteSync)
+}
+
+// write calls the BeforeBatchWrite hooks, writes the batch with fn unless they veto it, and calls
+// the AfterBatchWrite hooks once it is written.
+func (b *hookDBBatch) write(fn func() error) error {
+	ops := b.ops
+	if err := b.hdb.before(func(hook WriteHook) error { return hook.BeforeBatchWrite(ops) }); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	b.ops = nil
+	b.hdb.after(ops)
+	return nil
+}
+
+// Close implements Batch.
+func (b *hookDBBatch) Close() error {
+	b.ops = nil
+	return b.source.Close()
+}
+
+// GetByteSize implements Batch.
+func (b *hookDBBatch) GetByteSize() (int, error) {
+	return b.source.GetByteSize()
+}
+
+type hookDBIndexedBatch struct {
+	*hookDBBatch
+	source IndexedBatch
+}
+
+var _ IndexedBatch = (*hookDBIndexedBatch)(nil)
+
+// Get implements IndexedBatch.
+func (b *hookDBIndexedBatch) Get(key []byte) ([]byte, error) {
+	return b.source.Get(key)
+}
+
+// Has implements IndexedBatch.
+func (b *hookDBIndexedBatch) Has(key []byte) (bool, error) {
+	return b.source.Has(key)
+}
+
+// Iterator implements IndexedBatch.
+func (b *hookDBIndexedBatch) Iterator(start, end []byte) (Iterator, error) {
+	return b.source.Iterator(start, end)
+}
+
+// ReverseIterator implements IndexedBatch.
+func (b *hookDBIndexedBatch) ReverseIterator(start, end []byte) (Iterator, error) {
+	return b.source.ReverseIterator(start, end)
+}
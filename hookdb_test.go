@@ -0,0 +1,152 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errProtected = errors.New("protected key")
+
+// protectHook vetoes every write of a key.
+type protectHook struct {
+	NopWriteHook
+	key []byte
+}
+
+func (h protectHook) BeforeSet(key, _ []byte) error {
+	return h.BeforeDelete(key)
+}
+
+func (h protectHook) BeforeDelete(key []byte) error {
+	if bytes.Equal(key, h.key) {
+		return errProtected
+	}
+	return nil
+}
+
+func (h protectHook) BeforeDeleteRange(start, end []byte) error {
+	if IsKeyInDomain(h.key, start, end) {
+		return errProtected
+	}
+	return nil
+}
+
+// auditHook records the batches written, and vetoes batches with more than max writes.
+type auditHook struct {
+	NopWriteHook
+	mtx     sync.Mutex
+	max     int
+	written [][]Change
+}
+
+func (h *auditHook) BeforeBatchWrite(ops []Change) error {
+	if h.max > 0 && len(ops) > h.max {
+		return fmt.Errorf("batch of %d writes is too large", len(ops))
+	}
+	return nil
+}
+
+func (h *auditHook) AfterBatchWrite(ops []Change) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.written = append(h.written, ops)
+}
+
+func TestHookDB(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testHookDB(t, dbType)
+		})
+	}
+}
+
+func testHookDB(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	source, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+	db := NewHookDB(source, protectHook{key: []byte("s/latest")})
+	defer db.Close()
+	audit := &auditHook{max: 3}
+	db.AddHook(audit)
+
+	require.NoError(t, source.Set([]byte("s/latest"), []byte{1}))
+	require.NoError(t, db.Set([]byte("s/1"), []byte{1}))
+	require.NoError(t, db.DeleteSync([]byte("s/1")))
+
+	// Vetoed writes are refused, and leave the key as it was.
+	require.ErrorIs(t, db.Set([]byte("s/latest"), []byte{2}), errProtected)
+	require.ErrorIs(t, db.DeleteSync([]byte("s/latest")), errProtected)
+	require.ErrorIs(t, db.DeleteRange([]byte("s/"), nil), errProtected)
+	batch := db.NewBatch()
+	require.ErrorIs(t, batch.Delete([]byte("s/latest")), errProtected)
+	require.NoError(t, batch.Set([]byte("s/2"), []byte{2}))
+	require.NoError(t, batch.DeleteRange([]byte("s/3"), []byte("s/4")))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	value, err := db.Get([]byte("s/latest"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, value)
+
+	// Batch hooks see every write of the batch, and can veto it as a whole.
+	batch = db.NewBatch()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, batch.Set([]byte{'k', byte(i)}, []byte{1}))
+	}
+	require.Error(t, batch.Write())
+	require.NoError(t, batch.Close())
+	ok, err := db.Has([]byte{'k', 0})
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.Equal(t, [][]Change{
+		{{Kind: ChangeSet, Key: []byte("s/1"), Value: []byte{1}}},
+		{{Kind: ChangeDelete, Key: []byte("s/1")}},
+		{
+			{Kind: ChangeSet, Key: []byte("s/2"), Value: []byte{2}},
+			{Kind: ChangeDeleteRange, Key: []byte("s/3"), End: []byte("s/4")},
+		},
+	}, audit.written)
+}
+
+func TestHookDBPrefix(t *testing.T) {
+	// A hook sees the same writes through a PrefixDB over a HookDB as through a HookDB over a
+	// PrefixDB.
+	below := &auditHook{}
+	above := &auditHook{}
+	root := NewHookDB(NewMemDB(), PrefixWriteHook([]byte("p/"), below))
+	prefixed := NewPrefixDB(root, []byte("p/"))
+	hooked := NewHookDB(prefixed, above)
+
+	require.NoError(t, root.Set([]byte("q/1"), []byte{1}))
+	require.NoError(t, hooked.Set([]byte("1"), []byte{1}))
+	require.NoError(t, hooked.Delete([]byte("2")))
+	require.NoError(t, hooked.DeleteRange(nil, []byte("5")))
+	require.NoError(t, hooked.DeleteRange([]byte("3"), nil))
+	batch := hooked.NewBatch()
+	require.NoError(t, batch.Set([]byte("4"), []byte{4}))
+	require.NoError(t, batch.DeleteRange(nil, nil))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	require.NoError(t, root.DeleteRange([]byte("a"), []byte("z")))
+
+	require.Len(t, above.written, 5)
+	require.Equal(t, above.written, below.written[:5])
+	require.Equal(t, []Change{{Kind: ChangeDeleteRange}}, below.written[5])
+
+	// Writes outside the prefix are not passed to the hook.
+	veto := PrefixWriteHook([]byte("p/"), protectHook{key: []byte("x")})
+	require.NoError(t, veto.BeforeSet([]byte("x"), []byte{1}))
+	require.ErrorIs(t, veto.BeforeSet([]byte("p/x"), []byte{1}), errProtected)
+	require.NoError(t, veto.BeforeDeleteRange([]byte("q/"), nil))
+	require.ErrorIs(t, veto.BeforeDeleteRange([]byte("o"), []byte("q")), errProtected)
+}
@@ -0,0 +1,349 @@
+package db
+
+import (
+	"bytes"
+)
+
+// keyRange is a [start, end) domain of keys, where nil bounds are unbounded.
+type keyRange struct {
+	start []byte
+	end   []byte
+}
+
+// indexedBatch is an IndexedBatch for backends without native support. Writes go to the
+// backend's own batch, and are mirrored into an in-memory index that reads consult before
+// falling through to the database.
+type indexedBatch struct {
+	db    DB
+	batch Batch
+	// pending holds the latest pending operation for each key. Since values can never be nil, a
+	// nil value marks a pending delete.
+	pending *MemDB
+	// ranges are the pending range deletes. Keys set after a range delete are in pending, which
+	// takes precedence.
+	ranges []keyRange
+}
+
+var _ IndexedBatch = (*indexedBatch)(nil)
+
+func newIndexedBatch(db DB, batch Batch) *indexedBatch {
+	return &indexedBatch{
+		db:      db,
+		batch:   batch,
+		pending: NewMemDB(),
+	}
+}
+
+// Set implements Batch.
+func (b *indexedBatch) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	if value == nil {
+		return errValueNil
+	}
+	if b.pending == nil {
+		return errBatchClosed
+	}
+	if err := b.batch.Set(key, value); err != nil {
+		return err
+	}
+	b.pending.mtx.Lock()
+	defer b.pending.mtx.Unlock()
+
+	b.pending.set(cp(key), cp(value))
+	return nil
+}
+
+// Delete implements Batch.
+func (b *indexedBatch) Delete(key []byte) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	if b.pending == nil {
+		return errBatchClosed
+	}
+	if err := b.batch.Delete(key); err != nil {
+		return err
+	}
+	b.pending.mtx.Lock()
+	defer b.pending.mtx.Unlock()
+
+	b.pending.set(cp(key), nil)
+	return nil
+}
+
+// DeleteRange implements Batch.
+func (b *indexedBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	if b.pending == nil {
+		return errBatchClosed
+	}
+	if err := b.batch.DeleteRange(start, end); err != nil {
+		return err
+	}
+	b.pending.mtx.Lock()
+	defer b.pending.mtx.Unlock()
+
+	b.pending.deleteRange(start, end)
+	b.ranges = append(b.ranges, keyRange{start: bytes.Clone(start), end: bytes.Clone(end)})
+	return nil
+}
+
+// Write implements Batch.
+func (b *indexedBatch) Write() error {
+	if b.pending == nil {
+		return errBatchClosed
+	}
+	if err := b.batch.Write(); err != nil {
+		return err
+	}
+	return b.Close()
+}
+
+// WriteSync implements Batch.
+func (b *indexedBatch) WriteSync() error {
+	if b.pending == nil {
+		return errBatchClosed
+	}
+	if err := b.batch.WriteSync(); err != nil {
+		return err
+	}
+	return b.Close()
+}
+
+// Close implements Batch.
+func (b *indexedBatch) Close() error {
+	b.pending = nil
+	b.ranges = nil
+	return b.batch.Close()
+}
+
+// GetByteSize implements Batch.
+func (b *indexedBatch) GetByteSize() (int, error) {
+	if b.pending == nil {
+		return 0, errBatchClosed
+	}
+	return b.batch.GetByteSize()
+}
+
+// Get implements IndexedBatch.
+func (b *indexedBatch) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	if b.pending == nil {
+		return nil, errBatchClosed
+	}
+	if value, ok := b.lookup(key); ok {
+		return value, nil
+	}
+	return b.db.Get(key)
+}
+
+// Has implements IndexedBatch.
+func (b *indexedBatch) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, errKeyEmpty
+	}
+	if b.pending == nil {
+		return false, errBatchClosed
+	}
+	if value, ok := b.lookup(key); ok {
+		return value != nil, nil
+	}
+	return b.db.Has(key)
+}
+
+// lookup resolves key against the pending operations. ok is false if the batch does not affect
+// the key, in which case the database decides.
+func (b *indexedBatch) lookup(key []byte) (value []byte, ok bool) {
+	b.pending.mtx.RLock()
+	i := b.pending.btree.Get(newKey(key))
+	b.pending.mtx.RUnlock()
+	if i != nil {
+		return i.(item).value, true
+	}
+	if isKeyInRanges(key, b.ranges) {
+		return nil, true
+	}
+	return nil, false
+}
+
+// Iterator implements IndexedBatch.
+func (b *indexedBatch) Iterator(start, end []byte) (Iterator, error) {
+	return b.iterator(start, end, false)
+}
+
+// ReverseIterator implements IndexedBatch.
+func (b *indexedBatch) ReverseIterator(start, end []byte) (Iterator, error) {
+	return b.iterator(start, end, true)
+}
+
+func (b *indexedBatch) iterator(start, end []byte, isReverse bool) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if b.pending == nil {
+		return nil, errBatchClosed
+	}
+	var (
+		source Iterator
+		err    error
+	)
+	if isReverse {
+		source, err = b.db.ReverseIterator(start, end)
+	} else {
+		source, err = b.db.Iterator(start, end)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pending := newMemDBIterator(b.pending, start, end, isReverse)
+	return newIndexedBatchIterator(source, pending, b.ranges, start, end, isReverse), nil
+}
+
+func isKeyInRanges(key []byte, ranges []keyRange) bool {
+	for _, r := range ranges {
+		if IsKeyInDomain(key, r.start, r.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexedBatchIterator merges the pending operations of an indexedBatch over a database
+// iterator. Pending sets shadow database keys, while pending deletes and range deletes hide them.
+type indexedBatchIterator struct {
+	source    Iterator
+	pending   Iterator
+	ranges    []keyRange
+	start     []byte
+	end       []byte
+	isReverse bool
+
+	// current is the iterator positioned at the current key, or nil once exhausted. When both
+	// iterators are at the same key, current is pending and the source key is shadowed.
+	current  Iterator
+	shadowed bool
+}
+
+var _ Iterator = (*indexedBatchIterator)(nil)
+
+func newIndexedBatchIterator(source, pending Iterator, ranges []keyRange, start, end []byte, isReverse bool) *indexedBatchIterator {
+	itr := &indexedBatchIterator{
+		source:    source,
+		pending:   pending,
+		ranges:    ranges,
+		start:     start,
+		end:       end,
+		isReverse: isReverse,
+	}
+	itr.settle()
+	return itr
+}
+
+// settle positions current on the next visible key, skipping database keys hidden by range
+// deletes and pending deletes.
+func (itr *indexedBatchIterator) settle() {
+	for {
+		itr.current = nil
+		itr.shadowed = false
+
+		for itr.source.Valid() && isKeyInRanges(itr.source.Key(), itr.ranges) {
+			itr.source.Next()
+		}
+		sourceValid, pendingValid := itr.source.Valid(), itr.pending.Valid()
+
+		switch {
+		case !sourceValid && !pendingValid:
+			return
+		case !pendingValid:
+			itr.current = itr.source
+			return
+		case sourceValid:
+			cmp := bytes.Compare(itr.pending.Key(), itr.source.Key())
+			if itr.isReverse {
+				cmp = -cmp
+			}
+			if cmp > 0 {
+				itr.current = itr.source
+				return
+			}
+			itr.shadowed = cmp == 0
+		}
+
+		itr.current = itr.pending
+		if itr.pending.Value() != nil {
+			return
+		}
+		// A pending delete: skip it, along with the database key it hides.
+		itr.advance()
+	}
+}
+
+// advance moves past the current key.
+func (itr *indexedBatchIterator) advance() {
+	if itr.shadowed {
+		itr.source.Next()
+	}
+	itr.current.Next()
+}
+
+// Domain implements Iterator.
+func (itr *indexedBatchIterator) Domain() ([]byte, []byte) {
+	return itr.start, itr.end
+}
+
+// Valid implements Iterator.
+func (itr *indexedBatchIterator) Valid() bool {
+	return itr.current != nil && itr.source.Error() == nil
+}
+
+// Next implements Iterator.
+func (itr *indexedBatchIterator) Next() {
+	itr.assertIsValid()
+	itr.advance()
+	itr.settle()
+}
+
+// Seek implements Iterator.
+func (itr *indexedBatchIterator) Seek(key []byte) {
+	itr.source.Seek(key)
+	itr.pending.Seek(key)
+	itr.settle()
+}
+
+// Key implements Iterator.
+func (itr *indexedBatchIterator) Key() []byte {
+	itr.assertIsValid()
+	return itr.current.Key()
+}
+
+// Value implements Iterator.
+func (itr *indexedBatchIterator) Value() []byte {
+	itr.assertIsValid()
+	return itr.current.Value()
+}
+
+// Error implements Iterator.
+func (itr *indexedBatchIterator) Error() error {
+	return itr.source.Error()
+}
+
+// Close implements Iterator.
+func (itr *indexedBatchIterator) Close() error {
+	itr.current = nil
+	if err := itr.pending.Close(); err != nil {
+		return err
+	}
+	return itr.source.Close()
+}
+
+func (itr *indexedBatchIterator) assertIsValid() {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+}
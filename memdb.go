@@ -1,8 +1,13 @@
 package db
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/google/btree"
@@ -11,12 +16,15 @@ import (
 const (
 	// The approximate number of items and children per B-tree node. Tuned with benchmarks.
 	bTreeDegree = 32
+
+	// memDBBackupFile is the file in a backup directory holding the contents of a MemDB.
+	memDBBackupFile = "MEMDB"
 )
 
 func init() {
 	registerDBCreator(MemDBBackend, func(name, dir string, opts Options) (DB, error) {
 		return NewMemDB(), nil
-	}, false)
+	}, Capabilities{Snapshots: true}, false)
 }
 
 // item is a btree.Item with byte slices as keys and values
@@ -53,7 +61,10 @@ type MemDB struct {
 	btree *btree.BTree
 }
 
-var _ DB = (*MemDB)(nil)
+var (
+	_ DB        = (*MemDB)(nil)
+	_ Compactor = (*MemDB)(nil)
+)
 
 // NewMemDB creates a new in-memory database.
 func NewMemDB() *MemDB {
@@ -78,6 +89,33 @@ func (db *MemDB) Get(key []byte) ([]byte, error) {
 	return nil, nil
 }
 
+// GetMany implements DB.
+func (db *MemDB) GetMany(keys [][]byte) ([][]byte, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		if it := db.btree.Get(newKey(key)); it != nil {
+			values[i] = it.(item).value
+		}
+	}
+	return values, nil
+}
+
+// View implements DB.
+// MemDB stores values without copying them, so fn is given the stored value.
+func (db *MemDB) View(key []byte, fn func([]byte) error) error {
+	value, err := db.Get(key)
+	if err != nil {
+		return err
+	}
+	return fn(value)
+}
+
 // Has implements DB.
 func (db *MemDB) Has(key []byte) (bool, error) {
 	if len(key) == 0 {
@@ -136,6 +174,70 @@ func (db *MemDB) DeleteSync(key []byte) error {
 	return db.Delete(key)
 }
 
+// DeleteRange implements DB.
+func (db *MemDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	db.deleteRange(start, end)
+	return nil
+}
+
+// deleteRange deletes all keys in [start, end) without locking the mutex.
+func (db *MemDB) deleteRange(start, end []byte) {
+	var keys [][]byte
+	visitor := func(i btree.Item) bool {
+		keys = append(keys, i.(item).key)
+		return true
+	}
+	switch {
+	case start == nil && end == nil:
+		db.btree.Ascend(visitor)
+	case end == nil:
+		db.btree.AscendGreaterOrEqual(newKey(start), visitor)
+	case start == nil:
+		db.btree.AscendLessThan(newKey(end), visitor)
+	default:
+		db.btree.AscendRange(newKey(start), newKey(end), visitor)
+	}
+	// The btree must not be modified while it is being traversed.
+	for _, key := range keys {
+		db.delete(key)
+	}
+}
+
+// EstimateSize implements DB.
+// MemDB counts the bytes of the keys and values in the domain exactly.
+func (db *MemDB) EstimateSize(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	_, size, err := countRange(db, start, end)
+	return size, err
+}
+
+// EstimateKeys implements DB.
+// MemDB counts the keys in the domain exactly.
+func (db *MemDB) EstimateKeys(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	keys, _, err := countRange(db, start, end)
+	return keys, err
+}
+
+// Compact implements Compactor.
+// It is a noop, since a MemDB frees deleted keys immediately.
+func (db *MemDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	return nil
+}
+
 // Close implements DB.
 func (db *MemDB) Close() error {
 	// Close is a noop since for an in-memory database, we don't have a destination to flush
@@ -159,13 +261,123 @@ func (db *MemDB) Print() error {
 
 // Stats implements DB.
 func (db *MemDB) Stats() map[string]string {
-	db.mtx.RLock()
-	defer db.mtx.RUnlock()
+	typed := db.TypedStats()
 
 	stats := make(map[string]string)
 	stats["database.type"] = "memDB"
-	stats["database.size"] = fmt.Sprintf("%d", db.btree.Len())
-	return stats
+	stats["database.size"] = fmt.Sprintf("%d", typed.KeyCountEstimate)
+	return typed.flatten(stats)
+}
+
+// TypedStats implements DB.
+func (db *MemDB) TypedStats() DBStats {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	return DBStats{
+		Backend:          MemDBBackend,
+		KeyCountEstimate: uint64(db.btree.Len()),
+	}
+}
+
+// backendType implements backendTyper.
+func (db *MemDB) backendType() BackendType {
+	return MemDBBackend
+}
+
+// nativeMetrics implements nativeMetricer.
+func (db *MemDB) nativeMetrics() []nativeMetric {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	return []nativeMetric{
+		{name: "memdb_keys", help: "Number of keys in the MemDB.", value: float64(db.btree.Len())},
+	}
+}
+
+// Backup implements DB.
+// The contents are written to a single file in destDir as length-prefixed keys and values.
+func (db *MemDB) Backup(destDir string) error {
+	if err := checkBackupDir(destDir); err != nil {
+		return err
+	}
+	db.mtx.Lock()
+	tree := db.btree.Clone()
+	db.mtx.Unlock()
+
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(destDir, memDBBackupFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	var buf []byte
+	tree.Ascend(func(i btree.Item) bool {
+		item := i.(item)
+		buf = binary.AppendUvarint(buf[:0], uint64(len(item.key)))
+		buf = append(buf, item.key...)
+		buf = binary.AppendUvarint(buf, uint64(len(item.value)))
+		buf = append(buf, item.value...)
+		_, err = w.Write(buf)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// LoadMemDBBackup reads a MemDB from the directory written by its Backup. The MemDB is in memory
+// only, like any other: its writes are not saved back to the backup. NewDB always opens an empty
+// MemDB, whatever is in its directory.
+func LoadMemDBBackup(backupDir string) (*MemDB, error) {
+	path := filepath.Join(backupDir, memDBBackupFile)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db := NewMemDB()
+	r := bufio.NewReader(f)
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		bz := make([]byte, n)
+		if _, err := io.ReadFull(r, bz); err != nil {
+			return nil, err
+		}
+		return bz, nil
+	}
+	for {
+		key, err := readBytes()
+		if err == io.EOF {
+			return db, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading memdb backup %s: %w", path, err)
+		}
+		value, err := readBytes()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("reading memdb backup %s: %w", path, err)
+		}
+		db.set(key, value)
+	}
 }
 
 // NewBatch implements DB.
@@ -179,6 +391,11 @@ func (db *MemDB) NewBatchWithSize(_ int) Batch {
 	return newMemDBBatch(db)
 }
 
+// NewIndexedBatch implements DB.
+func (db *MemDB) NewIndexedBatch() IndexedBatch {
+	return newIndexedBatch(db, newMemDBBatch(db))
+}
+
 // Iterator implements DB.
 // Takes out a read-lock on the database until the iterator is closed.
 func (db *MemDB) Iterator(start, end []byte) (Iterator, error) {
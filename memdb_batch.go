@@ -8,8 +8,11 @@ type opType int
 const (
 	opTypeSet opType = iota + 1
 	opTypeDelete
+	opTypeDeleteRange
 )
 
+// operation is a single batched write. For opTypeDeleteRange, key and value hold the start and
+// end of the range.
 type operation struct {
 	opType
 	key   []byte
@@ -63,6 +66,19 @@ func (b *memDBBatch) Delete(key []byte) error {
 	return nil
 }
 
+// DeleteRange implements Batch.
+func (b *memDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	if b.ops == nil {
+		return errBatchClosed
+	}
+	b.size += len(start) + len(end)
+	b.ops = append(b.ops, operation{opTypeDeleteRange, start, end})
+	return nil
+}
+
 // Write implements Batch.
 func (b *memDBBatch) Write() error {
 	if b.ops == nil {
@@ -77,6 +93,8 @@ func (b *memDBBatch) Write() error {
 			b.db.set(op.key, op.value)
 		case opTypeDelete:
 			b.db.delete(op.key)
+		case opTypeDeleteRange:
+			b.db.deleteRange(op.key, op.value)
 		default:
 			return fmt.Errorf("unknown operation type %v (%v)", op.opType, op)
 		}
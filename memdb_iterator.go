@@ -16,12 +16,14 @@ const (
 
 // memDBIterator is a memDB iterator.
 type memDBIterator struct {
-	ch     <-chan *item
-	cancel context.CancelFunc
-	item   *item
-	start  []byte
-	end    []byte
-	useMtx bool
+	db      *MemDB
+	ch      <-chan *item
+	cancel  context.CancelFunc
+	item    *item
+	start   []byte
+	end     []byte
+	reverse bool
+	useMtx  bool
 }
 
 var _ Iterator = (*memDBIterator)(nil)
@@ -32,16 +34,27 @@ func newMemDBIterator(db *MemDB, start, end []byte, reverse bool) *memDBIterator
 }
 
 func newMemDBIteratorMtxChoice(db *MemDB, start, end []byte, reverse, useMtx bool) *memDBIterator {
-	ctx, cancel := context.WithCancel(context.Background())
-	ch := make(chan *item, chBufferSize)
 	iter := &memDBIterator{
-		ch:     ch,
-		cancel: cancel,
-		start:  start,
-		end:    end,
-		useMtx: useMtx,
+		db:      db,
+		start:   start,
+		end:     end,
+		reverse: reverse,
+		useMtx:  useMtx,
 	}
+	iter.traverse(start, end)
+	return iter
+}
 
+// traverse starts a B-tree traversal of [start, end) in the iterator's direction, and primes the
+// iterator with the first item, if any.
+func (i *memDBIterator) traverse(start, end []byte) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan *item, chBufferSize)
+	i.ch = ch
+	i.cancel = cancel
+	i.item = nil
+
+	db, reverse, useMtx := i.db, i.reverse, i.useMtx
 	if useMtx {
 		db.mtx.RLock()
 	}
@@ -96,18 +109,21 @@ func newMemDBIteratorMtxChoice(db *MemDB, start, end []byte, reverse, useMtx boo
 
 	// prime the iterator with the first value, if any
 	if item, ok := <-ch; ok {
-		iter.item = item
+		i.item = item
 	}
-
-	return iter
 }
 
-// Close implements Iterator.
-func (i *memDBIterator) Close() error {
+// stop cancels the running traversal and waits for it to finish.
+func (i *memDBIterator) stop() {
 	i.cancel()
 	for range i.ch {
 	} // drain channel
 	i.item = nil
+}
+
+// Close implements Iterator.
+func (i *memDBIterator) Close() error {
+	i.stop()
 	return nil
 }
 
@@ -133,6 +149,21 @@ func (i *memDBIterator) Next() {
 	}
 }
 
+// Seek implements Iterator.
+// The B-tree traversal is restarted from the seek key.
+func (i *memDBIterator) Seek(key []byte) {
+	target, ok := seekTarget(key, i.start, i.end, i.reverse)
+	i.stop()
+	if !ok {
+		return
+	}
+	if i.reverse {
+		i.traverse(i.start, target)
+	} else {
+		i.traverse(target, i.end)
+	}
+}
+
 // Error implements Iterator.
 func (i *memDBIterator) Error() error {
 	return nil // famous last words
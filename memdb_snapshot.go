@@ -0,0 +1,58 @@
+package db
+
+// memDBSnapshot is a point-in-time view of a MemDB, backed by a copy-on-write clone of its B-tree.
+type memDBSnapshot struct {
+	db *MemDB
+}
+
+var _ Snapshot = (*memDBSnapshot)(nil)
+
+// NewSnapshot implements DB.
+// Cloning the B-tree is cheap: nodes are shared until either tree is modified.
+func (db *MemDB) NewSnapshot() (Snapshot, error) {
+	// Clone mutates the copy-on-write context of the source tree, so it needs the write lock.
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	return &memDBSnapshot{
+		db: &MemDB{btree: db.btree.Clone()},
+	}, nil
+}
+
+// Get implements Snapshot.
+func (s *memDBSnapshot) Get(key []byte) ([]byte, error) {
+	if s.db == nil {
+		return nil, errSnapshotClosed
+	}
+	return s.db.Get(key)
+}
+
+// Has implements Snapshot.
+func (s *memDBSnapshot) Has(key []byte) (bool, error) {
+	if s.db == nil {
+		return false, errSnapshotClosed
+	}
+	return s.db.Has(key)
+}
+
+// Iterator implements Snapshot.
+func (s *memDBSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	if s.db == nil {
+		return nil, errSnapshotClosed
+	}
+	return s.db.Iterator(start, end)
+}
+
+// ReverseIterator implements Snapshot.
+func (s *memDBSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	if s.db == nil {
+		return nil, errSnapshotClosed
+	}
+	return s.db.ReverseIterator(start, end)
+}
+
+// Close implements Snapshot.
+func (s *memDBSnapshot) Close() error {
+	s.db = nil
+	return nil
+}
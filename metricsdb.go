@@ -0,0 +1,384 @@
+package db
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "cosmos_db"
+
+// Operation labels used by MetricsDB.
+const (
+	metricsOpGet            = "get"
+	metricsOpGetMany        = "get_many"
+	metricsOpView           = "view"
+	metricsOpHas            = "has"
+	metricsOpSet            = "set"
+	metricsOpSetSync        = "set_sync"
+	metricsOpDelete         = "delete"
+	metricsOpDeleteSync     = "delete_sync"
+	metricsOpDeleteRange    = "delete_range"
+	metricsOpBatchWrite     = "batch_write"
+	metricsOpBatchWriteSync = "batch_write_sync"
+	metricsOpIterator       = "iterator"
+)
+
+// MetricsDB wraps a DB and records Prometheus metrics for its operations: latency histograms and
+// error counts per operation, read and written byte volumes, and iterator lifetimes. Metrics
+// native to the backend, such as pebble.Metrics or the leveldb DBStats, are exported as well.
+type MetricsDB struct {
+	db       DB
+	registry prometheus.Registerer
+	metrics  *dbMetrics
+}
+
+var (
+	_ DB        = (*MetricsDB)(nil)
+	_ Compactor = (*MetricsDB)(nil)
+)
+
+// nativeMetric is a metric read from a backend, exported by MetricsDB under the cosmos_db
+// namespace.
+type nativeMetric struct {
+	name    string
+	help    string
+	labels  map[string]string
+	value   float64
+	counter bool
+}
+
+// nativeMetricer is implemented by backends with metrics of their own.
+type nativeMetricer interface {
+	nativeMetrics() []nativeMetric
+}
+
+type dbMetrics struct {
+	opDuration   *prometheus.HistogramVec
+	opErrors     *prometheus.CounterVec
+	readBytes    prometheus.Counter
+	writtenBytes prometheus.Counter
+	iterLifetime prometheus.Histogram
+	itersOpen    prometheus.Gauge
+	native       *nativeCollector
+}
+
+// NewMetricsDB wraps db, registering its metrics with registry. The labels are added to every
+// metric, and are needed to tell databases apart when several share a registry. The metrics
+// are unregistered when the database is closed.
+func NewMetricsDB(db DB, registry prometheus.Registerer, labels map[string]string) (*MetricsDB, error) {
+	constLabels := prometheus.Labels(labels)
+	m := &dbMetrics{
+		opDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Name:        "operation_duration_seconds",
+			Help:        "Duration of database operations.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(1e-6, 4, 12),
+		}, []string{"operation"}),
+		opErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "operation_errors_total",
+			Help:        "Number of database operations which returned an error.",
+			ConstLabels: constLabels,
+		}, []string{"operation"}),
+		readBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "read_bytes_total",
+			Help:        "Bytes of values read with Get.",
+			ConstLabels: constLabels,
+		}),
+		writtenBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "written_bytes_total",
+			Help:        "Bytes of keys and values written, directly or through batches.",
+			ConstLabels: constLabels,
+		}),
+		iterLifetime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Name:        "iterator_lifetime_seconds",
+			Help:        "Time from opening an iterator to closing it.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(1e-5, 4, 12),
+		}),
+		itersOpen: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "open_iterators",
+			Help:        "Number of iterators which have not been closed.",
+			ConstLabels: constLabels,
+		}),
+		native: &nativeCollector{db: db, labels: constLabels},
+	}
+
+	mdb := &MetricsDB{db: db, registry: registry, metrics: m}
+	for i, c := range mdb.collectors() {
+		if err := registry.Register(c); err != nil {
+			for _, registered := range mdb.collectors()[:i] {
+				registry.Unregister(registered)
+			}
+			return nil, err
+		}
+	}
+	return mdb, nil
+}
+
+func (mdb *MetricsDB) collectors() []prometheus.Collector {
+	m := mdb.metrics
+	return []prometheus.Collector{
+		m.opDuration, m.opErrors, m.readBytes, m.writtenBytes, m.iterLifetime, m.itersOpen, m.native,
+	}
+}
+
+// observe records the duration and outcome of an operation started at start.
+func (m *dbMetrics) observe(op string, start time.Time, err error) {
+	m.opDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.opErrors.WithLabelValues(op).Inc()
+	}
+}
+
+// Get implements DB.
+func (mdb *MetricsDB) Get(key []byte) ([]byte, error) {
+	start := time.Now()
+	value, err := mdb.db.Get(key)
+	mdb.metrics.observe(metricsOpGet, start, err)
+	mdb.metrics.readBytes.Add(float64(len(value)))
+	return value, err
+}
+
+// GetMany implements DB.
+func (mdb *MetricsDB) GetMany(keys [][]byte) ([][]byte, error) {
+	start := time.Now()
+	values, err := mdb.db.GetMany(keys)
+	mdb.metrics.observe(metricsOpGetMany, start, err)
+	for _, value := range values {
+		mdb.metrics.readBytes.Add(float64(len(value)))
+	}
+	return values, err
+}
+
+// View implements DB.
+// The latency recorded includes the time spent in fn.
+func (mdb *MetricsDB) View(key []byte, fn func([]byte) error) error {
+	start := time.Now()
+	var size int
+	err := mdb.db.View(key, func(value []byte) error {
+		size = len(value)
+		return fn(value)
+	})
+	mdb.metrics.observe(metricsOpView, start, err)
+	mdb.metrics.readBytes.Add(float64(size))
+	return err
+}
+
+// Has implements DB.
+func (mdb *MetricsDB) Has(key []byte) (bool, error) {
+	start := time.Now()
+	ok, err := mdb.db.Has(key)
+	mdb.metrics.observe(metricsOpHas, start, err)
+	return ok, err
+}
+
+// Set implements DB.
+func (mdb *MetricsDB) Set(key, value []byte) error {
+	start := time.Now()
+	err := mdb.db.Set(key, value)
+	mdb.metrics.observe(metricsOpSet, start, err)
+	if err == nil {
+		mdb.metrics.writtenBytes.Add(float64(len(key) + len(value)))
+	}
+	return err
+}
+
+// SetSync implements DB.
+func (mdb *MetricsDB) SetSync(key, value []byte) error {
+	start := time.Now()
+	err := mdb.db.SetSync(key, value)
+	mdb.metrics.observe(metricsOpSetSync, start, err)
+	if err == nil {
+		mdb.metrics.writtenBytes.Add(float64(len(key) + len(value)))
+	}
+	return err
+}
+
+// Delete implements DB.
+func (mdb *MetricsDB) Delete(key []byte) error {
+	start := time.Now()
+	err := mdb.db.Delete(key)
+	mdb.metrics.observe(metricsOpDelete, start, err)
+	return err
+}
+
+// DeleteSync implements DB.
+func (mdb *MetricsDB) DeleteSync(key []byte) error {
+	start := time.Now()
+	err := mdb.db.DeleteSync(key)
+	mdb.metrics.observe(metricsOpDeleteSync, start, err)
+	return err
+}
+
+// DeleteRange implements DB.
+func (mdb *MetricsDB) DeleteRange(start, end []byte) error {
+	began := time.Now()
+	err := mdb.db.DeleteRange(start, end)
+	mdb.metrics.observe(metricsOpDeleteRange, began, err)
+	return err
+}
+
+// Iterator implements DB.
+func (mdb *MetricsDB) Iterator(start, end []byte) (Iterator, error) {
+	began := time.Now()
+	itr, err := mdb.db.Iterator(start, end)
+	mdb.metrics.observe(metricsOpIterator, began, err)
+	if err != nil {
+		return nil, err
+	}
+	return newMetricsDBIterator(itr, mdb.metrics), nil
+}
+
+// ReverseIterator implements DB.
+func (mdb *MetricsDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	began := time.Now()
+	itr, err := mdb.db.ReverseIterator(start, end)
+	mdb.metrics.observe(metricsOpIterator, began, err)
+	if err != nil {
+		return nil, err
+	}
+	return newMetricsDBIterator(itr, mdb.metrics), nil
+}
+
+// Close implements DB.
+// It also unregisters the metrics of the database.
+func (mdb *MetricsDB) Close() error {
+	for _, c := range mdb.collectors() {
+		mdb.registry.Unregister(c)
+	}
+	// Unchecked collectors cannot be unregistered, so the native collector is detached instead.
+	mdb.metrics.native.detach()
+	return mdb.db.Close()
+}
+
+// NewBatch implements DB.
+func (mdb *MetricsDB) NewBatch() Batch {
+	return newMetricsDBBatch(mdb.db.NewBatch(), mdb.metrics)
+}
+
+// NewBatchWithSize implements DB.
+func (mdb *MetricsDB) NewBatchWithSize(size int) Batch {
+	return newMetricsDBBatch(mdb.db.NewBatchWithSize(size), mdb.metrics)
+}
+
+// NewIndexedBatch implements DB.
+func (mdb *MetricsDB) NewIndexedBatch() IndexedBatch {
+	source := mdb.db.NewIndexedBatch()
+	return &metricsDBIndexedBatch{
+		metricsDBBatch: newMetricsDBBatch(source, mdb.metrics),
+		source:         source,
+	}
+}
+
+// NewSnapshot implements DB.
+func (mdb *MetricsDB) NewSnapshot() (Snapshot, error) {
+	return mdb.db.NewSnapshot()
+}
+
+// Backup implements DB.
+func (mdb *MetricsDB) Backup(destDir string) error {
+	return mdb.db.Backup(destDir)
+}
+
+// EstimateSize implements DB.
+func (mdb *MetricsDB) EstimateSize(start, end []byte) (uint64, error) {
+	return mdb.db.EstimateSize(start, end)
+}
+
+// EstimateKeys implements DB.
+func (mdb *MetricsDB) EstimateKeys(start, end []byte) (uint64, error) {
+	return mdb.db.EstimateKeys(start, end)
+}
+
+// Compact implements Compactor.
+// It is a noop if the wrapped DB is not a Compactor.
+func (mdb *MetricsDB) Compact(start, end []byte) error {
+	if c, ok := mdb.db.(Compactor); ok {
+		return c.Compact(start, end)
+	}
+	return nil
+}
+
+// Print implements DB.
+func (mdb *MetricsDB) Print() error {
+	return mdb.db.Print()
+}
+
+// Stats implements DB.
+func (mdb *MetricsDB) Stats() map[string]string {
+	return mdb.db.Stats()
+}
+
+// TypedStats implements DB.
+func (mdb *MetricsDB) TypedStats() DBStats {
+	return mdb.db.TypedStats()
+}
+
+// backendType implements backendTyper.
+func (mdb *MetricsDB) backendType() BackendType {
+	return backendOf(mdb.db)
+}
+
+// nativeCollector exports the native metrics of a backend. The set of metrics depends on the
+// backend, so the collector is unchecked and describes none of them up front.
+type nativeCollector struct {
+	mtx    sync.RWMutex
+	db     DB
+	labels prometheus.Labels
+}
+
+var _ prometheus.Collector = (*nativeCollector)(nil)
+
+// Describe implements prometheus.Collector.
+func (c *nativeCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *nativeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	source, ok := c.db.(nativeMetricer)
+	if !ok {
+		return
+	}
+	for _, m := range source.nativeMetrics() {
+		labelNames := make([]string, 0, len(m.labels))
+		for name := range m.labels {
+			labelNames = append(labelNames, name)
+		}
+		sort.Strings(labelNames)
+		labelValues := make([]string, len(labelNames))
+		for i, name := range labelNames {
+			labelValues[i] = m.labels[name]
+		}
+
+		valueType := prometheus.GaugeValue
+		if m.counter {
+			valueType = prometheus.CounterValue
+		}
+		desc := prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", m.name), m.help, labelNames, c.labels)
+		metric, err := prometheus.NewConstMetric(desc, valueType, m.value, labelValues...)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+		ch <- metric
+	}
+}
+
+// detach stops the collector from reading the database, once it is closed.
+func (c *nativeCollector) detach() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.db = nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import "time"
+
+type metricsDBBatch struct {
+	source  Batch
+	metrics *dbMetrics
+	// size is the number of key and value bytes in the batch, counted once it is written.
+	size int
+}
+
+var _ Batch = (*metricsDBBatch)(nil)
+
+func newMetricsDBBatch(source Batch, metrics *dbMetrics) *metricsDBBatch {
+	return &metricsDBBatch{
+		source:  source,
+		metrics: metrics,
+	}
+}
+
+// Set implements Batch.
+func (b *metricsDBBatch) Set(key, value []byte) error {
+	if err := b.source.Set(key, value); err != nil {
+		return err
+	}
+	b.size += len(key) + len(value)
+	return nil
+}
+
+// Delete implements Batch.
+func (b *metricsDBBatch) Delete(key []byte) error {
+	return b.source.Delete(key)
+}
+
+// DeleteRange implements Batch.
+func (b *metricsDBBatch) DeleteRange(start, end []byte) error {
+	return b.source.DeleteRange(start, end)
+}
+
+// Write implements Batch.
+func (b *metricsDBBatch) Write() error {
+	start := time.Now()
+	err := b.source.Write()
+	b.observeWrite(metricsOpBatchWrite, start, err)
+	return err
+}
+
+// WriteSync implements Batch.
+func (b *metricsDBBatch) WriteSync() error {
+	start := time.Now()
+	err := b.source.WriteSync()
+	b.observeWrite(metricsOpBatchWriteSync, start, err)
+	return err
+}
+
+func (b *metricsDBBatch) observeWrite(op string, start time.Time, err error) {
+	b.metrics.observe(op, start, err)
+	if err == nil {
+		b.metrics.writtenBytes.Add(float64(b.size))
+		b.size = 0
+	}
+}
+
+// Close implements Batch.
+func (b *metricsDBBatch) Close() error {
+	return b.source.Close()
+}
+
+// GetByteSize implements Batch.
+func (b *metricsDBBatch) GetByteSize() (int, error) {
+	return b.source.GetByteSize()
+}
+
+type metricsDBIndexedBatch struct {
+	*metricsDBBatch
+	source IndexedBatch
+}
+
+var _ IndexedBatch = (*metricsDBIndexedBatch)(nil)
+
+// Get implements IndexedBatch.
+func (b *metricsDBIndexedBatch) Get(key []byte) ([]byte, error) {
+	return b.source.Get(key)
+}
+
+// Has implements IndexedBatch.
+func (b *metricsDBIndexedBatch) Has(key []byte) (bool, error) {
+	return b.source.Has(key)
+}
+
+// Iterator implements IndexedBatch.
+func (b *metricsDBIndexedBatch) Iterator(start, end []byte) (Iterator, error) {
+	itr, err := b.source.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newMetricsDBIterator(itr, b.metrics), nil
+}
+
+// ReverseIterator implements IndexedBatch.
+func (b *metricsDBIndexedBatch) ReverseIterator(start, end []byte) (Iterator, error) {
+	itr, err := b.source.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newMetricsDBIterator(itr, b.metrics), nil
+}
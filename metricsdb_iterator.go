@@ -0,0 +1,32 @@
+package db
+
+import "time"
+
+// metricsDBIterator records the lifetime of an iterator.
+type metricsDBIterator struct {
+	Iterator
+	metrics *dbMetrics
+	opened  time.Time
+	closed  bool
+}
+
+var _ Iterator = (*metricsDBIterator)(nil)
+
+func newMetricsDBIterator(source Iterator, metrics *dbMetrics) *metricsDBIterator {
+	metrics.itersOpen.Inc()
+	return &metricsDBIterator{
+		Iterator: source,
+		metrics:  metrics,
+		opened:   time.Now(),
+	}
+}
+
+// Close implements Iterator.
+func (itr *metricsDBIterator) Close() error {
+	if !itr.closed {
+		itr.closed = true
+		itr.metrics.itersOpen.Dec()
+		itr.metrics.iterLifetime.Observe(time.Since(itr.opened).Seconds())
+	}
+	return itr.Iterator.Close()
+}
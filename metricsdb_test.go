@@ -0,0 +1,130 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsDB(t *testing.T) {
+	for dbType := range backends {
+		t.Run(fmt.Sprintf("%v", dbType), func(t *testing.T) {
+			testMetricsDB(t, dbType)
+		})
+	}
+}
+
+func testMetricsDB(t *testing.T, backend BackendType) {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	db, err := NewDB(name, backend, dir)
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+
+	registry := prometheus.NewRegistry()
+	mdb, err := NewMetricsDB(db, registry, map[string]string{"db": name})
+	require.NoError(t, err)
+
+	// a second database with the same labels collides with the first
+	_, err = NewMetricsDB(NewMemDB(), registry, map[string]string{"db": name})
+	require.Error(t, err)
+
+	require.NoError(t, mdb.Set([]byte("a"), []byte("1")))
+	batch := mdb.NewBatch()
+	require.NoError(t, batch.Set([]byte("b"), []byte("22")))
+	require.NoError(t, batch.Write())
+	require.NoError(t, batch.Close())
+	value, err := mdb.Get([]byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("22"), value)
+	_, err = mdb.Get(nil)
+	require.Error(t, err)
+
+	itr, err := mdb.Iterator(nil, nil)
+	require.NoError(t, err)
+	verifyMetric(t, registry, "cosmos_db_open_iterators", func(m *dto.Metric) {
+		require.Equal(t, 1.0, m.GetGauge().GetValue())
+	})
+	require.NoError(t, itr.Close())
+
+	verifyMetric(t, registry, "cosmos_db_open_iterators", func(m *dto.Metric) {
+		require.Equal(t, 0.0, m.GetGauge().GetValue())
+	})
+	verifyMetric(t, registry, "cosmos_db_iterator_lifetime_seconds", func(m *dto.Metric) {
+		require.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+	})
+	verifyMetric(t, registry, "cosmos_db_written_bytes_total", func(m *dto.Metric) {
+		require.Equal(t, 5.0, m.GetCounter().GetValue())
+	})
+	verifyMetric(t, registry, "cosmos_db_read_bytes_total", func(m *dto.Metric) {
+		require.Equal(t, 2.0, m.GetCounter().GetValue())
+	})
+	verifyMetric(t, registry, "cosmos_db_operation_errors_total", func(m *dto.Metric) {
+		require.Equal(t, "get", operationLabel(m))
+		require.Equal(t, 1.0, m.GetCounter().GetValue())
+	})
+
+	counts := map[string]uint64{}
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	nativeMetrics := map[BackendType]string{
+		MemDBBackend:     "cosmos_db_memdb_keys",
+		GoLevelDBBackend: "cosmos_db_leveldb_opened_tables",
+		PebbleDBBackend:  "cosmos_db_pebble_compactions_total",
+		RocksDBBackend:   "cosmos_db_rocksdb_estimated_keys",
+		TreeDBBackend:    "cosmos_db_treedb_stat",
+	}
+	native := false
+	for _, family := range families {
+		if family.GetName() == "cosmos_db_operation_duration_seconds" {
+			for _, m := range family.GetMetric() {
+				counts[operationLabel(m)] = m.GetHistogram().GetSampleCount()
+			}
+		}
+		if family.GetName() == nativeMetrics[backend] {
+			native = true
+		}
+	}
+	require.Equal(t, map[string]uint64{"get": 2, "set": 1, "batch_write": 1, "iterator": 1}, counts)
+	if _, ok := nativeMetrics[backend]; ok {
+		require.True(t, native, "no native metrics")
+	}
+
+	// closing unregisters the metrics, so the name can be reused
+	require.NoError(t, mdb.Close())
+	families, err = registry.Gather()
+	require.NoError(t, err)
+	require.Empty(t, families)
+	_, err = NewMetricsDB(NewMemDB(), registry, map[string]string{"db": name})
+	require.NoError(t, err)
+}
+
+func verifyMetric(t *testing.T, registry *prometheus.Registry, name string, verify func(*dto.Metric)) {
+	t.Helper()
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == name {
+			require.Len(t, family.GetMetric(), 1)
+			verify(family.GetMetric()[0])
+			return
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+}
+
+func operationLabel(m *dto.Metric) string {
+	for _, label := range m.GetLabel() {
+		if label.GetName() == "operation" {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
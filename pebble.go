@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 
 	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/bloom"
 	"github.com/spf13/cast"
 )
 
@@ -63,7 +67,7 @@ var (
 )
 
 func init() {
-	registerDBCreator(PebbleDBBackend, NewPebbleDB, false)
+	registerDBCreator(PebbleDBBackend, NewPebbleDB, Capabilities{Persistent: true, Snapshots: true, DeleteRange: true}, false)
 
 	if ForceSync == "1" {
 		isForceSync = true
@@ -73,10 +77,47 @@ func init() {
 // PebbleDB is a PebbleDB backend.
 type PebbleDB struct {
 	db *pebble.DB
+	// writeStalls counts the write stalls reported by pebble.
+	writeStalls atomic.Uint64
+	// keyCount is the key count estimated from the sstable properties.
+	keyCount cachedStat
 }
 
-var _ DB = (*PebbleDB)(nil)
+var (
+	_ DB        = (*PebbleDB)(nil)
+	_ Compactor = (*PebbleDB)(nil)
+)
+
+// Options read by NewPebbleDB, besides maxopenfiles. Sizes are in bytes. The per-level options
+// take either a single value applied to every level, or a list of values starting at L0, where
+// the last value also applies to all deeper levels. Compressions are "none", "snappy" or "zstd",
+// and zero bloom filter bits disable the filter for a level.
+const (
+	PebbleOptBlockCacheSize           = "pebble.block_cache_size"
+	PebbleOptMemTableSize             = "pebble.memtable_size"
+	PebbleOptL0CompactionThreshold    = "pebble.l0_compaction_threshold"
+	PebbleOptL0StopWritesThreshold    = "pebble.l0_stop_writes_threshold"
+	PebbleOptBloomBitsPerKey          = "pebble.bloom_bits_per_key"
+	PebbleOptCompression              = "pebble.compression"
+	PebbleOptBytesPerSync             = "pebble.bytes_per_sync"
+	PebbleOptWALDir                   = "pebble.wal_dir"
+	PebbleOptMaxConcurrentCompactions = "pebble.max_concurrent_compactions"
+)
+
+var pebbleOptKeys = []string{
+	PebbleOptBlockCacheSize,
+	PebbleOptMemTableSize,
+	PebbleOptL0CompactionThreshold,
+	PebbleOptL0StopWritesThreshold,
+	PebbleOptBloomBitsPerKey,
+	PebbleOptCompression,
+	PebbleOptBytesPerSync,
+	PebbleOptWALDir,
+	PebbleOptMaxConcurrentCompactions,
+}
 
+// NewPebbleDB opens the PebbleDB name in dir. With OptReadOnly set, writes fail with the errors
+// of pebble rather than ErrReadOnly; open it through NewReadOnlyDB for those.
 func NewPebbleDB(name, dir string, opts Options) (DB, error) {
 	do := &pebble.Options{
 		Logger: &fatalLogger{}, // pebble info logs are messing up the logs
@@ -91,16 +132,189 @@ func NewPebbleDB(name, dir string, opts Options) (DB, error) {
 		if files > 0 {
 			do.MaxOpenFiles = files
 		}
+		if err := applyPebbleOptions(do, opts); err != nil {
+			return nil, err
+		}
+		readOnly, err := isReadOnly(opts)
+		if err != nil {
+			return nil, err
+		}
+		if readOnly {
+			do.ReadOnly = true
+			do.ErrorIfNotExists = true
+		}
 	}
+	if do.Cache != nil {
+		// pebble takes its own reference to the cache.
+		defer do.Cache.Unref()
+	}
+
+	db := &PebbleDB{}
+	do.AddEventListener(pebble.EventListener{
+		WriteStallBegin: func(pebble.WriteStallBeginInfo) { db.writeStalls.Add(1) },
+	})
 
 	dbPath := filepath.Join(dir, name+DBFileSuffix)
 	p, err := pebble.Open(dbPath, do)
 	if err != nil {
 		return nil, err
 	}
-	return &PebbleDB{
-		db: p,
-	}, err
+	db.db = p
+	return db, nil
+}
+
+// applyPebbleOptions applies the pebble.* options to do. Options which can be enumerated, such
+// as an OptionsMap, are also checked for unknown pebble.* keys.
+func applyPebbleOptions(do *pebble.Options, opts Options) error {
+	if keyed, ok := opts.(KeyedOptions); ok {
+		for _, key := range keyed.AllKeys() {
+			if strings.HasPrefix(key, "pebble.") && !slices.Contains(pebbleOptKeys, key) {
+				return fmt.Errorf("unknown pebble option %q", key)
+			}
+		}
+	}
+
+	var (
+		bloomBits    []int
+		compressions []pebble.Compression
+	)
+	for _, key := range pebbleOptKeys {
+		value := opts.Get(key)
+		if value == nil {
+			continue
+		}
+		var err error
+		switch key {
+		case PebbleOptBlockCacheSize:
+			var size int64
+			if size, err = toNonNegativeInt64(value); err == nil {
+				do.Cache = pebble.NewCache(size)
+			}
+		case PebbleOptMemTableSize:
+			var size int64
+			if size, err = toNonNegativeInt64(value); err == nil {
+				do.MemTableSize = uint64(size)
+			}
+		case PebbleOptL0CompactionThreshold:
+			do.L0CompactionThreshold, err = cast.ToIntE(value)
+		case PebbleOptL0StopWritesThreshold:
+			do.L0StopWritesThreshold, err = cast.ToIntE(value)
+		case PebbleOptBloomBitsPerKey:
+			bloomBits, err = toIntPerLevel(value)
+		case PebbleOptCompression:
+			compressions, err = toCompressionPerLevel(value)
+		case PebbleOptBytesPerSync:
+			var size int64
+			if size, err = toNonNegativeInt64(value); err == nil {
+				do.BytesPerSync = int(size)
+			}
+		case PebbleOptWALDir:
+			do.WALDir, err = cast.ToStringE(value)
+		case PebbleOptMaxConcurrentCompactions:
+			var n int
+			if n, err = cast.ToIntE(value); err == nil && n < 1 {
+				err = fmt.Errorf("must be at least 1, got %d", n)
+			}
+			do.MaxConcurrentCompactions = func() int { return n }
+		}
+		if err != nil {
+			if do.Cache != nil {
+				do.Cache.Unref()
+			}
+			return fmt.Errorf("invalid pebble option %q: %w", key, err)
+		}
+	}
+
+	levels := max(len(bloomBits), len(compressions))
+	for len(do.Levels) < levels {
+		l := do.Levels[len(do.Levels)-1]
+		l.TargetFileSize *= 2
+		do.Levels = append(do.Levels, l)
+	}
+	for i := range do.Levels {
+		if len(bloomBits) > 0 {
+			bits := bloomBits[min(i, len(bloomBits)-1)]
+			if bits > 0 {
+				do.Levels[i].FilterPolicy = bloom.FilterPolicy(bits)
+				do.Levels[i].FilterType = pebble.TableFilter
+			} else {
+				do.Levels[i].FilterPolicy = nil
+			}
+		}
+		if len(compressions) > 0 {
+			do.Levels[i].Compression = compressions[min(i, len(compressions)-1)]
+		}
+	}
+	return nil
+}
+
+func toNonNegativeInt64(value interface{}) (int64, error) {
+	n, err := cast.ToInt64E(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative, got %d", n)
+	}
+	return n, nil
+}
+
+// toIntPerLevel reads an int, or a list of ints, for the per-level options.
+func toIntPerLevel(value interface{}) ([]int, error) {
+	var values []int
+	switch value.(type) {
+	case []int, []interface{}:
+		var err error
+		if values, err = cast.ToIntSliceE(value); err != nil {
+			return nil, err
+		}
+	default:
+		n, err := cast.ToIntE(value)
+		if err != nil {
+			return nil, err
+		}
+		values = []int{n}
+	}
+	if len(values) == 0 {
+		return nil, errors.New("no levels given")
+	}
+	for _, n := range values {
+		if n < 0 {
+			return nil, fmt.Errorf("must not be negative, got %d", n)
+		}
+	}
+	return values, nil
+}
+
+// toCompressionPerLevel reads a compression name, or a list of names, for the per-level options.
+// A single string may also list the names separated by commas.
+func toCompressionPerLevel(value interface{}) ([]pebble.Compression, error) {
+	var names []string
+	if s, ok := value.(string); ok {
+		names = strings.Split(s, ",")
+	} else {
+		var err error
+		if names, err = cast.ToStringSliceE(value); err != nil {
+			return nil, err
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no levels given")
+	}
+	compressions := make([]pebble.Compression, len(names))
+	for i, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "none":
+			compressions[i] = pebble.NoCompression
+		case "snappy":
+			compressions[i] = pebble.SnappyCompression
+		case "zstd":
+			compressions[i] = pebble.ZstdCompression
+		default:
+			return nil, fmt.Errorf("unknown compression %q", name)
+		}
+	}
+	return compressions, nil
 }
 
 // Get implements DB.
@@ -121,6 +335,66 @@ func (db *PebbleDB) Get(key []byte) ([]byte, error) {
 	return cp(res), nil
 }
 
+// GetMany implements DB.
+// The keys are looked up in sorted order with a single iterator, which moves forward through
+// the database instead of searching it from the top for every key.
+func (db *PebbleDB) GetMany(keys [][]byte) ([][]byte, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return bytes.Compare(keys[a], keys[b])
+	})
+	itr, err := db.db.NewIter(&pebble.IterOptions{
+		LowerBound: keys[order[0]],
+		UpperBound: append(cp(keys[order[len(order)-1]]), 0x00),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range order {
+		if itr.SeekGE(keys[i]) && bytes.Equal(itr.Key(), keys[i]) {
+			values[i] = cp(itr.Value())
+		}
+	}
+	if err := itr.Error(); err != nil {
+		_ = itr.Close()
+		return nil, err
+	}
+	return values, itr.Close()
+}
+
+// View implements DB.
+// fn is given the value buffer of pebble, which is released when it returns.
+func (db *PebbleDB) View(key []byte, fn func([]byte) error) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+
+	res, closer, err := db.db.Get(key)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return fn(nil)
+		}
+		return err
+	}
+	defer closer.Close()
+
+	if res == nil {
+		res = []byte{}
+	}
+	return fn(res)
+}
+
 // Has implements DB.
 func (db *PebbleDB) Has(key []byte) (bool, error) {
 	// fmt.Println("PebbleDB.Has")
@@ -195,6 +469,23 @@ func (db *PebbleDB) DeleteSync(key []byte) error {
 	return db.db.Delete(key, pebble.Sync)
 }
 
+// DeleteRange implements DB.
+func (db *PebbleDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	start, end, ok, err := resolveDeleteRange(db, start, end, nil)
+	if err != nil || !ok {
+		return err
+	}
+
+	wopts := pebble.NoSync
+	if isForceSync {
+		wopts = pebble.Sync
+	}
+	return db.db.DeleteRange(start, end, wopts)
+}
+
 func (db *PebbleDB) DB() *pebble.DB {
 	return db.db
 }
@@ -206,6 +497,109 @@ func (db *PebbleDB) Close() error {
 	return nil
 }
 
+// Backup implements DB.
+// It creates a pebble checkpoint, which hard-links the sstables where possible.
+func (db *PebbleDB) Backup(destDir string) error {
+	if err := checkBackupDir(destDir); err != nil {
+		return err
+	}
+	return db.db.Checkpoint(destDir, pebble.WithFlushedWAL())
+}
+
+// EstimateSize implements DB.
+// It uses the EstimateDiskUsage of pebble, which only covers keys flushed to sstables.
+func (db *PebbleDB) EstimateSize(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	return db.diskUsage(start, end)
+}
+
+// EstimateKeys implements DB.
+// pebble does not count keys by range, so the count is extrapolated from EstimateSize.
+func (db *PebbleDB) EstimateKeys(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	return estimateKeys(db, db.diskUsage, start, end)
+}
+
+// diskUsage returns the size of [start, end) on disk. EstimateDiskUsage needs a bounded domain,
+// so a nil end is replaced by the largest key in the sstables.
+func (db *PebbleDB) diskUsage(start, end []byte) (uint64, error) {
+	if start == nil {
+		start = []byte{}
+	}
+	if end == nil {
+		largest, err := db.largestKey()
+		if err != nil || largest == nil {
+			return 0, err
+		}
+		end = append(largest, 0x00)
+	}
+	if bytes.Compare(start, end) >= 0 {
+		return 0, nil
+	}
+	return db.db.EstimateDiskUsage(start, end)
+}
+
+// Compact implements Compactor.
+// pebble needs both bounds, so a nil end is resolved to just past the largest key in any sstable
+// or the memtable. Deleted keys are still in the sstables until they are compacted, which is what
+// the caller is after.
+func (db *PebbleDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	lo, hi := start, end
+	if lo == nil {
+		lo = []byte{}
+	}
+	if hi == nil {
+		var err error
+		if hi, err = db.largestKey(); err != nil {
+			return err
+		}
+		if hi == nil {
+			return nil
+		}
+		hi = append(hi, 0x00)
+	}
+	if bytes.Compare(lo, hi) >= 0 {
+		return nil
+	}
+	return db.db.Compact(lo, hi, true)
+}
+
+// largestKey returns the largest key in the database, including deleted keys still held in
+// sstables, or nil if the database is empty.
+func (db *PebbleDB) largestKey() ([]byte, error) {
+	var largest []byte
+	itr, err := db.ReverseIterator(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if itr.Valid() {
+		largest = cp(itr.Key())
+	}
+	if err := itr.Close(); err != nil {
+		return nil, err
+	}
+
+	levels, err := db.db.SSTables()
+	if err != nil {
+		return nil, err
+	}
+	for _, tables := range levels {
+		for _, table := range tables {
+			if key := table.Largest.UserKey; bytes.Compare(key, largest) > 0 {
+				largest = cp(key)
+			}
+		}
+	}
+	return largest, nil
+}
+
 // Print implements DB.
 func (db *PebbleDB) Print() error {
 	itr, err := db.Iterator(nil, nil)
@@ -223,14 +617,77 @@ func (db *PebbleDB) Print() error {
 
 // Stats implements DB.
 func (db *PebbleDB) Stats() map[string]string {
-	/*
-		keys := []string{"rocksdb.stats"}
-		stats := make(map[string]string, len(keys))
-		for _, key := range keys {
-			stats[key] = db.(key)
+	return db.TypedStats().flatten(nil)
+}
+
+// TypedStats implements DB.
+func (db *PebbleDB) TypedStats() DBStats {
+	m := db.db.Metrics()
+	return DBStats{
+		Backend:          PebbleDBBackend,
+		KeyCountEstimate: db.keyCount.get(db.keyCountEstimate),
+		DiskSize:         m.DiskSpaceUsage(),
+		MemtableSize:     m.MemTable.Size,
+		CacheHitRatio:    hitRatio(m.BlockCache.Hits, m.BlockCache.Misses),
+		CompactionDebt:   m.Compact.EstimatedDebt,
+		OpenIterators:    uint64(m.TableIters),
+		WriteStalls:      db.writeStalls.Load(),
+	}
+}
+
+// backendType implements backendTyper.
+func (db *PebbleDB) backendType() BackendType {
+	return PebbleDBBackend
+}
+
+// keyCountEstimate estimates the number of keys from the properties of the sstables, as the
+// entries they hold less their deletions. Keys still in memtables are not counted.
+func (db *PebbleDB) keyCountEstimate() uint64 {
+	tables, err := db.db.SSTables(pebble.WithProperties())
+	if err != nil {
+		return 0
+	}
+	var entries, deletions uint64
+	for _, level := range tables {
+		for _, table := range level {
+			entries += table.Properties.NumEntries
+			deletions += table.Properties.NumDeletions
 		}
-	*/
-	return nil
+	}
+	if deletions >= entries {
+		return 0
+	}
+	return entries - deletions
+}
+
+// nativeMetrics implements nativeMetricer.
+func (db *PebbleDB) nativeMetrics() []nativeMetric {
+	m := db.db.Metrics()
+	metrics := []nativeMetric{
+		{name: "pebble_block_cache_size_bytes", help: "Size of the block cache.", value: float64(m.BlockCache.Size)},
+		{name: "pebble_block_cache_hits_total", help: "Number of block cache hits.", value: float64(m.BlockCache.Hits), counter: true},
+		{name: "pebble_block_cache_misses_total", help: "Number of block cache misses.", value: float64(m.BlockCache.Misses), counter: true},
+		{name: "pebble_compactions_total", help: "Number of compactions.", value: float64(m.Compact.Count), counter: true},
+		{name: "pebble_compaction_debt_bytes", help: "Estimated bytes to compact for the LSM to reach a stable state.", value: float64(m.Compact.EstimatedDebt)},
+		{name: "pebble_compactions_in_progress", help: "Number of compactions in progress.", value: float64(m.Compact.NumInProgress)},
+		{name: "pebble_flushes_total", help: "Number of memtable flushes.", value: float64(m.Flush.Count), counter: true},
+		{name: "pebble_memtable_size_bytes", help: "Size of the memtables.", value: float64(m.MemTable.Size)},
+		{name: "pebble_memtables", help: "Number of memtables.", value: float64(m.MemTable.Count)},
+		{name: "pebble_read_amplification", help: "Number of sublevels and levels read by a point lookup.", value: float64(m.ReadAmp())},
+		{name: "pebble_disk_usage_bytes", help: "Disk space used by the database.", value: float64(m.DiskSpaceUsage())},
+		{name: "pebble_wal_size_bytes", help: "Size of the live WAL files.", value: float64(m.WAL.Size)},
+		{name: "pebble_table_iterators", help: "Number of open sstable iterators.", value: float64(m.TableIters)},
+		{name: "pebble_snapshots", help: "Number of open snapshots.", value: float64(m.Snapshots.Count)},
+	}
+	for level, lm := range m.Levels {
+		labels := map[string]string{"level": fmt.Sprint(level)}
+		metrics = append(metrics,
+			nativeMetric{name: "pebble_level_files", help: "Number of sstables in a level.", labels: labels, value: float64(lm.NumFiles)},
+			nativeMetric{name: "pebble_level_size_bytes", help: "Size of the sstables in a level.", labels: labels, value: float64(lm.Size)},
+			nativeMetric{name: "pebble_level_score", help: "Compaction score of a level.", labels: labels, value: lm.Score},
+		)
+	}
+	return metrics
 }
 
 // NewBatch implements DB.
@@ -244,6 +701,16 @@ func (db *PebbleDB) NewBatchWithSize(_ int) Batch {
 	return newPebbleDBBatch(db)
 }
 
+// NewIndexedBatch implements DB.
+func (db *PebbleDB) NewIndexedBatch() IndexedBatch {
+	return &pebbleDBIndexedBatch{
+		pebbleDBBatch: pebbleDBBatch{
+			db:    db,
+			batch: db.db.NewIndexedBatch(),
+		},
+	}
+}
+
 // Iterator implements DB.
 func (db *PebbleDB) Iterator(start, end []byte) (Iterator, error) {
 	// fmt.Println("PebbleDB.Iterator")
@@ -281,16 +748,113 @@ func (db *PebbleDB) ReverseIterator(start, end []byte) (Iterator, error) {
 	return newPebbleDBIterator(itr, start, end, true), nil
 }
 
+// NewSnapshot implements DB.
+func (db *PebbleDB) NewSnapshot() (Snapshot, error) {
+	return &pebbleDBSnapshot{snap: db.db.NewSnapshot()}, nil
+}
+
+type pebbleDBSnapshot struct {
+	snap *pebble.Snapshot
+}
+
+var _ Snapshot = (*pebbleDBSnapshot)(nil)
+
+// Get implements Snapshot.
+func (s *pebbleDBSnapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+
+	res, closer, err := s.snap.Get(key)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer closer.Close()
+
+	return cp(res), nil
+}
+
+// Has implements Snapshot.
+func (s *pebbleDBSnapshot) Has(key []byte) (bool, error) {
+	bz, err := s.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return bz != nil, nil
+}
+
+// Iterator implements Snapshot.
+func (s *pebbleDBSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	o := pebble.IterOptions{
+		LowerBound: start,
+		UpperBound: end,
+	}
+	itr, err := s.snap.NewIter(&o)
+	if err != nil {
+		return nil, err
+	}
+	itr.First()
+
+	return newPebbleDBIterator(itr, start, end, false), nil
+}
+
+// ReverseIterator implements Snapshot.
+func (s *pebbleDBSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	o := pebble.IterOptions{
+		LowerBound: start,
+		UpperBound: end,
+	}
+	itr, err := s.snap.NewIter(&o)
+	if err != nil {
+		return nil, err
+	}
+	itr.Last()
+
+	return newPebbleDBIterator(itr, start, end, true), nil
+}
+
+// Close implements Snapshot.
+func (s *pebbleDBSnapshot) Close() error {
+	if s.snap == nil {
+		return nil
+	}
+	err := s.snap.Close()
+	s.snap = nil
+	return err
+}
+
 var _ Batch = (*pebbleDBBatch)(nil)
 
 type pebbleDBBatch struct {
+	db    *PebbleDB
 	batch *pebble.Batch
+	// maxKey is the largest key set in the batch, covered by range deletes with a nil end.
+	maxKey []byte
 }
 
 var _ Batch = (*pebbleDBBatch)(nil)
 
 func newPebbleDBBatch(db *PebbleDB) *pebbleDBBatch {
 	return &pebbleDBBatch{
+		db:    db,
 		batch: db.db.NewBatch(),
 	}
 }
@@ -306,7 +870,18 @@ func (b *pebbleDBBatch) Set(key, value []byte) error {
 	if b.batch == nil {
 		return errBatchClosed
 	}
-	return b.batch.Set(key, value, nil)
+	if err := b.batch.Set(key, value, nil); err != nil {
+		return err
+	}
+	b.setMaxKey(key)
+	return nil
+}
+
+// setMaxKey records key as the largest key set in the batch, if it is.
+func (b *pebbleDBBatch) setMaxKey(key []byte) {
+	if bytes.Compare(key, b.maxKey) > 0 {
+		b.maxKey = cp(key)
+	}
 }
 
 // Delete implements Batch.
@@ -320,6 +895,23 @@ func (b *pebbleDBBatch) Delete(key []byte) error {
 	return b.batch.Delete(key, nil)
 }
 
+// DeleteRange implements Batch.
+// A nil end is resolved when DeleteRange is called, against the keys in the database and the
+// keys set earlier in this batch. Keys written to the database after this call are not deleted.
+func (b *pebbleDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	if b.batch == nil {
+		return errBatchClosed
+	}
+	start, end, ok, err := resolveDeleteRange(b.db, start, end, b.maxKey)
+	if err != nil || !ok {
+		return err
+	}
+	return b.batch.DeleteRange(start, end, nil)
+}
+
 // Write implements Batch.
 func (b *pebbleDBBatch) Write() error {
 	if b.batch == nil {
@@ -375,6 +967,86 @@ func (b *pebbleDBBatch) GetByteSize() (int, error) {
 	return b.batch.Len(), nil
 }
 
+// pebbleDBIndexedBatch is a pebbleDBBatch over a pebble indexed batch, which natively merges its
+// pending operations over the database on reads.
+type pebbleDBIndexedBatch struct {
+	pebbleDBBatch
+}
+
+var _ IndexedBatch = (*pebbleDBIndexedBatch)(nil)
+
+// Get implements IndexedBatch.
+func (b *pebbleDBIndexedBatch) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	if b.batch == nil {
+		return nil, errBatchClosed
+	}
+
+	res, closer, err := b.batch.Get(key)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer closer.Close()
+
+	return cp(res), nil
+}
+
+// Has implements IndexedBatch.
+func (b *pebbleDBIndexedBatch) Has(key []byte) (bool, error) {
+	bz, err := b.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return bz != nil, nil
+}
+
+// Iterator implements IndexedBatch.
+func (b *pebbleDBIndexedBatch) Iterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if b.batch == nil {
+		return nil, errBatchClosed
+	}
+	o := pebble.IterOptions{
+		LowerBound: start,
+		UpperBound: end,
+	}
+	itr, err := b.batch.NewIter(&o)
+	if err != nil {
+		return nil, err
+	}
+	itr.First()
+
+	return newPebbleDBIterator(itr, start, end, false), nil
+}
+
+// ReverseIterator implements IndexedBatch.
+func (b *pebbleDBIndexedBatch) ReverseIterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if b.batch == nil {
+		return nil, errBatchClosed
+	}
+	o := pebble.IterOptions{
+		LowerBound: start,
+		UpperBound: end,
+	}
+	itr, err := b.batch.NewIter(&o)
+	if err != nil {
+		return nil, err
+	}
+	itr.Last()
+
+	return newPebbleDBIterator(itr, start, end, true), nil
+}
+
 type pebbleDBIterator struct {
 	source     *pebble.Iterator
 	start, end []byte
@@ -474,6 +1146,21 @@ func (itr *pebbleDBIterator) Next() {
 	}
 }
 
+// Seek implements Iterator.
+func (itr *pebbleDBIterator) Seek(key []byte) {
+	target, ok := seekTarget(key, itr.start, itr.end, itr.isReverse)
+	if !ok {
+		itr.isInvalid = true
+		return
+	}
+	itr.isInvalid = false
+	if itr.isReverse {
+		itr.source.SeekLT(target)
+	} else {
+		itr.source.SeekGE(target)
+	}
+}
+
 // Error implements Iterator.
 func (itr *pebbleDBIterator) Error() error {
 	return itr.source.Error()
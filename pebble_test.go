@@ -3,6 +3,7 @@ package db
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -19,6 +20,68 @@ func TestPebbleDBBackend(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestPebbleDBOptions(t *testing.T) {
+	name := fmt.Sprintf("test_%x", randStr(12))
+	dir := os.TempDir()
+	walDir := filepath.Join(t.TempDir(), "wal")
+	db, err := NewPebbleDB(name, dir, OptionsMap{
+		"maxopenfiles":                    100,
+		PebbleOptBlockCacheSize:           8 << 20,
+		PebbleOptMemTableSize:             "4194304",
+		PebbleOptL0CompactionThreshold:    2,
+		PebbleOptL0StopWritesThreshold:    16,
+		PebbleOptBloomBitsPerKey:          []interface{}{10, 10, 0},
+		PebbleOptCompression:              "none,snappy,zstd",
+		PebbleOptBytesPerSync:             1 << 20,
+		PebbleOptWALDir:                   walDir,
+		PebbleOptMaxConcurrentCompactions: 2,
+	})
+	require.NoError(t, err)
+	defer cleanupDBDir(dir, name)
+
+	require.NoError(t, db.Set([]byte("key"), []byte("value")))
+	require.NoError(t, db.Close())
+	require.DirExists(t, walDir)
+}
+
+// appOptions are Options listing their keys, as the viper-backed AppOptions of the SDK do.
+type appOptions map[string]interface{}
+
+func (o appOptions) Get(key string) interface{} { return o[key] }
+
+func (o appOptions) AllKeys() []string { return OptionsMap(o).AllKeys() }
+
+func TestPebbleDBOptionsUnknownKey(t *testing.T) {
+	// unknown keys are rejected in any Options which can list them
+	_, err := NewPebbleDB(fmt.Sprintf("test_%x", randStr(12)), t.TempDir(), appOptions{
+		PebbleOptMemTableSize: 64 << 20,
+		"pebble.memtable":     64 << 20,
+	})
+	require.ErrorContains(t, err, `unknown pebble option "pebble.memtable"`)
+}
+
+func TestPebbleDBOptionsInvalid(t *testing.T) {
+	testCases := map[string]OptionsMap{
+		"unknown key":        {"pebble.block_cache": 1},
+		"mistyped size":      {PebbleOptMemTableSize: "large"},
+		"negative size":      {PebbleOptBlockCacheSize: -1},
+		"mistyped threshold": {PebbleOptL0CompactionThreshold: []int{1}},
+		"negative bloom":     {PebbleOptBloomBitsPerKey: []int{10, -1}},
+		"empty bloom levels": {PebbleOptBloomBitsPerKey: []int{}},
+		"unknown codec":      {PebbleOptCompression: []string{"snappy", "lz4"}},
+		"no compactions":     {PebbleOptMaxConcurrentCompactions: 0},
+		"stop before start":  {PebbleOptL0CompactionThreshold: 8, PebbleOptL0StopWritesThreshold: 4},
+	}
+	for desc, opts := range testCases {
+		t.Run(desc, func(t *testing.T) {
+			name := fmt.Sprintf("test_%x", randStr(12))
+			dir := t.TempDir()
+			_, err := NewPebbleDB(name, dir, opts)
+			require.Error(t, err)
+		})
+	}
+}
+
 // func TestPebbleDBStats(t *testing.T) {
 // 	name := fmt.Sprintf("test_%x", randStr(12))
 // 	dir := os.TempDir()
@@ -12,7 +12,10 @@ type PrefixDB struct {
 	db     DB
 }
 
-var _ DB = (*PrefixDB)(nil)
+var (
+	_ DB        = (*PrefixDB)(nil)
+	_ Compactor = (*PrefixDB)(nil)
+)
 
 type appendGetter interface {
 	GetAppend(key, dst []byte) ([]byte, error)
@@ -73,6 +76,26 @@ func (pdb *PrefixDB) Checkpoint() error {
 	return nil
 }
 
+// GetMany implements DB.
+func (pdb *PrefixDB) GetMany(keys [][]byte) ([][]byte, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	pkeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		pkeys[i] = pdb.prefixed(key)
+	}
+	return pdb.db.GetMany(pkeys)
+}
+
+// View implements DB.
+func (pdb *PrefixDB) View(key []byte, fn func([]byte) error) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	return pdb.db.View(pdb.prefixed(key), fn)
+}
+
 // Has implements DB.
 func (pdb *PrefixDB) Has(key []byte) (bool, error) {
 	if len(key) == 0 {
@@ -133,19 +156,23 @@ func (pdb *PrefixDB) DeleteSync(key []byte) error {
 	return pdb.db.DeleteSync(pdb.prefixed(key))
 }
 
+// DeleteRange implements DB.
+func (pdb *PrefixDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+
+	pStart, pEnd := prefixedDeleteRange(pdb.prefix, start, end)
+	return pdb.db.DeleteRange(pStart, pEnd)
+}
+
 // Iterator implements DB.
 func (pdb *PrefixDB) Iterator(start, end []byte) (Iterator, error) {
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
 		return nil, errKeyEmpty
 	}
 
-	var pStart, pEnd []byte
-	pStart = append(cp(pdb.prefix), start...)
-	if end == nil {
-		pEnd = cpIncr(pdb.prefix)
-	} else {
-		pEnd = append(cp(pdb.prefix), end...)
-	}
+	pStart, pEnd := pdb.prefixedRange(start, end)
 	itr, err := pdb.db.Iterator(pStart, pEnd)
 	if err != nil {
 		return nil, err
@@ -160,13 +187,7 @@ func (pdb *PrefixDB) ReverseIterator(start, end []byte) (Iterator, error) {
 		return nil, errKeyEmpty
 	}
 
-	var pStart, pEnd []byte
-	pStart = append(cp(pdb.prefix), start...)
-	if end == nil {
-		pEnd = cpIncr(pdb.prefix)
-	} else {
-		pEnd = append(cp(pdb.prefix), end...)
-	}
+	pStart, pEnd := pdb.prefixedRange(start, end)
 	ritr, err := pdb.db.ReverseIterator(pStart, pEnd)
 	if err != nil {
 		return nil, err
@@ -185,6 +206,12 @@ func (pdb *PrefixDB) NewBatchWithSize(size int) Batch {
 	return newPrefixBatch(pdb.prefix, pdb.db.NewBatchWithSize(size))
 }
 
+// NewIndexedBatch implements DB.
+// Reads go through the PrefixDB, so they stay within the prefix.
+func (pdb *PrefixDB) NewIndexedBatch() IndexedBatch {
+	return newIndexedBatch(pdb, pdb.NewBatch())
+}
+
 // Close implements DB.
 func (pdb *PrefixDB) Close() error {
 	pdb.mtx.Lock()
@@ -193,6 +220,44 @@ func (pdb *PrefixDB) Close() error {
 	return pdb.db.Close()
 }
 
+// Backup implements DB.
+// The backup is of the whole underlying database, not only the prefix.
+func (pdb *PrefixDB) Backup(destDir string) error {
+	return pdb.db.Backup(destDir)
+}
+
+// EstimateSize implements DB.
+func (pdb *PrefixDB) EstimateSize(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	pStart, pEnd := pdb.prefixedRange(start, end)
+	return pdb.db.EstimateSize(pStart, pEnd)
+}
+
+// EstimateKeys implements DB.
+func (pdb *PrefixDB) EstimateKeys(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	pStart, pEnd := pdb.prefixedRange(start, end)
+	return pdb.db.EstimateKeys(pStart, pEnd)
+}
+
+// Compact implements Compactor.
+// The range is translated into the prefix, and compacted if the underlying DB is a Compactor.
+func (pdb *PrefixDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	c, ok := pdb.db.(Compactor)
+	if !ok {
+		return nil
+	}
+	pStart, pEnd := pdb.prefixedRange(start, end)
+	return c.Compact(pStart, pEnd)
+}
+
 // Print implements DB.
 func (pdb *PrefixDB) Print() error {
 	fmt.Printf("prefix: %X\n", pdb.prefix)
@@ -219,9 +284,50 @@ func (pdb *PrefixDB) Stats() map[string]string {
 	for key, value := range source {
 		stats["prefixdb.source."+key] = value
 	}
-	return stats
+	return pdb.TypedStats().flatten(stats)
+}
+
+// TypedStats implements DB.
+// The stats are those of the whole underlying database.
+func (pdb *PrefixDB) TypedStats() DBStats {
+	return pdb.db.TypedStats()
+}
+
+// backendType implements backendTyper.
+func (pdb *PrefixDB) backendType() BackendType {
+	return backendOf(pdb.db)
 }
 
 func (pdb *PrefixDB) prefixed(key []byte) []byte {
-	return append(cp(pdb.prefix), key...)
+	return prefixed(pdb.prefix, key)
+}
+
+func (pdb *PrefixDB) prefixedRange(start, end []byte) ([]byte, []byte) {
+	return prefixedRange(pdb.prefix, start, end)
+}
+
+func prefixed(prefix, key []byte) []byte {
+	return append(cp(prefix), key...)
+}
+
+// prefixedRange maps the domain [start, end) of a prefixed namespace onto the
+// underlying database. A nil end maps to the end of the namespace.
+func prefixedRange(prefix, start, end []byte) (pStart, pEnd []byte) {
+	pStart = append(cp(prefix), start...)
+	if end == nil {
+		pEnd = cpIncr(prefix)
+	} else {
+		pEnd = append(cp(prefix), end...)
+	}
+	return pStart, pEnd
+}
+
+// prefixedDeleteRange is like prefixedRange, except that a nil start excludes the key equal to
+// the prefix itself, which maps to the (invalid) empty key and is therefore outside the namespace.
+func prefixedDeleteRange(prefix, start, end []byte) (pStart, pEnd []byte) {
+	pStart, pEnd = prefixedRange(prefix, start, end)
+	if start == nil {
+		pStart = append(pStart, 0x00)
+	}
+	return pStart, pEnd
 }
@@ -72,6 +72,15 @@ func (pb prefixDBBatch) DeleteView(key []byte) error {
 	return pb.source.Delete(pkey)
 }
 
+// DeleteRange implements Batch.
+func (pb prefixDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	pStart, pEnd := prefixedDeleteRange(pb.prefix, start, end)
+	return pb.source.DeleteRange(pStart, pEnd)
+}
+
 // Write implements Batch.
 func (pb prefixDBBatch) Write() error {
 	return pb.source.Write()
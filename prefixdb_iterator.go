@@ -88,6 +88,16 @@ func (itr *prefixDBIterator) Next() {
 	}
 }
 
+// Seek implements Iterator.
+// The underlying iterator clamps the prefixed key to its own domain.
+func (itr *prefixDBIterator) Seek(key []byte) {
+	if len(key) == 0 {
+		panic("seek key cannot be empty")
+	}
+	itr.source.Seek(append(cp(itr.prefix), key...))
+	itr.valid = itr.seekToPrefix()
+}
+
 // Key implements Iterator.
 func (itr *prefixDBIterator) Key() []byte {
 	itr.assertIsValid()
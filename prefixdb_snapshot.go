@@ -0,0 +1,72 @@
+package db
+
+type prefixDBSnapshot struct {
+	prefix []byte
+	source Snapshot
+}
+
+var _ Snapshot = (*prefixDBSnapshot)(nil)
+
+// NewSnapshot implements DB.
+// The snapshot covers the whole underlying database, reads are restricted to the prefix.
+func (pdb *PrefixDB) NewSnapshot() (Snapshot, error) {
+	source, err := pdb.db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &prefixDBSnapshot{
+		prefix: pdb.prefix,
+		source: source,
+	}, nil
+}
+
+// Get implements Snapshot.
+func (s *prefixDBSnapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	return s.source.Get(prefixed(s.prefix, key))
+}
+
+// Has implements Snapshot.
+func (s *prefixDBSnapshot) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, errKeyEmpty
+	}
+	return s.source.Has(prefixed(s.prefix, key))
+}
+
+// Iterator implements Snapshot.
+func (s *prefixDBSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+
+	pStart, pEnd := prefixedRange(s.prefix, start, end)
+	itr, err := s.source.Iterator(pStart, pEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPrefixIterator(s.prefix, start, end, itr)
+}
+
+// ReverseIterator implements Snapshot.
+func (s *prefixDBSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+
+	pStart, pEnd := prefixedRange(s.prefix, start, end)
+	ritr, err := s.source.ReverseIterator(pStart, pEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPrefixIterator(s.prefix, start, end, ritr)
+}
+
+// Close implements Snapshot.
+func (s *prefixDBSnapshot) Close() error {
+	return s.source.Close()
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cast"
+)
+
+// OptReadOnly is the Options key which, set to true, opens an existing database read-only. The
+// backends open their files read-only where they support it, and NewDBwithOptions returns a
+// handle whose writes all fail with ErrReadOnly. The backend constructors, such as NewPebbleDB,
+// open their files read-only too, but return the backend's own errors on writes.
+const OptReadOnly = "read_only"
+
+// ErrReadOnly is returned by writes to a database opened read-only through NewDBwithOptions or
+// NewReadOnlyDB. It is not guaranteed for a database opened read-only by a backend constructor.
+var ErrReadOnly = errors.New("database is read-only")
+
+// isReadOnly reports whether opts ask for a read-only database.
+func isReadOnly(opts Options) (bool, error) {
+	if opts == nil {
+		return false, nil
+	}
+	raw := opts.Get(OptReadOnly)
+	if raw == nil {
+		return false, nil
+	}
+	readOnly, err := cast.ToBoolE(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s=%v: %w", OptReadOnly, raw, err)
+	}
+	return readOnly, nil
+}
+
+// readOnlyDB wraps a database opened read-only, failing all writes with ErrReadOnly so that
+// every backend reports them the same way.
+type readOnlyDB struct {
+	db DB
+}
+
+var (
+	_ DB        = (*readOnlyDB)(nil)
+	_ Compactor = (*readOnlyDB)(nil)
+)
+
+// Get implements DB.
+func (rdb *readOnlyDB) Get(key []byte) ([]byte, error) {
+	return rdb.db.Get(key)
+}
+
+// GetMany implements DB.
+func (rdb *readOnlyDB) GetMany(keys [][]byte) ([][]byte, error) {
+	return rdb.db.GetMany(keys)
+}
+
+// View implements DB.
+func (rdb *readOnlyDB) View(key []byte, fn func([]byte) error) error {
+	return rdb.db.View(key, fn)
+}
+
+// Has implements DB.
+func (rdb *readOnlyDB) Has(key []byte) (bool, error) {
+	return rdb.db.Has(key)
+}
+
+// Set implements DB.
+func (rdb *readOnlyDB) Set([]byte, []byte) error {
+	return ErrReadOnly
+}
+
+// SetSync implements DB.
+func (rdb *readOnlyDB) SetSync([]byte, []byte) error {
+	return ErrReadOnly
+}
+
+// Delete implements DB.
+func (rdb *readOnlyDB) Delete([]byte) error {
+	return ErrReadOnly
+}
+
+// DeleteSync implements DB.
+func (rdb *readOnlyDB) DeleteSync([]byte) error {
+	return ErrReadOnly
+}
+
+// DeleteRange implements DB.
+func (rdb *readOnlyDB) DeleteRange(_, _ []byte) error {
+	return ErrReadOnly
+}
+
+// EstimateSize implements DB.
+func (rdb *readOnlyDB) EstimateSize(start, end []byte) (uint64, error) {
+	return rdb.db.EstimateSize(start, end)
+}
+
+// EstimateKeys implements DB.
+func (rdb *readOnlyDB) EstimateKeys(start, end []byte) (uint64, error) {
+	return rdb.db.EstimateKeys(start, end)
+}
+
+// Compact implements Compactor.
+func (rdb *readOnlyDB) Compact(_, _ []byte) error {
+	return ErrReadOnly
+}
+
+// Iterator implements DB.
+func (rdb *readOnlyDB) Iterator(start, end []byte) (Iterator, error) {
+	return rdb.db.Iterator(start, end)
+}
+
+// ReverseIterator implements DB.
+func (rdb *readOnlyDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	return rdb.db.ReverseIterator(start, end)
+}
+
+// Close implements DB.
+func (rdb *readOnlyDB) Close() error {
+	return rdb.db.Close()
+}
+
+// NewBatch implements DB.
+// The batch fails all writes with ErrReadOnly.
+func (rdb *readOnlyDB) NewBatch() Batch {
+	return readOnlyBatch{}
+}
+
+// NewBatchWithSize implements DB.
+// The batch fails all writes with ErrReadOnly.
+func (rdb *readOnlyDB) NewBatchWithSize(int) Batch {
+	return readOnlyBatch{}
+}
+
+// NewIndexedBatch implements DB.
+// The batch can read the database, but fails all writes with ErrReadOnly.
+func (rdb *readOnlyDB) NewIndexedBatch() IndexedBatch {
+	return newIndexedBatch(rdb, readOnlyBatch{})
+}
+
+// NewSnapshot implements DB.
+func (rdb *readOnlyDB) NewSnapshot() (Snapshot, error) {
+	return rdb.db.NewSnapshot()
+}
+
+// Backup implements DB.
+func (rdb *readOnlyDB) Backup(destDir string) error {
+	return rdb.db.Backup(destDir)
+}
+
+// Print implements DB.
+func (rdb *readOnlyDB) Print() error {
+	return rdb.db.Print()
+}
+
+// Stats implements DB.
+func (rdb *readOnlyDB) Stats() map[string]string {
+	return rdb.db.Stats()
+}
+
+// TypedStats implements DB.
+func (rdb *readOnlyDB) TypedStats() DBStats {
+	return rdb.db.TypedStats()
+}
+
+// backendType implements backendTyper.
+func (rdb *readOnlyDB) backendType() BackendType {
+	return backendOf(rdb.db)
+}
+
+// nativeMetrics implements nativeMetricer.
+func (rdb *readOnlyDB) nativeMetrics() []nativeMetric {
+	if source, ok := rdb.db.(nativeMetricer); ok {
+		return source.nativeMetrics()
+	}
+	return nil
+}
+
+// readOnlyBatch is the batch of a read-only database. Every operation but Close fails with
+// ErrReadOnly.
+type readOnlyBatch struct{}
+
+var _ Batch = readOnlyBatch{}
+
+// Set implements Batch.
+func (readOnlyBatch) Set(_, _ []byte) error {
+	return ErrReadOnly
+}
+
+// Delete implements Batch.
+func (readOnlyBatch) Delete([]byte) error {
+	return ErrReadOnly
+}
+
+// DeleteRange implements Batch.
+func (readOnlyBatch) DeleteRange(_, _ []byte) error {
+	return ErrReadOnly
+}
+
+// Write implements Batch.
+func (readOnlyBatch) Write() error {
+	return ErrReadOnly
+}
+
+// WriteSync implements Batch.
+func (readOnlyBatch) WriteSync() error {
+	return ErrReadOnly
+}
+
+// Close implements Batch.
+func (readOnlyBatch) Close() error {
+	return nil
+}
+
+// GetByteSize implements Batch.
+func (readOnlyBatch) GetByteSize() (int, error) {
+	return 0, ErrReadOnly
+}
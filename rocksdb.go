@@ -16,7 +16,7 @@ func init() {
 	dbCreator := func(name, dir string, opts Options) (DB, error) {
 		return NewRocksDB(name, dir, opts)
 	}
-	registerDBCreator(RocksDBBackend, dbCreator, false)
+	registerDBCreator(RocksDBBackend, dbCreator, Capabilities{Persistent: true, Snapshots: true, DeleteRange: true, RequiresCgo: true}, false)
 }
 
 // RocksDB is a RocksDB backend.
@@ -27,7 +27,10 @@ type RocksDB struct {
 	woSync *grocksdb.WriteOptions
 }
 
-var _ DB = (*RocksDB)(nil)
+var (
+	_ DB        = (*RocksDB)(nil)
+	_ Compactor = (*RocksDB)(nil)
+)
 
 // defaultRocksdbOptions, good enough for most cases, including heavy workloads.
 // 1GB table cache, 512MB write buffer (may use 50% more on heavy workloads).
@@ -48,6 +51,8 @@ func defaultRocksdbOptions() *grocksdb.Options {
 	return rocksdbOpts
 }
 
+// NewRocksDB opens the RocksDB name in dir. With OptReadOnly set, writes fail with the errors
+// of RocksDB rather than ErrReadOnly; open it through NewReadOnlyDB for those.
 func NewRocksDB(name, dir string, opts Options) (*RocksDB, error) {
 	defaultOpts := defaultRocksdbOptions()
 
@@ -56,11 +61,32 @@ func NewRocksDB(name, dir string, opts Options) (*RocksDB, error) {
 		if files > 0 {
 			defaultOpts.SetMaxOpenFiles(files)
 		}
+		readOnly, err := isReadOnly(opts)
+		if err != nil {
+			return nil, err
+		}
+		if readOnly {
+			return NewReadOnlyRocksDBWithOptions(name, dir, defaultOpts)
+		}
 	}
 
 	return NewRocksDBWithOptions(name, dir, defaultOpts)
 }
 
+// NewReadOnlyRocksDBWithOptions opens an existing RocksDB read-only.
+func NewReadOnlyRocksDBWithOptions(name, dir string, opts *grocksdb.Options) (*RocksDB, error) {
+	dbPath := filepath.Join(dir, name+DBFileSuffix)
+	db, err := grocksdb.OpenDbForReadOnly(opts, dbPath, false)
+	if err != nil {
+		return nil, err
+	}
+	ro := grocksdb.NewDefaultReadOptions()
+	wo := grocksdb.NewDefaultWriteOptions()
+	woSync := grocksdb.NewDefaultWriteOptions()
+	woSync.SetSync(true)
+	return NewRocksDBWithRawDB(db, ro, wo, woSync), nil
+}
+
 func NewRocksDBWithOptions(name, dir string, opts *grocksdb.Options) (*RocksDB, error) {
 	dbPath := filepath.Join(dir, name+DBFileSuffix)
 	db, err := grocksdb.OpenDb(opts, dbPath)
@@ -112,6 +138,48 @@ func (db *RocksDB) Get(key []byte) ([]byte, error) {
 	return moveSliceToBytes(res), nil
 }
 
+// GetMany implements DB.
+// It uses the MultiGet of RocksDB, which reads all keys in one call.
+func (db *RocksDB) GetMany(keys [][]byte) ([][]byte, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return [][]byte{}, nil
+	}
+	res, err := db.db.MultiGet(db.ro, keys...)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(res))
+	for i, s := range res {
+		values[i] = moveSliceToBytes(s)
+	}
+	return values, nil
+}
+
+// View implements DB.
+// fn is given the value buffer of RocksDB, which is freed when it returns.
+func (db *RocksDB) View(key []byte, fn func([]byte) error) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	res, err := db.db.Get(db.ro, key)
+	if err != nil {
+		return err
+	}
+	defer res.Free()
+
+	if !res.Exists() {
+		return fn(nil)
+	}
+	value := res.Data()
+	if value == nil {
+		value = []byte{}
+	}
+	return fn(value)
+}
+
 // Has implements DB.
 func (db *RocksDB) Has(key []byte) (bool, error) {
 	bytes, err := db.Get(key)
@@ -159,10 +227,72 @@ func (db *RocksDB) DeleteSync(key []byte) error {
 	return db.db.Delete(db.woSync, key)
 }
 
+// DeleteRange implements DB.
+func (db *RocksDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	start, end, ok, err := resolveDeleteRange(db, start, end, nil)
+	if err != nil || !ok {
+		return err
+	}
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
+	batch.DeleteRange(start, end)
+	return db.db.Write(db.wo, batch)
+}
+
 func (db *RocksDB) DB() *grocksdb.DB {
 	return db.db
 }
 
+// EstimateSize implements DB.
+// It uses the GetApproximateSizes of RocksDB, which only covers keys flushed to sstables.
+func (db *RocksDB) EstimateSize(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	return db.approximateSize(start, end)
+}
+
+// EstimateKeys implements DB.
+// RocksDB does not count keys by range, so the count is extrapolated from EstimateSize.
+func (db *RocksDB) EstimateKeys(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	return estimateKeys(db, db.approximateSize, start, end)
+}
+
+// approximateSize returns the size of [start, end) on disk. GetApproximateSizes needs a bounded
+// domain, so a nil end is replaced by one past the last key.
+func (db *RocksDB) approximateSize(start, end []byte) (uint64, error) {
+	if start == nil {
+		start = []byte{}
+	}
+	if end == nil {
+		last, err := lastKey(db)
+		if err != nil || last == nil {
+			return 0, err
+		}
+		end = append(last, 0x00)
+	}
+	sizes, err := db.db.GetApproximateSizes([]grocksdb.Range{{Start: start, Limit: end}})
+	if err != nil {
+		return 0, err
+	}
+	return sizes[0], nil
+}
+
+// Compact implements Compactor.
+func (db *RocksDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	db.db.CompactRange(grocksdb.Range{Start: start, Limit: end})
+	return nil
+}
+
 // Close implements DB.
 func (db *RocksDB) Close() error {
 	db.ro.Destroy()
@@ -172,6 +302,20 @@ func (db *RocksDB) Close() error {
 	return nil
 }
 
+// Backup implements DB.
+// It creates a RocksDB checkpoint, which hard-links the sstables where possible.
+func (db *RocksDB) Backup(destDir string) error {
+	if err := checkBackupDir(destDir); err != nil {
+		return err
+	}
+	cp, err := db.db.NewCheckpoint()
+	if err != nil {
+		return err
+	}
+	defer cp.Destroy()
+	return cp.CreateCheckpoint(destDir, 0)
+}
+
 // Print implements DB.
 func (db *RocksDB) Print() error {
 	itr, err := db.Iterator(nil, nil)
@@ -194,7 +338,48 @@ func (db *RocksDB) Stats() map[string]string {
 	for _, key := range keys {
 		stats[key] = db.db.GetProperty(key)
 	}
-	return stats
+	return db.TypedStats().flatten(stats)
+}
+
+// TypedStats implements DB.
+func (db *RocksDB) TypedStats() DBStats {
+	property := func(name string) uint64 {
+		value, _ := db.db.GetIntProperty(name)
+		return value
+	}
+	return DBStats{
+		Backend:          RocksDBBackend,
+		KeyCountEstimate: property("rocksdb.estimate-num-keys"),
+		DiskSize:         property("rocksdb.total-sst-files-size"),
+		MemtableSize:     property("rocksdb.cur-size-all-mem-tables"),
+		CompactionDebt:   property("rocksdb.estimate-pending-compaction-bytes"),
+	}
+}
+
+// backendType implements backendTyper.
+func (db *RocksDB) backendType() BackendType {
+	return RocksDBBackend
+}
+
+// nativeMetrics implements nativeMetricer.
+func (db *RocksDB) nativeMetrics() []nativeMetric {
+	properties := []struct{ property, name, help string }{
+		{"rocksdb.estimate-num-keys", "rocksdb_estimated_keys", "Estimated number of keys."},
+		{"rocksdb.cur-size-all-mem-tables", "rocksdb_memtable_size_bytes", "Size of the memtables."},
+		{"rocksdb.block-cache-usage", "rocksdb_block_cache_usage_bytes", "Memory used by the block cache."},
+		{"rocksdb.total-sst-files-size", "rocksdb_sst_files_size_bytes", "Size of all sst files."},
+		{"rocksdb.estimate-pending-compaction-bytes", "rocksdb_compaction_debt_bytes", "Estimated bytes to compact for all levels to be under target size."},
+		{"rocksdb.num-running-compactions", "rocksdb_compactions_in_progress", "Number of compactions in progress."},
+		{"rocksdb.num-running-flushes", "rocksdb_flushes_in_progress", "Number of memtable flushes in progress."},
+		{"rocksdb.num-snapshots", "rocksdb_snapshots", "Number of unreleased snapshots."},
+	}
+	metrics := make([]nativeMetric, 0, len(properties))
+	for _, p := range properties {
+		if value, ok := db.db.GetIntProperty(p.property); ok {
+			metrics = append(metrics, nativeMetric{name: p.name, help: p.help, value: float64(value)})
+		}
+	}
+	return metrics
 }
 
 // NewBatch implements DB.
@@ -208,6 +393,11 @@ func (db *RocksDB) NewBatchWithSize(_ int) Batch {
 	return newRocksDBBatch(db)
 }
 
+// NewIndexedBatch implements DB.
+func (db *RocksDB) NewIndexedBatch() IndexedBatch {
+	return newIndexedBatch(db, newRocksDBBatch(db))
+}
+
 // Iterator implements DB.
 func (db *RocksDB) Iterator(start, end []byte) (Iterator, error) {
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
@@ -3,11 +3,17 @@
 
 package db
 
-import "github.com/linxGnu/grocksdb"
+import (
+	"bytes"
+
+	"github.com/linxGnu/grocksdb"
+)
 
 type rocksDBBatch struct {
 	db    *RocksDB
 	batch *grocksdb.WriteBatch
+	// maxKey is the largest key set in the batch, covered by range deletes with a nil end.
+	maxKey []byte
 }
 
 var _ Batch = (*rocksDBBatch)(nil)
@@ -31,9 +37,17 @@ func (b *rocksDBBatch) Set(key, value []byte) error {
 		return errBatchClosed
 	}
 	b.batch.Put(key, value)
+	b.setMaxKey(key)
 	return nil
 }
 
+// setMaxKey records key as the largest key set in the batch, if it is.
+func (b *rocksDBBatch) setMaxKey(key []byte) {
+	if bytes.Compare(key, b.maxKey) > 0 {
+		b.maxKey = cp(key)
+	}
+}
+
 // Delete implements Batch.
 func (b *rocksDBBatch) Delete(key []byte) error {
 	if len(key) == 0 {
@@ -46,6 +60,24 @@ func (b *rocksDBBatch) Delete(key []byte) error {
 	return nil
 }
 
+// DeleteRange implements Batch.
+// A nil end is resolved when DeleteRange is called, against the keys in the database and the
+// keys set earlier in this batch. Keys written to the database after this call are not deleted.
+func (b *rocksDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	if b.batch == nil {
+		return errBatchClosed
+	}
+	start, end, ok, err := resolveDeleteRange(b.db, start, end, b.maxKey)
+	if err != nil || !ok {
+		return err
+	}
+	b.batch.DeleteRange(start, end)
+	return nil
+}
+
 // Write implements Batch.
 func (b *rocksDBBatch) Write() error {
 	if b.batch == nil {
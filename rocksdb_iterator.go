@@ -115,6 +115,24 @@ func (itr rocksDBIterator) Next() {
 	}
 }
 
+// Seek implements Iterator.
+func (itr *rocksDBIterator) Seek(key []byte) {
+	target, ok := seekTarget(key, itr.start, itr.end, itr.isReverse)
+	if !ok {
+		itr.isInvalid = true
+		return
+	}
+	itr.isInvalid = false
+	itr.source.Seek(target)
+	if itr.isReverse {
+		if itr.source.Valid() {
+			itr.source.Prev()
+		} else {
+			itr.source.SeekToLast()
+		}
+	}
+}
+
 // Error implements Iterator.
 func (itr *rocksDBIterator) Error() error {
 	return itr.source.Err()
@@ -0,0 +1,85 @@
+//go:build rocksdb
+// +build rocksdb
+
+package db
+
+import "github.com/linxGnu/grocksdb"
+
+type rocksDBSnapshot struct {
+	db   *RocksDB
+	snap *grocksdb.Snapshot
+	ro   *grocksdb.ReadOptions
+}
+
+var _ Snapshot = (*rocksDBSnapshot)(nil)
+
+// NewSnapshot implements DB.
+func (db *RocksDB) NewSnapshot() (Snapshot, error) {
+	snap := db.db.NewSnapshot()
+	ro := grocksdb.NewDefaultReadOptions()
+	ro.SetSnapshot(snap)
+	return &rocksDBSnapshot{
+		db:   db,
+		snap: snap,
+		ro:   ro,
+	}, nil
+}
+
+// Get implements Snapshot.
+func (s *rocksDBSnapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	res, err := s.db.db.Get(s.ro, key)
+	if err != nil {
+		return nil, err
+	}
+	return moveSliceToBytes(res), nil
+}
+
+// Has implements Snapshot.
+func (s *rocksDBSnapshot) Has(key []byte) (bool, error) {
+	bytes, err := s.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return bytes != nil, nil
+}
+
+// Iterator implements Snapshot.
+func (s *rocksDBSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	itr := s.db.db.NewIterator(s.ro)
+	return newRocksDBIterator(itr, start, end, false), nil
+}
+
+// ReverseIterator implements Snapshot.
+func (s *rocksDBSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	itr := s.db.db.NewIterator(s.ro)
+	return newRocksDBIterator(itr, start, end, true), nil
+}
+
+// Close implements Snapshot.
+func (s *rocksDBSnapshot) Close() error {
+	if s.snap != nil {
+		s.ro.Destroy()
+		s.db.db.ReleaseSnapshot(s.snap)
+		s.snap = nil
+		s.ro = nil
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// cachedStatTTL is how long a cachedStat reuses what it measured.
+const cachedStatTTL = 10 * time.Second
+
+// DBStats are the statistics every backend reports in the same form. Backends only fill in what
+// they can measure, and leave the other fields zero.
+type DBStats struct {
+	// Backend is the type of the backend which reported the stats.
+	Backend BackendType
+	// KeyCountEstimate is an estimate of the number of keys in the database.
+	KeyCountEstimate uint64
+	// DiskSize is the number of bytes the database takes up on disk.
+	DiskSize uint64
+	// MemtableSize is the number of bytes buffered in memtables, not yet written to disk.
+	MemtableSize uint64
+	// CacheHitRatio is the fraction of block cache lookups which were hits, between 0 and 1.
+	CacheHitRatio float64
+	// CompactionDebt is an estimate of the number of bytes left to compact.
+	CompactionDebt uint64
+	// OpenIterators is the number of iterators which have not been closed. goleveldb counts the
+	// iterators it gave out; pebble counts its open sstable iterators, several of which can back a
+	// single iterator, and none of which back one over the memtable only. The other backends
+	// leave it zero.
+	OpenIterators uint64
+	// WriteStalls is the number of times writes were delayed or stopped by the backend.
+	WriteStalls uint64
+}
+
+// flatten adds the stats to a Stats map, under the "stats." prefix.
+func (s DBStats) flatten(stats map[string]string) map[string]string {
+	if stats == nil {
+		stats = make(map[string]string)
+	}
+	stats["stats.backend"] = string(s.Backend)
+	stats["stats.key_count_estimate"] = strconv.FormatUint(s.KeyCountEstimate, 10)
+	stats["stats.disk_size"] = strconv.FormatUint(s.DiskSize, 10)
+	stats["stats.memtable_size"] = strconv.FormatUint(s.MemtableSize, 10)
+	stats["stats.cache_hit_ratio"] = strconv.FormatFloat(s.CacheHitRatio, 'f', -1, 64)
+	stats["stats.compaction_debt"] = strconv.FormatUint(s.CompactionDebt, 10)
+	stats["stats.open_iterators"] = strconv.FormatUint(s.OpenIterators, 10)
+	stats["stats.write_stalls"] = strconv.FormatUint(s.WriteStalls, 10)
+	return stats
+}
+
+// hitRatio returns hits / (hits + misses), or 0 if there were no lookups.
+func hitRatio(hits, misses int64) float64 {
+	if hits+misses <= 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) uint64 {
+	var size uint64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
+}
+
+// cachedStat is a stat which is too costly to measure on every call to TypedStats, such as the
+// size of a directory, which is measured again once it is older than cachedStatTTL.
+type cachedStat struct {
+	mtx      sync.Mutex
+	value    uint64
+	measured time.Time
+}
+
+// get returns the value of the stat, measuring it with measure if it is stale.
+func (c *cachedStat) get(measure func() uint64) uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.measured.IsZero() || time.Since(c.measured) >= cachedStatTTL {
+		c.value = measure()
+		c.measured = time.Now()
+	}
+	return c.value
+}
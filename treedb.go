@@ -1,14 +1,20 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 
+	"github.com/spf13/cast"
+
 	treedb "github.com/snissn/gomap/TreeDB"
 	treedbkv "github.com/snissn/gomap/TreeDB/integration/kvstoreadapter"
 	"github.com/snissn/gomap/TreeDB/tree"
+	"github.com/snissn/gomap/kvstore"
 	treedbadapter "github.com/snissn/gomap/kvstore/adapters/treedb"
 )
 
@@ -16,20 +22,27 @@ func init() {
 	dbCreator := func(name, dir string, opts Options) (DB, error) {
 		return NewTreeDB(name, dir, opts)
 	}
-	registerDBCreator(TreeDBBackend, dbCreator, false)
+	registerDBCreator(TreeDBBackend, dbCreator, Capabilities{Persistent: true, Snapshots: true, DeleteRange: true}, false)
 }
 
 // TreeDB is a TreeDB backend.
 type TreeDB struct {
-	db           *treedb.DB
-	kv           *treedbadapter.DB
-	snap         treedb.Snapshot
-	reuseReads   bool
-	readBuf      []byte
+	db         *treedb.DB
+	kv         *treedbadapter.DB
+	snap       treedb.Snapshot
+	visibility treedbVisibility
+	// options are the options TreeDB was opened with.
+	options treedb.Options
+	dir     string
+	// diskSize is the size of dir, which is walked at most every cachedStatTTL.
+	diskSize     cachedStat
 	batchWriteMu sync.Mutex
 }
 
-var _ DB = (*TreeDB)(nil)
+var (
+	_ DB        = (*TreeDB)(nil)
+	_ Compactor = (*TreeDB)(nil)
+)
 
 const envTreeDBOpenProfile = treedbkv.EnvOpenProfile
 const envTreeDBKeepRecent = treedbkv.EnvKeepRecent
@@ -61,16 +74,35 @@ func (d *TreeDB) withSerializedBatchWrite(fn func() error) error {
 	return fn()
 }
 
-func NewTreeDB(name, dir string, opts Options) (*TreeDB, error) {
-	_ = opts
-	return NewTreeDBAdapter(dir, name)
-}
+// Options read by NewTreeDB. Each falls back to its environment variable when unset, so that a
+// process can still tune every TreeDB instance at once. There is no option to reuse read
+// buffers: GetAppend reads into a buffer of the caller, and View reads without copying.
+const (
+	// TreeDBOptProfile is the open profile (TREEDB_OPEN_PROFILE). The adapter only supports
+	// command_wal_durable.
+	TreeDBOptProfile = "treedb.profile"
+	// TreeDBOptKeepRecent is the number of recent versions to keep (TREEDB_KEEP_RECENT).
+	TreeDBOptKeepRecent = "treedb.keep_recent"
+	// TreeDBOptMemtableMode is the memtable mode (TREEDB_MEMTABLE_MODE).
+	TreeDBOptMemtableMode = "treedb.memtable_mode"
+	// TreeDBOptDebugVisibility enables the visibility debug log on stderr
+	// (TREEDB_COSMOS_DEBUG_VISIBILITY).
+	TreeDBOptDebugVisibility = "treedb.debug_visibility"
+	// TreeDBOptDebugPrefix limits the visibility debug log to keys with a prefix
+	// (TREEDB_COSMOS_DEBUG_PREFIX).
+	TreeDBOptDebugPrefix = "treedb.debug_prefix"
+)
 
-func NewTreeDBAdapter(dir string, name string) (*TreeDB, error) {
-	if err := validateTreeDBAdapterProfile(); err != nil {
-		return nil, err
-	}
-	opened, err := treedbkv.Open(treedbkv.OpenConfig{
+// treeDBOptionSetEnv is the environment variable given to treedbkv.ResolveOptions in place of
+// the one of a setting which Options sets. It is never set, so that the option takes precedence.
+const treeDBOptionSetEnv = "COSMOS_DB_TREEDB_OPTION_SET"
+
+// NewTreeDB opens the TreeDB name in dir. Its settings are resolved by treedbkv.ResolveOptions,
+// from the environment and the adapter defaults, with the settings given in opts taking
+// precedence. With OptReadOnly set, writes fail with the errors of TreeDB rather than
+// ErrReadOnly; open it through NewReadOnlyDB for those.
+func NewTreeDB(name, dir string, opts Options) (*TreeDB, error) {
+	cfg := treedbkv.OpenConfig{
 		ParentDir:                   dir,
 		Name:                        name,
 		DBFileSuffix:                DBFileSuffix,
@@ -81,30 +113,132 @@ func NewTreeDBAdapter(dir string, name string) (*TreeDB, error) {
 		ProfileEnvKey:               envTreeDBOpenProfile,
 		KeepRecentEnvKey:            envTreeDBKeepRecent,
 		MemtableModeEnvKey:          envTreeDBMemtableMode,
-	})
+	}
+
+	rawProfile, source, err := treeDBOption(opts, TreeDBOptProfile, envTreeDBOpenProfile)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateTreeDBAdapterProfile(source, rawProfile); err != nil {
+		return nil, err
+	}
+	if source == TreeDBOptProfile {
+		// The only profile supported, command_wal_durable, is the default.
+		cfg.ProfileEnvKey = treeDBOptionSetEnv
+	}
+
+	var keepRecent *uint64
+	raw, source, err := treeDBOption(opts, TreeDBOptKeepRecent, "")
+	if err != nil {
+		return nil, err
+	}
+	if raw != "" {
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s=%q: %w", source, raw, err)
+		}
+		keepRecent = &n
+		cfg.KeepRecentEnvKey = treeDBOptionSetEnv
+	}
+
+	if raw, _, err = treeDBOption(opts, TreeDBOptMemtableMode, ""); err != nil {
+		return nil, err
+	}
+	if raw != "" {
+		cfg.DefaultMemtableMode = raw
+		cfg.MemtableModeEnvKey = treeDBOptionSetEnv
+	}
+
+	visibility := treedbVisibility{}
+	raw, _, err = treeDBOption(opts, TreeDBOptDebugVisibility, envTreeDBCosmosDebugVisibility)
+	if err != nil {
+		return nil, err
+	}
+	if raw != "" && raw != "0" && !strings.EqualFold(raw, "false") {
+		visibility.enabled = true
+		if visibility.prefix, _, err = treeDBOption(opts, TreeDBOptDebugPrefix, envTreeDBCosmosDebugPrefix); err != nil {
+			return nil, err
+		}
+	}
+
+	o, _, err := treedbkv.ResolveOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if keepRecent != nil {
+		// Set it here, as ResolveOptions takes a zero default as unset.
+		o.KeepRecent = *keepRecent
+	}
+	readOnly, err := isReadOnly(opts)
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		// A read-only TreeDB reads the backend files directly, so it sees the state as of the last
+		// checkpoint of the writer.
+		o.ReadOnly = true
+	}
 
-	adapter := &TreeDB{
-		db:         opened.DB,
-		kv:         opened.KV,
-		reuseReads: false,
+	adapter, err := openTreeDB(o)
+	if err != nil {
+		return nil, err
 	}
+	adapter.visibility = visibility
 	return adapter, nil
 }
 
-func validateTreeDBAdapterProfile() error {
-	rawProfile := os.Getenv(envTreeDBOpenProfile)
+// openTreeDB opens the TreeDB configured by o, creating its directory unless it is read-only.
+func openTreeDB(o treedb.Options) (*TreeDB, error) {
+	if !o.ReadOnly {
+		if err := os.MkdirAll(o.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("error creating treedb directory: %w", err)
+		}
+	}
+	tdb, err := treedb.Open(o)
+	if err != nil {
+		return nil, err
+	}
+	return &TreeDB{
+		db:      tdb,
+		kv:      treedbadapter.WrapNamed(tdb, "TreeDB"),
+		options: o,
+		dir:     o.Dir,
+	}, nil
+}
+
+// NewTreeDBAdapter opens a TreeDB configured through the environment only.
+func NewTreeDBAdapter(dir string, name string) (*TreeDB, error) {
+	return NewTreeDB(name, dir, nil)
+}
+
+// treeDBOption returns the trimmed value of an option, falling back to the environment variable
+// envKey, along with the name of where it came from for error messages.
+func treeDBOption(opts Options, key, envKey string) (value, source string, err error) {
+	if opts != nil {
+		if v := opts.Get(key); v != nil {
+			value, err := cast.ToStringE(v)
+			if err != nil {
+				return "", key, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			return strings.TrimSpace(value), key, nil
+		}
+	}
+	if envKey == "" {
+		return "", key, nil
+	}
+	return strings.TrimSpace(os.Getenv(envKey)), envKey, nil
+}
+
+func validateTreeDBAdapterProfile(source, rawProfile string) error {
 	if rawProfile == "" {
 		return nil
 	}
 	profile, err := treedbkv.ParsePublicProfile(rawProfile, treedb.ProfileCommandWALDurable)
 	if err != nil {
-		return fmt.Errorf("invalid %s=%q: %w", envTreeDBOpenProfile, rawProfile, err)
+		return fmt.Errorf("invalid %s=%q: %w", source, rawProfile, err)
 	}
 	if profile != treedb.ProfileCommandWALDurable {
-		return fmt.Errorf("invalid %s=%q: TreeDB adapter supports only %q", envTreeDBOpenProfile, rawProfile, treedb.ProfileCommandWALDurable)
+		return fmt.Errorf("invalid %s=%q: TreeDB adapter supports only %q", source, rawProfile, treedb.ProfileCommandWALDurable)
 	}
 	return nil
 }
@@ -116,14 +250,14 @@ func (d *TreeDB) Get(key []byte) ([]byte, error) {
 	}
 	if d.snap != nil {
 		val, err := d.snap.GetUnsafe(key)
-		if treedbVisibilityTrackKey(key) {
-			treedbVisibilityf("get source=snapshot key=%x val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
+		if d.visibility.trackKey(key) {
+			d.visibility.logf("get source=snapshot key=%x val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
 		}
-		if version, prefix, ok := prefixedIAVLRootVersion(key); ok {
-			treedbVisibilityf("get source=snapshot prefix=%q key=%x version=%d val_nil=%t val_len=%d err=%v", prefix, key, version, val == nil, len(val), err)
+		if version, prefix, ok := d.visibility.trackRootVersion(key); ok {
+			d.visibility.logf("get source=snapshot prefix=%q key=%x version=%d val_nil=%t val_len=%d err=%v", prefix, key, version, val == nil, len(val), err)
 		}
 		if isRootMultiMetaKey(key) {
-			treedbVisibilityf("get-meta source=snapshot key=%q val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
+			d.visibility.logf("get-meta source=snapshot key=%q val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
 		}
 		if err != nil {
 			if errors.Is(err, tree.ErrKeyNotFound) {
@@ -136,35 +270,15 @@ func (d *TreeDB) Get(key []byte) ([]byte, error) {
 	if d.db == nil {
 		return nil, treedb.ErrClosed
 	}
-	if d.reuseReads {
-		val, err := d.db.GetAppend(key, d.readBuf[:0])
-		if treedbVisibilityTrackKey(key) {
-			treedbVisibilityf("get source=getappend key=%x val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
-		}
-		if version, prefix, ok := prefixedIAVLRootVersion(key); ok {
-			treedbVisibilityf("get source=getappend prefix=%q key=%x version=%d val_nil=%t val_len=%d err=%v", prefix, key, version, val == nil, len(val), err)
-		}
-		if isRootMultiMetaKey(key) {
-			treedbVisibilityf("get-meta source=getappend key=%q val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
-		}
-		if err != nil {
-			if errors.Is(err, tree.ErrKeyNotFound) {
-				return nil, nil
-			}
-			return nil, err
-		}
-		d.readBuf = val[:0]
-		return val, nil
-	}
 	val, err := d.kv.GetUnsafe(key)
-	if treedbVisibilityTrackKey(key) {
-		treedbVisibilityf("get source=kv key=%x val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
+	if d.visibility.trackKey(key) {
+		d.visibility.logf("get source=kv key=%x val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
 	}
-	if version, prefix, ok := prefixedIAVLRootVersion(key); ok {
-		treedbVisibilityf("get source=kv prefix=%q key=%x version=%d val_nil=%t val_len=%d err=%v", prefix, key, version, val == nil, len(val), err)
+	if version, prefix, ok := d.visibility.trackRootVersion(key); ok {
+		d.visibility.logf("get source=kv prefix=%q key=%x version=%d val_nil=%t val_len=%d err=%v", prefix, key, version, val == nil, len(val), err)
 	}
 	if isRootMultiMetaKey(key) {
-		treedbVisibilityf("get-meta source=kv key=%q val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
+		d.visibility.logf("get-meta source=kv key=%q val_nil=%t val_len=%d err=%v", key, val == nil, len(val), err)
 	}
 	return val, err
 }
@@ -198,6 +312,52 @@ func (d *TreeDB) GetAppend(key, dst []byte) ([]byte, error) {
 	return val, nil
 }
 
+// GetMany implements DB.
+// It uses the batched lookup of TreeDB, which reads all keys from one snapshot, unless a
+// snapshot is pinned, in which case the keys are read from it one at a time.
+func (d *TreeDB) GetMany(keys [][]byte) ([][]byte, error) {
+	if d.snap != nil {
+		return getMany(d.Get, keys)
+	}
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	if d.db == nil {
+		return nil, treedb.ErrClosed
+	}
+	return d.kv.GetMany(keys)
+}
+
+// View implements DB.
+// fn is given a view of the value in a TreeDB snapshot, which is released when it returns.
+func (d *TreeDB) View(key []byte, fn func([]byte) error) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	if d.snap != nil {
+		value, err := d.snap.GetUnsafe(key)
+		if err != nil {
+			if errors.Is(err, tree.ErrKeyNotFound) {
+				return fn(nil)
+			}
+			return err
+		}
+		return fn(value)
+	}
+	if d.db == nil {
+		return treedb.ErrClosed
+	}
+	return d.db.GetManyView([][]byte{key}, func(_ int, _, value []byte, found bool) error {
+		switch {
+		case !found:
+			value = nil
+		case value == nil:
+			value = []byte{}
+		}
+		return fn(value)
+	})
+}
+
 // Has implements DB.
 func (d *TreeDB) Has(key []byte) (bool, error) {
 	if len(key) == 0 {
@@ -205,14 +365,14 @@ func (d *TreeDB) Has(key []byte) (bool, error) {
 	}
 	if d.snap != nil {
 		ok, err := d.snap.Has(key)
-		if treedbVisibilityTrackKey(key) {
-			treedbVisibilityf("has source=snapshot key=%x ok=%t err=%v", key, ok, err)
+		if d.visibility.trackKey(key) {
+			d.visibility.logf("has source=snapshot key=%x ok=%t err=%v", key, ok, err)
 		}
-		if version, prefix, match := prefixedIAVLRootVersion(key); match {
-			treedbVisibilityf("has source=snapshot prefix=%q key=%x version=%d ok=%t err=%v", prefix, key, version, ok, err)
+		if version, prefix, match := d.visibility.trackRootVersion(key); match {
+			d.visibility.logf("has source=snapshot prefix=%q key=%x version=%d ok=%t err=%v", prefix, key, version, ok, err)
 		}
 		if isRootMultiMetaKey(key) {
-			treedbVisibilityf("has-meta source=snapshot key=%q ok=%t err=%v", key, ok, err)
+			d.visibility.logf("has-meta source=snapshot key=%q ok=%t err=%v", key, ok, err)
 		}
 		return ok, err
 	}
@@ -220,14 +380,14 @@ func (d *TreeDB) Has(key []byte) (bool, error) {
 		return false, treedb.ErrClosed
 	}
 	ok, err := d.kv.Has(key)
-	if treedbVisibilityTrackKey(key) {
-		treedbVisibilityf("has source=kv key=%x ok=%t err=%v", key, ok, err)
+	if d.visibility.trackKey(key) {
+		d.visibility.logf("has source=kv key=%x ok=%t err=%v", key, ok, err)
 	}
-	if version, prefix, match := prefixedIAVLRootVersion(key); match {
-		treedbVisibilityf("has source=kv prefix=%q key=%x version=%d ok=%t err=%v", prefix, key, version, ok, err)
+	if version, prefix, match := d.visibility.trackRootVersion(key); match {
+		d.visibility.logf("has source=kv prefix=%q key=%x version=%d ok=%t err=%v", prefix, key, version, ok, err)
 	}
 	if isRootMultiMetaKey(key) {
-		treedbVisibilityf("has-meta source=kv key=%q ok=%t err=%v", key, ok, err)
+		d.visibility.logf("has-meta source=kv key=%q ok=%t err=%v", key, ok, err)
 	}
 	return ok, err
 }
@@ -246,8 +406,8 @@ func (d *TreeDB) Set(key, value []byte) error {
 	if err := d.kv.Set(key, value); err != nil {
 		return err
 	}
-	if version, prefix, ok := prefixedIAVLRootVersion(key); ok {
-		treedbVisibilityf("set prefix=%q key=%x version=%d val_len=%d", prefix, key, version, len(value))
+	if version, prefix, ok := d.visibility.trackRootVersion(key); ok {
+		d.visibility.logf("set prefix=%q key=%x version=%d val_len=%d", prefix, key, version, len(value))
 	}
 	return nil
 }
@@ -266,8 +426,8 @@ func (d *TreeDB) SetSync(key, value []byte) error {
 	if err := d.kv.SetSync(key, value); err != nil {
 		return err
 	}
-	if version, prefix, ok := prefixedIAVLRootVersion(key); ok {
-		treedbVisibilityf("setsync prefix=%q key=%x version=%d val_len=%d", prefix, key, version, len(value))
+	if version, prefix, ok := d.visibility.trackRootVersion(key); ok {
+		d.visibility.logf("setsync prefix=%q key=%x version=%d val_len=%d", prefix, key, version, len(value))
 	}
 	return nil
 }
@@ -283,8 +443,8 @@ func (d *TreeDB) Delete(key []byte) error {
 	if err := d.kv.Delete(key); err != nil {
 		return err
 	}
-	if version, prefix, ok := prefixedIAVLRootVersion(key); ok {
-		treedbVisibilityf("delete prefix=%q key=%x version=%d", prefix, key, version)
+	if version, prefix, ok := d.visibility.trackRootVersion(key); ok {
+		d.visibility.logf("delete prefix=%q key=%x version=%d", prefix, key, version)
 	}
 	return nil
 }
@@ -300,12 +460,23 @@ func (d *TreeDB) DeleteSync(key []byte) error {
 	if err := d.kv.DeleteSync(key); err != nil {
 		return err
 	}
-	if version, prefix, ok := prefixedIAVLRootVersion(key); ok {
-		treedbVisibilityf("deletesync prefix=%q key=%x version=%d", prefix, key, version)
+	if version, prefix, ok := d.visibility.trackRootVersion(key); ok {
+		d.visibility.logf("deletesync prefix=%q key=%x version=%d", prefix, key, version)
 	}
 	return nil
 }
 
+// DeleteRange implements DB.
+func (d *TreeDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	if d.db == nil {
+		return treedb.ErrClosed
+	}
+	return d.db.DeleteRange(start, end)
+}
+
 // Iterator implements DB.
 func (d *TreeDB) Iterator(start, end []byte) (Iterator, error) {
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
@@ -318,7 +489,7 @@ func (d *TreeDB) Iterator(start, end []byte) (Iterator, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &coreIterator{iter: it, start: start, end: end}, nil
+	return &coreIterator{iter: it, start: start, end: end, reopen: d.reopenForwardIterator}, nil
 }
 
 // ReverseIterator implements DB.
@@ -333,7 +504,21 @@ func (d *TreeDB) ReverseIterator(start, end []byte) (Iterator, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &coreIterator{iter: it, start: start, end: end}, nil
+	return &coreIterator{iter: it, start: start, end: end, isReverse: true, reopen: d.reopenReverseIterator}, nil
+}
+
+func (d *TreeDB) reopenForwardIterator(start, end []byte) (kvstore.Iterator, error) {
+	if d.kv == nil {
+		return nil, treedb.ErrClosed
+	}
+	return d.forwardIteratorWithIAVLFallback(start, end)
+}
+
+func (d *TreeDB) reopenReverseIterator(start, end []byte) (kvstore.Iterator, error) {
+	if d.kv == nil {
+		return nil, treedb.ErrClosed
+	}
+	return d.kv.ReverseIterator(start, end)
 }
 
 // Close implements DB.
@@ -370,6 +555,11 @@ func (d *TreeDB) NewBatchWithSize(size int) Batch {
 	return b
 }
 
+// NewIndexedBatch implements DB.
+func (d *TreeDB) NewIndexedBatch() IndexedBatch {
+	return newIndexedBatch(d, d.NewBatch())
+}
+
 // Print implements DB.
 func (d *TreeDB) Print() error {
 	itr, err := d.Iterator(nil, nil)
@@ -394,12 +584,150 @@ func (d *TreeDB) Checkpoint() error {
 	return d.kv.Checkpoint()
 }
 
+// Backup implements DB.
+// The files of an open TreeDB are rewritten by background maintenance, so they cannot be copied
+// safely. Instead, after a Checkpoint, a snapshot is copied into a new TreeDB at destDir, which is
+// checkpointed in turn before it is closed.
+func (d *TreeDB) Backup(destDir string) error {
+	if err := checkBackupDir(destDir); err != nil {
+		return err
+	}
+	if err := d.Checkpoint(); err != nil {
+		return err
+	}
+	snap, err := d.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	o := d.options
+	o.Dir = destDir
+	o.ReadOnly = false
+	dst, err := openTreeDB(o)
+	if err != nil {
+		return err
+	}
+	if err := copySnapshot(snap, dst); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Checkpoint(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// treeDBCompactSpanRatioPPM is the span ratio of the user index pages, in parts per million, at
+// which Compact rebuilds the index. It matches the threshold of the TreeDB background vacuum.
+const treeDBCompactSpanRatioPPM = 1_200_000
+
+// EstimateSize implements DB.
+// TreeDB keeps statistics of its tree as a whole, but neither of key ranges nor of its live keys,
+// and its size on disk, which includes preallocated space, is far from the size of its data. So the
+// bytes of the keys and values in the domain are estimated from a bounded sample of its keys, with
+// sampleRange.
+func (d *TreeDB) EstimateSize(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	_, size, err := sampleRange(d, start, end)
+	return size, err
+}
+
+// EstimateKeys implements DB.
+// TreeDB does not count its live keys, so the keys in the domain are estimated from a bounded
+// sample of them, with sampleRange.
+func (d *TreeDB) EstimateKeys(start, end []byte) (uint64, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return 0, errKeyEmpty
+	}
+	keys, _, err := sampleRange(d, start, end)
+	return keys, err
+}
+
+// Compact implements Compactor.
+// TreeDB rebuilds its whole index rather than a range, and only does so when its
+// FragmentationReport shows the index pages spread over more than treeDBCompactSpanRatioPPM
+// of the space they need.
+func (d *TreeDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	if err := d.Checkpoint(); err != nil {
+		return err
+	}
+	report, err := d.db.FragmentationReport()
+	if err != nil {
+		return err
+	}
+	pages, _ := strconv.ParseUint(report["treedb.user.pages"], 10, 64)
+	spanRatio, _ := strconv.ParseUint(report["treedb.user.pages.span_ratio_ppm"], 10, 64)
+	if pages == 0 || spanRatio < treeDBCompactSpanRatioPPM {
+		return nil
+	}
+	return d.db.VacuumIndexOnline(context.Background())
+}
+
 // Stats implements DB.
 func (d *TreeDB) Stats() map[string]string {
 	if d.kv == nil {
 		return nil
 	}
-	return d.kv.Stats()
+	stats := d.kv.Stats()
+	return d.typedStats(stats).flatten(stats)
+}
+
+// TypedStats implements DB.
+// TreeDB does not count its keys, so KeyCountEstimate is left zero rather than paid for with a
+// scan of the whole database. The disk size is measured by walking its directory, at most every
+// cachedStatTTL.
+func (d *TreeDB) TypedStats() DBStats {
+	if d.kv == nil {
+		return DBStats{Backend: TreeDBBackend}
+	}
+	return d.typedStats(d.kv.Stats())
+}
+
+// backendType implements backendTyper.
+func (d *TreeDB) backendType() BackendType {
+	return TreeDBBackend
+}
+
+func (d *TreeDB) typedStats(stats map[string]string) DBStats {
+	typed := DBStats{
+		Backend:  TreeDBBackend,
+		DiskSize: d.diskSize.get(func() uint64 { return dirSize(d.dir) }),
+	}
+	for _, key := range []string{
+		"treedb.cache.memtable_residency.mutable.total.size_bytes",
+		"treedb.cache.memtable_residency.queue.total.size_bytes",
+	} {
+		if size, err := strconv.ParseUint(stats[key], 10, 64); err == nil {
+			typed.MemtableSize += size
+		}
+	}
+	return typed
+}
+
+// nativeMetrics implements nativeMetricer.
+// TreeDB reports a large and changing set of stats, so the numeric ones are exported as a single
+// gauge labelled by stat name.
+func (d *TreeDB) nativeMetrics() []nativeMetric {
+	stats := d.Stats()
+	metrics := make([]nativeMetric, 0, len(stats))
+	for name, raw := range stats {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		metrics = append(metrics, nativeMetric{
+			name: "treedb_stat", help: "Numeric TreeDB stats, by name.",
+			labels: map[string]string{"name": name}, value: value,
+		})
+	}
+	return metrics
 }
 
 // FragmentationReport reports tree fragmentation metrics.
@@ -1,6 +1,10 @@
 package db
 
-import "github.com/snissn/gomap/kvstore"
+import (
+	"fmt"
+
+	"github.com/snissn/gomap/kvstore"
+)
 
 type coreBatch struct {
 	db   *TreeDB
@@ -101,6 +105,25 @@ func (b *coreBatch) DeleteView(key []byte) error {
 	return nil
 }
 
+// DeleteRange implements Batch.
+func (b *coreBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return errKeyEmpty
+	}
+	if b.done || b.kb == nil {
+		return errBatchClosed
+	}
+	rd, ok := b.kb.(kvstore.BatchRangeDeleter)
+	if !ok {
+		return fmt.Errorf("treedb batch %T: DeleteRange: %w", b.kb, kvstore.ErrUnsupported)
+	}
+	if err := rd.DeleteRange(start, end); err != nil {
+		return err
+	}
+	b.size += len(start) + len(end)
+	return nil
+}
+
 // Write implements Batch.
 func (b *coreBatch) Write() error {
 	if b.done || b.kb == nil {
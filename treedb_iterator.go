@@ -27,9 +27,13 @@ func (a *keyArena) Copy(key []byte) ([]byte, bool) {
 }
 
 type coreIterator struct {
-	iter  kvstore.Iterator
-	start []byte
-	end   []byte
+	iter      kvstore.Iterator
+	start     []byte
+	end       []byte
+	isReverse bool
+	// reopen opens a new source iterator over a subdomain, used by Seek.
+	reopen func(start, end []byte) (kvstore.Iterator, error)
+	err    error
 
 	keyArena keyArena
 	valArena keyArena
@@ -41,7 +45,7 @@ var _ Iterator = (*coreIterator)(nil)
 func (it *coreIterator) Domain() (start, end []byte) { return it.start, it.end }
 
 // Valid implements Iterator.
-func (it *coreIterator) Valid() bool { return it.iter.Valid() }
+func (it *coreIterator) Valid() bool { return it.iter != nil && it.iter.Valid() }
 
 // Next implements Iterator.
 func (it *coreIterator) Next() {
@@ -49,6 +53,36 @@ func (it *coreIterator) Next() {
 	it.iter.Next()
 }
 
+// Seek implements Iterator.
+// TreeDB iterators cannot be repositioned, so the source iterator is reopened over the rest of
+// the domain, starting from the seek key.
+func (it *coreIterator) Seek(key []byte) {
+	target, ok := seekTarget(key, it.start, it.end, it.isReverse)
+	if it.iter != nil {
+		if err := it.iter.Close(); err != nil && it.err == nil {
+			it.err = err
+		}
+		it.iter = nil
+	}
+	if !ok || it.err != nil {
+		return
+	}
+	var (
+		iter kvstore.Iterator
+		err  error
+	)
+	if it.isReverse {
+		iter, err = it.reopen(it.start, target)
+	} else {
+		iter, err = it.reopen(target, it.end)
+	}
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.iter = iter
+}
+
 // Key implements Iterator.
 func (it *coreIterator) Key() []byte {
 	it.assertIsValid()
@@ -82,10 +116,20 @@ func (it *coreIterator) Value() []byte {
 }
 
 // Error implements Iterator.
-func (it *coreIterator) Error() error { return it.iter.Error() }
+func (it *coreIterator) Error() error {
+	if it.err != nil || it.iter == nil {
+		return it.err
+	}
+	return it.iter.Error()
+}
 
 // Close implements Iterator.
-func (it *coreIterator) Close() error { return it.iter.Close() }
+func (it *coreIterator) Close() error {
+	if it.iter == nil {
+		return nil
+	}
+	return it.iter.Close()
+}
 
 func (it *coreIterator) assertIsValid() {
 	if !it.Valid() {
@@ -205,8 +249,8 @@ func (d *TreeDB) forwardIteratorWithIAVLFallback(start, end []byte) (kvstore.Ite
 	}
 	bounded := newBoundedKVIterator(start, end, alt)
 	if bounded.Valid() || bounded.Error() != nil {
-		if treedbVisibilityOn() {
-			treedbVisibilityf(
+		if d.visibility.enabled {
+			d.visibility.logf(
 				"iter fallback iavl_range=true mode=open_end start=%x end=%x alt_valid=%t",
 				start, end, bounded.Valid(),
 			)
@@ -224,8 +268,8 @@ func (d *TreeDB) forwardIteratorWithIAVLFallback(start, end []byte) (kvstore.Ite
 		return nil, err
 	}
 	bounded2 := newBoundedKVIterator(start, end, alt2)
-	if treedbVisibilityOn() {
-		treedbVisibilityf(
+	if d.visibility.enabled {
+		d.visibility.logf(
 			"iter fallback iavl_range=true mode=prefix_scan start=%x end=%x alt_valid=%t",
 			start, end, bounded2.Valid(),
 		)
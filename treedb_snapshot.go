@@ -0,0 +1,163 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	treedb "github.com/snissn/gomap/TreeDB"
+	"github.com/snissn/gomap/TreeDB/caching"
+	backenddb "github.com/snissn/gomap/TreeDB/db"
+	"github.com/snissn/gomap/TreeDB/tree"
+	"github.com/snissn/gomap/kvstore"
+)
+
+// treeDBSnapshot is an independent point-in-time view of a TreeDB. Unlike
+// PinSnapshot, it does not change what reads through the TreeDB handle see.
+type treeDBSnapshot struct {
+	snap treedb.Snapshot
+}
+
+var _ Snapshot = (*treeDBSnapshot)(nil)
+
+// NewSnapshot implements DB.
+func (d *TreeDB) NewSnapshot() (Snapshot, error) {
+	if d.db == nil {
+		return nil, treedb.ErrClosed
+	}
+	snap := d.db.AcquireSnapshot()
+	if snap == nil {
+		return nil, treedb.ErrClosed
+	}
+	return &treeDBSnapshot{snap: snap}, nil
+}
+
+// Get implements Snapshot.
+func (s *treeDBSnapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	val, err := s.snap.Get(key)
+	if err != nil {
+		if errors.Is(err, tree.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return val, nil
+}
+
+// Has implements Snapshot.
+func (s *treeDBSnapshot) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, errKeyEmpty
+	}
+	if s.snap == nil {
+		return false, errSnapshotClosed
+	}
+	return s.snap.Has(key)
+}
+
+// Iterator implements Snapshot.
+func (s *treeDBSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	return s.iterator(start, end, false)
+}
+
+// ReverseIterator implements Snapshot.
+func (s *treeDBSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	return s.iterator(start, end, true)
+}
+
+func (s *treeDBSnapshot) iterator(start, end []byte, isReverse bool) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	open := func(start, end []byte) (kvstore.Iterator, error) {
+		return s.open(start, end, isReverse)
+	}
+	it, err := open(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &coreIterator{iter: it, start: start, end: end, isReverse: isReverse, reopen: open}, nil
+}
+
+// open opens a live-key iterator over the snapshot. The public Snapshot
+// interface only exposes point reads, so range scans go through the concrete
+// snapshot types TreeDB hands out.
+func (s *treeDBSnapshot) open(start, end []byte, isReverse bool) (kvstore.Iterator, error) {
+	if s.snap == nil {
+		return nil, errSnapshotClosed
+	}
+	var (
+		it  kvstore.Iterator
+		err error
+	)
+	switch snap := s.snap.(type) {
+	case *caching.Snapshot:
+		if isReverse {
+			it, err = snap.ReverseIterator(start, end)
+		} else {
+			it, err = snap.Iterator(start, end)
+		}
+	case *backenddb.Snapshot:
+		if isReverse {
+			it, err = snap.ReverseIterator(start, end)
+		} else {
+			it, err = snap.Iterator(start, end)
+		}
+	default:
+		return nil, fmt.Errorf("treedb snapshot %T does not support iteration", s.snap)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newLiveKVIterator(it), nil
+}
+
+// Close implements Snapshot.
+func (s *treeDBSnapshot) Close() error {
+	if s.snap == nil {
+		return nil
+	}
+	err := s.snap.Close()
+	s.snap = nil
+	return err
+}
+
+type tombstoneReporter interface {
+	IsDeleted() bool
+}
+
+// liveKVIterator hides tombstones from raw backend iterators. Iterators that
+// do not report tombstones are returned unchanged.
+type liveKVIterator struct {
+	kvstore.Iterator
+	deleted tombstoneReporter
+}
+
+func newLiveKVIterator(src kvstore.Iterator) kvstore.Iterator {
+	deleted, ok := src.(tombstoneReporter)
+	if !ok {
+		return src
+	}
+	it := &liveKVIterator{Iterator: src, deleted: deleted}
+	it.skipDeleted()
+	return it
+}
+
+func (it *liveKVIterator) Next() {
+	it.Iterator.Next()
+	it.skipDeleted()
+}
+
+func (it *liveKVIterator) skipDeleted() {
+	for it.Iterator.Valid() && it.deleted.IsDeleted() {
+		it.Iterator.Next()
+	}
+}
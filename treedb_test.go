@@ -19,6 +19,57 @@ func TestTreeDBBackend(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestTreeDBOptions(t *testing.T) {
+	// options take precedence over the environment
+	t.Setenv(envTreeDBOpenProfile, "bench")
+	t.Setenv(envTreeDBKeepRecent, "not-a-number")
+	t.Setenv(envTreeDBCosmosDebugVisibility, "1")
+
+	db, err := NewTreeDB(fmt.Sprintf("test_%x", randStr(12)), t.TempDir(), OptionsMap{
+		TreeDBOptProfile:         "command_wal_durable",
+		TreeDBOptKeepRecent:      3,
+		TreeDBOptMemtableMode:    "adaptive:hash_sorted",
+		TreeDBOptDebugVisibility: false,
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.False(t, db.visibility.enabled)
+	require.NoError(t, db.Set([]byte("key"), []byte("value")))
+	value, err := db.Get([]byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), value)
+
+	// the environment is the fallback for unset options
+	_, err = NewTreeDB(fmt.Sprintf("test_%x", randStr(12)), t.TempDir(), OptionsMap{
+		TreeDBOptProfile: "command_wal_durable",
+	})
+	require.ErrorContains(t, err, envTreeDBKeepRecent)
+}
+
+func TestTreeDBInvalidName(t *testing.T) {
+	// names are validated by the TreeDB adapter, which rejects paths
+	for _, name := range []string{"", "..", "a/b", "../escape"} {
+		_, err := NewTreeDB(name, t.TempDir(), nil)
+		require.Error(t, err, "name %q", name)
+	}
+}
+
+func TestTreeDBOptionsInvalid(t *testing.T) {
+	testCases := map[string]OptionsMap{
+		"unsupported profile": {TreeDBOptProfile: "bench"},
+		"unknown profile":     {TreeDBOptProfile: "fastest"},
+		"negative keep":       {TreeDBOptKeepRecent: -1},
+		"mistyped memtable":   {TreeDBOptMemtableMode: []string{"skiplist"}},
+	}
+	for desc, opts := range testCases {
+		t.Run(desc, func(t *testing.T) {
+			_, err := NewTreeDB(fmt.Sprintf("test_%x", randStr(12)), t.TempDir(), opts)
+			require.Error(t, err)
+		})
+	}
+}
+
 func BenchmarkTreeDBRandomReadsWrites(b *testing.B) {
 	name := fmt.Sprintf("test_%x", randStr(12))
 	dir := os.TempDir()
@@ -8,32 +8,23 @@ import (
 	"os"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
 const envTreeDBCosmosDebugVisibility = "TREEDB_COSMOS_DEBUG_VISIBILITY"
 const envTreeDBCosmosDebugPrefix = "TREEDB_COSMOS_DEBUG_PREFIX"
 
-var treedbVisibilityOnce sync.Once
-var treedbVisibilityEnabled atomic.Bool
-var treedbVisibilityPrefix string
-var treedbVisibilityMu sync.Mutex
-
-func treedbVisibilityOn() bool {
-	treedbVisibilityOnce.Do(func() {
-		raw := strings.TrimSpace(os.Getenv(envTreeDBCosmosDebugVisibility))
-		if raw == "" || raw == "0" || strings.EqualFold(raw, "false") {
-			return
-		}
-		treedbVisibilityEnabled.Store(true)
-		treedbVisibilityPrefix = strings.TrimSpace(os.Getenv(envTreeDBCosmosDebugPrefix))
-	})
-	return treedbVisibilityEnabled.Load()
+// treedbVisibility configures the visibility debug log of a TreeDB, which traces reads and
+// writes of IAVL root and multistore metadata keys, plus keys with the configured prefix.
+type treedbVisibility struct {
+	enabled bool
+	prefix  string
 }
 
-func treedbVisibilityf(format string, args ...any) {
-	if !treedbVisibilityOn() {
+var treedbVisibilityMu sync.Mutex
+
+func (v treedbVisibility) logf(format string, args ...any) {
+	if !v.enabled {
 		return
 	}
 	line := fmt.Sprintf("treedb-cosmos-visibility %s "+format+"\n", append([]any{time.Now().Format(time.RFC3339Nano)}, args...)...)
@@ -42,8 +33,8 @@ func treedbVisibilityf(format string, args ...any) {
 	treedbVisibilityMu.Unlock()
 }
 
-func treedbVisibilityTrackKey(key []byte) bool {
-	if !treedbVisibilityOn() {
+func (v treedbVisibility) trackKey(key []byte) bool {
+	if !v.enabled {
 		return false
 	}
 	if len(key) == 0 {
@@ -52,13 +43,25 @@ func treedbVisibilityTrackKey(key []byte) bool {
 	if isRootMultiMetaKey(key) {
 		return true
 	}
-	if _, _, ok := prefixedIAVLRootVersion(key); ok {
+	if _, _, ok := v.trackRootVersion(key); ok {
 		return true
 	}
-	if treedbVisibilityPrefix == "" {
+	if v.prefix == "" {
 		return false
 	}
-	return bytes.HasPrefix(key, []byte(treedbVisibilityPrefix))
+	return bytes.HasPrefix(key, []byte(v.prefix))
+}
+
+// trackRootVersion returns the root version of an IAVL root node key which should be logged.
+func (v treedbVisibility) trackRootVersion(key []byte) (version uint64, prefix []byte, ok bool) {
+	if !v.enabled {
+		return 0, nil, false
+	}
+	version, prefix, ok = prefixedIAVLRootVersion(key)
+	if !ok || v.prefix == "" || strings.Contains(string(prefix), v.prefix) {
+		return version, prefix, ok
+	}
+	return 0, nil, false
 }
 
 // prefixedIAVLRootVersion returns the parsed root version for a key that ends
@@ -73,13 +76,7 @@ func prefixedIAVLRootVersion(key []byte) (version uint64, prefix []byte, ok bool
 	}
 	version = binary.BigEndian.Uint64(tail[1:9])
 	prefix = key[:len(key)-13]
-	if treedbVisibilityPrefix == "" {
-		return version, prefix, true
-	}
-	if strings.Contains(string(prefix), treedbVisibilityPrefix) {
-		return version, prefix, true
-	}
-	return 0, nil, false
+	return version, prefix, true
 }
 
 func isRootMultiMetaKey(key []byte) bool {
@@ -13,6 +13,9 @@ var (
 
 	// errValueNil is returned when attempting to set a nil value.
 	errValueNil = errors.New("value cannot be nil")
+
+	// errSnapshotClosed is returned when a closed snapshot is used.
+	errSnapshotClosed = errors.New("snapshot has been closed")
 )
 
 // DB is the main interface for all database backends. DBs are concurrency-safe. Callers must call
@@ -29,6 +32,18 @@ type DB interface {
 	// CONTRACT: key, value readonly []byte
 	Has(key []byte) (bool, error)
 
+	// GetMany fetches the values of the given keys, in the same order, with nil for keys which do
+	// not exist. It is equivalent to calling Get for each key, but backends serve it in fewer
+	// calls where they can. Empty keys error.
+	// CONTRACT: keys, values readonly []byte
+	GetMany(keys [][]byte) ([][]byte, error)
+
+	// View calls fn with the value of the given key, or nil if it does not exist, and returns the
+	// error of fn. Backends lend fn their own buffer where they can instead of copying the value,
+	// so the value is only valid until fn returns, and must not be modified or retained.
+	// CONTRACT: key readonly []byte
+	View(key []byte, fn func(value []byte) error) error
+
 	// Set sets the value for the given key, replacing it if it already exists.
 	// CONTRACT: key, value readonly []byte
 	Set([]byte, []byte) error
@@ -43,6 +58,12 @@ type DB interface {
 	// DeleteSync deletes the key, and flushes the delete to storage before returning.
 	DeleteSync([]byte) error
 
+	// DeleteRange deletes all keys in the domain [start, end), or does nothing if there are none.
+	// A nil start deletes from the first key, and a nil end deletes to the last key (inclusive).
+	// Empty keys are not valid.
+	// CONTRACT: start, end readonly []byte
+	DeleteRange(start, end []byte) error
+
 	// Iterator returns an iterator over a domain of keys, in ascending order. The caller must call
 	// Close when done. End is exclusive, and start must be less than end. A nil start iterates
 	// from the first key, and a nil end iterates to the last key (inclusive). Empty keys are not
@@ -69,11 +90,81 @@ type DB interface {
 	// This will does the same thing as NewBatch if the batch implementation doesn't support pre-allocation.
 	NewBatchWithSize(int) Batch
 
+	// NewIndexedBatch creates a batch for atomic updates which can also serve reads, merging its
+	// pending operations over the database. The caller must call Batch.Close.
+	NewIndexedBatch() IndexedBatch
+
+	// NewSnapshot creates a read-only, point-in-time view of the database. Writes made to the
+	// database after the snapshot is taken are not visible through it. The caller must call
+	// Snapshot.Close.
+	NewSnapshot() (Snapshot, error)
+
+	// EstimateSize returns an estimate of the number of bytes the keys in the domain [start, end)
+	// take up in storage, from the statistics of the backend where it has them. Estimates may
+	// leave out recent writes which are not yet on disk. A nil start or end leaves the domain
+	// unbounded at that end, and empty keys error.
+	// CONTRACT: start, end readonly []byte
+	EstimateSize(start, end []byte) (uint64, error)
+
+	// EstimateKeys returns an estimate of the number of keys in the domain [start, end), under the
+	// same rules as EstimateSize.
+	// CONTRACT: start, end readonly []byte
+	EstimateKeys(start, end []byte) (uint64, error)
+
+	// Backup writes a consistent copy of the database to destDir, which must not exist. The copy
+	// can be opened with NewDB under the same backend, naming destDir as the database directory:
+	//
+	//	NewDB(strings.TrimSuffix(filepath.Base(destDir), DBFileSuffix), backend, filepath.Dir(destDir))
+	Backup(destDir string) error
+
 	// Print is used for debugging.
 	Print() error
 
-	// Stats returns a map of property values for all keys and the size of the cache.
+	// Stats returns a map of property values for all keys and the size of the cache. It includes
+	// a flattened view of TypedStats, under the "stats." prefix.
 	Stats() map[string]string
+
+	// TypedStats returns the statistics every backend reports in the same form.
+	TypedStats() DBStats
+}
+
+// Compactor is implemented by databases which can compact a range of keys on demand, reclaiming
+// the space of deleted and overwritten keys. All the backends in this package implement it.
+type Compactor interface {
+	// Compact compacts the keys in the domain [start, end). A nil start or end leaves the domain
+	// unbounded at that end, and empty keys error. Backends may compact a wider range than asked.
+	// CONTRACT: start, end readonly []byte
+	Compact(start, end []byte) error
+}
+
+// Snapshot is a consistent, read-only view of a DB at the point in time it was created. Reads
+// through a snapshot are unaffected by concurrent writes to the DB. Callers must call Close on the
+// snapshot when done, which releases any resources pinned by the backend.
+//
+// As with DB, keys and values should be considered read-only, and must be copied before they are
+// modified.
+type Snapshot interface {
+	// Get fetches the value of the given key, or nil if it does not exist.
+	// CONTRACT: key, value readonly []byte
+	Get([]byte) ([]byte, error)
+
+	// Has checks if a key exists.
+	// CONTRACT: key, value readonly []byte
+	Has(key []byte) (bool, error)
+
+	// Iterator returns an iterator over a domain of keys, in ascending order. The caller must call
+	// Close when done. The same domain rules as DB.Iterator apply.
+	// CONTRACT: start, end readonly []byte
+	Iterator(start, end []byte) (Iterator, error)
+
+	// ReverseIterator returns an iterator over a domain of keys, in descending order. The caller
+	// must call Close when done. The same domain rules as DB.ReverseIterator apply.
+	// CONTRACT: start, end readonly []byte
+	ReverseIterator(start, end []byte) (Iterator, error)
+
+	// Close releases the snapshot. It is idempotent, but calls to other methods afterwards will
+	// error. Iterators created from the snapshot must be closed before the snapshot.
+	Close() error
 }
 
 // Batch represents a group of writes. They may or may not be written atomically depending on the
@@ -90,6 +181,11 @@ type Batch = interface {
 	// CONTRACT: key readonly []byte
 	Delete(key []byte) error
 
+	// DeleteRange deletes all keys in the domain [start, end), following the same bound rules as
+	// DB.DeleteRange. It applies to operations added to the batch before it, but not after.
+	// CONTRACT: start, end readonly []byte
+	DeleteRange(start, end []byte) error
+
 	// Write writes the batch, possibly without flushing to disk. Only Close() can be called after,
 	// other methods will error.
 	Write() error
@@ -107,6 +203,36 @@ type Batch = interface {
 	GetByteSize() (int, error)
 }
 
+// IndexedBatch is a Batch that indexes its pending operations, so that reads through it observe
+// the batch's own writes layered over the current contents of the database. Once the batch has
+// been written or closed, reads error like the other batch methods.
+//
+// Reads see the database as it is at the time of the read, not when the batch was created.
+type IndexedBatch interface {
+	Batch
+
+	// Get fetches the value of the given key, or nil if it does not exist or has been deleted in
+	// the batch.
+	// CONTRACT: key, value readonly []byte
+	Get([]byte) ([]byte, error)
+
+	// Has checks if a key exists.
+	// CONTRACT: key, value readonly []byte
+	Has(key []byte) (bool, error)
+
+	// Iterator returns an iterator over a domain of keys, in ascending order. The caller must call
+	// Close when done. The same domain rules as DB.Iterator apply.
+	// CONTRACT: No writes may happen to the batch or within the domain while an iterator exists.
+	// CONTRACT: start, end readonly []byte
+	Iterator(start, end []byte) (Iterator, error)
+
+	// ReverseIterator returns an iterator over a domain of keys, in descending order. The caller
+	// must call Close when done. The same domain rules as DB.ReverseIterator apply.
+	// CONTRACT: No writes may happen to the batch or within the domain while an iterator exists.
+	// CONTRACT: start, end readonly []byte
+	ReverseIterator(start, end []byte) (Iterator, error)
+}
+
 // Iterator represents an iterator over a domain of keys. Callers must call Close when done.
 // No writes can happen to a domain while there exists an iterator over it, some backends may take
 // out database locks to ensure this will not happen.
@@ -136,13 +262,21 @@ type Iterator = interface {
 	Domain() (start, end []byte)
 
 	// Valid returns whether the current iterator is valid. Once invalid, the Iterator remains
-	// invalid forever.
+	// invalid forever, unless it is repositioned with Seek.
 	Valid() bool
 
 	// Next moves the iterator to the next key in the database, as defined by order of iteration.
 	// If Valid returns false, this method will panic.
 	Next()
 
+	// Seek moves the iterator to the first key reached from the given key in the order of
+	// iteration: for ascending iterators the first key >= key (SeekGE), and for descending
+	// iterators the last key < key (SeekLT), matching the exclusive end of a domain. The key is
+	// clamped to the iterator's domain, and the iterator is invalid if no key in the domain
+	// qualifies. Seek may be called on an exhausted iterator, but panics if the key is empty.
+	// CONTRACT: key readonly []byte
+	Seek(key []byte)
+
 	// Key returns the key at the current position. Panics if the iterator is invalid.
 	// CONTRACT: key readonly []byte
 	Key() (key []byte)
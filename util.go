@@ -2,9 +2,38 @@ package db
 
 import (
 	"bytes"
+	"maps"
 	"os"
+	"slices"
 )
 
+// getMany fetches the values of keys with a Get per key, for implementations of DB.GetMany
+// without a native multi-get.
+func getMany(get func([]byte) ([]byte, error), keys [][]byte) ([][]byte, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		value, err := get(key)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// checkKeys returns errKeyEmpty if any of keys is empty.
+func checkKeys(keys [][]byte) error {
+	for _, key := range keys {
+		if len(key) == 0 {
+			return errKeyEmpty
+		}
+	}
+	return nil
+}
+
 func cp(bz []byte) (ret []byte) {
 	ret = make([]byte, len(bz))
 	copy(ret, bz)
@@ -45,6 +74,60 @@ func IsKeyInDomain(key, start, end []byte) bool {
 	return true
 }
 
+// resolveDeleteRange turns the nil bounds of a DeleteRange domain into concrete keys, for
+// backends whose range tombstones need both ends. A nil start becomes the empty key, which sorts
+// before every valid key, and a nil end becomes the key right after the last key in the domain,
+// in the database or, for a batch, in pending, the largest key set earlier in the batch.
+// ok is false if the domain holds no keys.
+func resolveDeleteRange(db DB, start, end, pending []byte) (lo, hi []byte, ok bool, err error) {
+	lo, hi = start, end
+	if lo == nil {
+		lo = []byte{}
+	}
+	if hi == nil {
+		itr, err := db.ReverseIterator(start, nil)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		defer itr.Close()
+		if itr.Valid() {
+			hi = append(cp(itr.Key()), 0x00)
+		} else if err := itr.Error(); err != nil {
+			return nil, nil, false, err
+		}
+		if pending != nil && bytes.Compare(pending, hi) >= 0 {
+			hi = append(cp(pending), 0x00)
+		}
+		if hi == nil {
+			return nil, nil, false, nil
+		}
+	}
+	if bytes.Compare(lo, hi) >= 0 {
+		return nil, nil, false, nil
+	}
+	return lo, hi, true, nil
+}
+
+// seekTarget clamps the key given to Iterator.Seek to the domain [start, end) of an iterator.
+// Ascending iterators seek to the first key >= target, descending iterators to the last key
+// < target. ok is false if no key in the domain can satisfy the seek.
+func seekTarget(key, start, end []byte, isReverse bool) (target []byte, ok bool) {
+	if len(key) == 0 {
+		panic("seek key cannot be empty")
+	}
+	target = key
+	if isReverse {
+		if end != nil && bytes.Compare(target, end) > 0 {
+			target = end
+		}
+		return target, start == nil || bytes.Compare(target, start) > 0
+	}
+	if start != nil && bytes.Compare(target, start) < 0 {
+		target = start
+	}
+	return target, end == nil || bytes.Compare(target, end) < 0
+}
+
 func FileExists(filePath string) bool {
 	_, err := os.Stat(filePath)
 	return !os.IsNotExist(err)
@@ -61,3 +144,8 @@ func (m OptionsMap) Get(key string) interface{} {
 
 	return v
 }
+
+// AllKeys implements KeyedOptions.
+func (m OptionsMap) AllKeys() []string {
+	return slices.Sorted(maps.Keys(m))
+}